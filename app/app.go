@@ -10,8 +10,12 @@ import (
 
 	"github.com/Sirupsen/logrus"
 	"github.com/spf13/afero"
+	"github.com/zqzca/back/clamd"
 	"github.com/zqzca/back/dependencies"
+	"github.com/zqzca/back/geoip"
+	"github.com/zqzca/back/jobs"
 	"github.com/zqzca/back/lib"
+	"github.com/zqzca/back/processors"
 	"github.com/zqzca/back/ws"
 	"golang.org/x/crypto/acme/autocert"
 )
@@ -22,6 +26,9 @@ func init() {
 
 var config Config
 
+// Sizes anyone can ask /i/:slug for.
+var imagePresets lib.ImagePresets
+
 // Run the application, start http and scp server.
 func Run(appConfig Config) {
 	config = appConfig
@@ -33,6 +40,80 @@ func Run(appConfig Config) {
 		return
 	}
 
+	proxies, err := lib.ParseProxies(config.TrustedProxies)
+	if err != nil {
+		fmt.Println("Failed to parse trusted proxies:", err)
+		return
+	}
+	lib.TrustedProxies = proxies
+
+	switch config.ForwardedHeader {
+	case lib.HeaderXForwardedFor, lib.HeaderForwarded:
+		lib.ForwardedHeader = config.ForwardedHeader
+	default:
+		fmt.Println("Unknown forwarded header:", config.ForwardedHeader)
+		return
+	}
+
+	lib.Slugs = lib.SlugGenerator{
+		Length:    config.SlugLength,
+		Alphabet:  config.SlugAlphabet,
+		WordCount: config.SlugWordCount,
+	}
+	if len(config.SlugWords) > 0 {
+		words, err := lib.LoadWords(config.SlugWords)
+		if err != nil {
+			fmt.Println("Failed to load slug wordlist:", err)
+			return
+		}
+		lib.Slugs.Words = words
+	}
+
+	if len(config.Thumbnails) > 0 {
+		renditions, err := processors.ParseRenditions(config.Thumbnails)
+		if err != nil {
+			fmt.Println("Failed to parse thumbnails:", err)
+			return
+		}
+		processors.Renditions = renditions
+	}
+	if len(config.ThumbnailFormats) > 0 {
+		for _, f := range config.ThumbnailFormats {
+			if f != lib.ThumbnailJPEG && f != lib.ThumbnailWebP {
+				fmt.Println("Unknown thumbnail format:", f)
+				return
+			}
+		}
+		processors.ThumbnailFormats = config.ThumbnailFormats
+	}
+
+	processors.PrivacyDefault = config.Privacy
+	processors.KeepOriginals = !config.DiscardOriginals
+	processors.ManifestMinSize = config.ManifestMinSize
+
+	switch config.ClamdOversized {
+	case processors.OversizedReject, processors.OversizedUnscanned:
+		processors.ScanOversized = config.ClamdOversized
+	default:
+		fmt.Println("Unknown clamd oversized policy:", config.ClamdOversized)
+		return
+	}
+	processors.MaxScanSize = config.ClamdMaxSize
+
+	if len(config.Clamd) > 0 {
+		processors.Scanner = clamd.New(config.Clamd)
+		if err = processors.Scanner.Ping(); err != nil {
+			fmt.Println("Failed to reach clamd:", err)
+			return
+		}
+	}
+
+	imagePresets, err = lib.ParseImagePresets(config.ImagePresets)
+	if err != nil {
+		fmt.Println("Failed to parse image presets:", err)
+		return
+	}
+
 	// Logging
 	log := logrus.New()
 	log.Level = logrus.DebugLevel
@@ -50,8 +131,26 @@ func Run(appConfig Config) {
 		WS:     ws,
 	}
 
+	// Background processing
+	queue := jobs.NewQueue(deps, config.Workers)
+	deps.Jobs = queue
+	processors.RegisterJobs(deps, queue)
+
 	ws.Dependencies = &deps
 	go ws.Start()
+	go queue.Start(nil)
+
+	if len(config.GeoIPPath) > 0 {
+		geo, err := geoip.Open(config.GeoIPPath)
+		if err != nil {
+			deps.Error("Failed to load GeoIP database", "err", err)
+		} else {
+			lib.GeoIP = geo
+			go geo.Watch(time.Minute, nil, func(err error) {
+				deps.Warn("Failed to reload GeoIP database", "err", err)
+			})
+		}
+	}
 
 	// // Start SCP
 	// scp := scp.Server{}
@@ -66,7 +165,7 @@ func Run(appConfig Config) {
 		m := autocert.Manager{
 			Cache:      c,
 			Prompt:     autocert.AcceptTOS,
-			HostPolicy: autocert.HostWhitelist("x.zqz.ca", "de.zqz.ca", "zqz.ca"),
+			HostPolicy: autocert.HostWhitelist(certHosts()...),
 		}
 
 		s := &http.Server{
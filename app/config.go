@@ -7,4 +7,61 @@ type Config struct {
 	LiveReload   bool
 	CDNURL       string
 	Secure       bool
+
+	// CIDRs of reverse proxies allowed to set ForwardedHeader, which is
+	// X-Forwarded-For or Forwarded.
+	TrustedProxies  []string
+	ForwardedHeader string
+
+	// Path to a MaxMind format database used to tag downloads with a country
+	// and ASN. Optional.
+	GeoIPPath string
+
+	// AppHost serves the app and websocket. UserContentHost serves raw
+	// uploads and thumbnails on a separate origin. Both are optional; without
+	// UserContentHost everything is served from every host.
+	AppHost         string
+	UserContentHost string
+
+	// Random slugs are SlugLength characters from SlugAlphabet, or
+	// SlugWordCount words from the SlugWords file when it is set.
+	SlugLength    int
+	SlugAlphabet  string
+	SlugWords     string
+	SlugWordCount int
+
+	// Thumbnail renditions written as kind:width, and the formats each one
+	// is encoded in. Empty means the defaults in processors.
+	Thumbnails       []string
+	ThumbnailFormats []string
+
+	// Options /i/:slug serves to anyone, written as query strings. Other
+	// options need a signature made with ImageSecret.
+	ImagePresets []string
+	ImageSecret  string
+
+	// Privacy strips EXIF, GPS and other metadata from images uploaded
+	// without saying otherwise. DiscardOriginals deletes the original once
+	// the stripped copy is made.
+	Privacy          bool
+	DiscardOriginals bool
+
+	// Address of a clamd daemon every upload is scanned with, eg.
+	// localhost:3310 or /run/clamav/clamd.ctl. Optional. Files over
+	// ClamdMaxSize, which should match clamd's StreamMaxLength, are rejected
+	// or served unscanned as ClamdOversized says.
+	Clamd          string
+	ClamdMaxSize   int64
+	ClamdOversized string
+
+	// Uploads at least this big that only need streaming processors are
+	// stored as their chunks instead of a single blob. Zero always builds
+	// a blob.
+	ManifestMinSize int64
+
+	// Number of files processed at the same time.
+	Workers int
+
+	// Text files bigger than this are truncated in the inline viewer.
+	ViewerLimit int64
 }
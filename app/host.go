@@ -0,0 +1,83 @@
+package app
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// hostSwitch sends requests to a handler based on the Host header.
+type hostSwitch struct {
+	hosts    map[string]http.Handler
+	fallback http.Handler
+}
+
+func (hs hostSwitch) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h, ok := hs.hosts[nakedHost(r.Host)]; ok {
+		h.ServeHTTP(w, r)
+		return
+	}
+
+	hs.fallback.ServeHTTP(w, r)
+}
+
+func nakedHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	return strings.ToLower(host)
+}
+
+func scheme() string {
+	if config.Secure {
+		return "https"
+	}
+
+	return "http"
+}
+
+// userContentRedirect sends the client to the same path on the usercontent
+// host.
+func userContentRedirect(w http.ResponseWriter, r *http.Request) {
+	u := *r.URL
+	u.Scheme = scheme()
+	u.Host = config.UserContentHost
+	http.Redirect(w, r, u.String(), http.StatusFound)
+}
+
+// appRedirect sends the client to the same path on the app host.
+func appRedirect(w http.ResponseWriter, r *http.Request) {
+	if len(config.AppHost) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	u := *r.URL
+	u.Scheme = scheme()
+	u.Host = config.AppHost
+	http.Redirect(w, r, u.String(), http.StatusFound)
+}
+
+// certHosts lists every host we request certificates for.
+func certHosts() []string {
+	hosts := []string{"x.zqz.ca", "de.zqz.ca", "zqz.ca"}
+
+	for _, h := range []string{config.AppHost, config.UserContentHost} {
+		if len(h) > 0 && !contains(hosts, h) {
+			hosts = append(hosts, h)
+		}
+	}
+
+	return hosts
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,51 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHostSwitch(t *testing.T) {
+	a := assert.New(t)
+
+	handler := func(name string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(name))
+		})
+	}
+
+	hs := hostSwitch{
+		hosts:    map[string]http.Handler{"usercontent.test": handler("content")},
+		fallback: handler("app"),
+	}
+
+	for host, expected := range map[string]string{
+		"usercontent.test":      "content",
+		"USERCONTENT.test:3001": "content",
+		"app.test":              "app",
+		"":                      "app",
+	} {
+		r := httptest.NewRequest("GET", "/d/abc", nil)
+		r.Host = host
+		w := httptest.NewRecorder()
+		hs.ServeHTTP(w, r)
+		a.Equal(expected, w.Body.String(), host)
+	}
+}
+
+func TestUserContentRedirect(t *testing.T) {
+	a := assert.New(t)
+
+	config = Config{Secure: true, UserContentHost: "usercontent.test"}
+	defer func() { config = Config{} }()
+
+	r := httptest.NewRequest("GET", "/d/abc?x=1", nil)
+	w := httptest.NewRecorder()
+	userContentRedirect(w, r)
+
+	a.Equal(http.StatusFound, w.Code)
+	a.Equal("https://usercontent.test/d/abc?x=1", w.Header().Get("Location"))
+}
@@ -0,0 +1,163 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"github.com/zqzca/back/jobs"
+	"github.com/zqzca/back/lib"
+)
+
+// ReprocessOptions picks which finished files are queued to run through the
+// processors again.
+type ReprocessOptions struct {
+	All   bool
+	Slugs []string
+
+	// Type is a MIME type where * matches anything, eg. image/*.
+	Type string
+	// Since is either a duration back from now, eg. 72h, or a date.
+	Since string
+
+	// Files queued a second, zero for as fast as possible.
+	Rate float64
+}
+
+// Reprocess queues matching files for the running server to pick up and
+// prints progress as it goes.
+func Reprocess(opts ReprocessOptions) error {
+	if !opts.All && len(opts.Slugs) == 0 && opts.Type == "" && opts.Since == "" {
+		return errors.New("Pick files with --all, --type, --since or slugs")
+	}
+
+	query, params, err := reprocessQuery(opts)
+	if err != nil {
+		return err
+	}
+
+	db, err := lib.Connect()
+	if err != nil {
+		return errors.Wrap(err, "Failed to connect to db")
+	}
+	defer db.Close()
+
+	var ids []string
+	if err = db.Select(&ids, query, params...); err != nil {
+		return errors.Wrap(err, "Failed to find files")
+	}
+
+	return enqueueAll(db, jobs.ReprocessFile, ids, opts.Rate)
+}
+
+// reprocessQuery selects the ids of the files opts picks, oldest first.
+func reprocessQuery(opts ReprocessOptions) (string, []interface{}, error) {
+	where := []string{"state = ANY($1)"}
+	params := []interface{}{pq.Array([]int{lib.FileFinished, lib.FileQuarantined})}
+
+	if len(opts.Slugs) > 0 {
+		params = append(params, pq.Array(opts.Slugs))
+		where = append(where, fmt.Sprintf("slug = ANY($%d)", len(params)))
+	}
+
+	if opts.Type != "" {
+		params = append(params, strings.Replace(opts.Type, "*", "%", -1))
+		where = append(where, fmt.Sprintf("coalesce(detected_type, type) LIKE $%d", len(params)))
+	}
+
+	if opts.Since != "" {
+		since, err := parseSince(opts.Since)
+		if err != nil {
+			return "", nil, err
+		}
+
+		params = append(params, since)
+		where = append(where, fmt.Sprintf("created_at >= $%d", len(params)))
+	}
+
+	return "SELECT id FROM files WHERE " + strings.Join(where, " AND ") + " ORDER BY created_at ASC", params, nil
+}
+
+// CompactOptions picks which files stored as a manifest are written out into
+// a single blob.
+type CompactOptions struct {
+	All   bool
+	Slugs []string
+
+	// Files queued a second, zero for as fast as possible.
+	Rate float64
+}
+
+// Compact queues manifests for the running server to compact.
+func Compact(opts CompactOptions) error {
+	if !opts.All && len(opts.Slugs) == 0 {
+		return errors.New("Pick files with --all or slugs")
+	}
+
+	query := "SELECT f.id FROM files AS f JOIN file_manifests AS m ON m.file_id = f.id"
+	var params []interface{}
+	if !opts.All {
+		query += " WHERE f.slug = ANY($1)"
+		params = append(params, pq.Array(opts.Slugs))
+	}
+
+	db, err := lib.Connect()
+	if err != nil {
+		return errors.Wrap(err, "Failed to connect to db")
+	}
+	defer db.Close()
+
+	var ids []string
+	if err = db.Select(&ids, query+" ORDER BY f.created_at ASC", params...); err != nil {
+		return errors.Wrap(err, "Failed to find files")
+	}
+
+	return enqueueAll(db, jobs.CompactFile, ids, opts.Rate)
+}
+
+// enqueueAll queues a job for every file, no more than rate a second, and
+// prints progress as it goes.
+func enqueueAll(ex *sqlx.DB, kind string, ids []string, rate float64) error {
+	var throttle <-chan time.Time
+	if rate > 0 {
+		t := time.NewTicker(time.Duration(float64(time.Second) / rate))
+		defer t.Stop()
+		throttle = t.C
+	}
+
+	for i, id := range ids {
+		if throttle != nil {
+			<-throttle
+		}
+
+		if err := jobs.Enqueue(ex, kind, id, nil); err != nil {
+			fmt.Println()
+			return err
+		}
+
+		fmt.Printf("\r%d/%d queued", i+1, len(ids))
+	}
+
+	if len(ids) > 0 {
+		fmt.Println()
+	}
+	fmt.Printf("Queued %d files\n", len(ids))
+	return nil
+}
+
+func parseSince(s string) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, errors.Errorf("Invalid --since %q, use a duration like 72h or a date like 2006-01-02", s)
+}
@@ -0,0 +1,56 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSince(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	since, err := parseSince("72h")
+	a.NoError(err)
+	a.WithinDuration(time.Now().Add(-72*time.Hour), since, time.Minute)
+
+	since, err = parseSince("2026-10-01")
+	a.NoError(err)
+	a.Equal(time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC), since)
+
+	since, err = parseSince("2026-10-01T12:30:00Z")
+	a.NoError(err)
+	a.Equal(time.Date(2026, 10, 1, 12, 30, 0, 0, time.UTC), since)
+
+	for _, s := range []string{"", "yesterday", "72", "2026-13-01"} {
+		_, err = parseSince(s)
+		a.Error(err, s)
+	}
+}
+
+func TestReprocessQuery(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	query, params, err := reprocessQuery(ReprocessOptions{All: true})
+	a.NoError(err)
+	a.Equal("SELECT id FROM files WHERE state = ANY($1) ORDER BY created_at ASC", query)
+	a.Len(params, 1)
+
+	query, params, err = reprocessQuery(ReprocessOptions{Slugs: []string{"abc"}, Type: "image/*"})
+	a.NoError(err)
+	a.Equal("SELECT id FROM files WHERE state = ANY($1) AND slug = ANY($2) AND coalesce(detected_type, type) LIKE $3 ORDER BY created_at ASC", query)
+	a.Equal(pq.Array([]string{"abc"}), params[1])
+	a.Equal("image/%", params[2])
+
+	query, params, err = reprocessQuery(ReprocessOptions{Type: "*/*", Since: "2026-10-01"})
+	a.NoError(err)
+	a.Equal("SELECT id FROM files WHERE state = ANY($1) AND coalesce(detected_type, type) LIKE $2 AND created_at >= $3 ORDER BY created_at ASC", query)
+	a.Equal("%/%", params[1])
+	a.Equal(time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC), params[2])
+
+	_, _, err = reprocessQuery(ReprocessOptions{Since: "soon"})
+	a.Error(err)
+}
@@ -10,23 +10,63 @@ import (
 	"github.com/zqzca/back/controller/chunks"
 	"github.com/zqzca/back/controller/dashboard"
 	"github.com/zqzca/back/controller/files"
+	"github.com/zqzca/back/controller/images"
+	"github.com/zqzca/back/controller/stats"
 	"github.com/zqzca/back/controller/thumbnails"
 	"github.com/zqzca/back/dependencies"
 	"github.com/zqzca/back/ws"
 )
 
-// Routes defines all the routes for the application
+// Routes defines all the routes for the application. When a usercontent
+// host is configured, raw file bytes and thumbnails are only served from it.
 func Routes(deps dependencies.Dependencies) http.Handler {
+	app := appRoutes(deps)
+
+	if len(config.UserContentHost) == 0 {
+		return app
+	}
+
+	return hostSwitch{
+		hosts: map[string]http.Handler{
+			nakedHost(config.UserContentHost): userContentRoutes(deps),
+		},
+		fallback: app,
+	}
+}
+
+func appRoutes(deps dependencies.Dependencies) http.Handler {
 	r := chi.NewRouter()
 
 	r.Use(middleware.RequestID)
-	r.Use(middleware.RealIP)
+	r.Use(controller.RealIP)
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
 
 	ep := deps.WS.(*ws.Server).Endpoint()
 	r.Handle("/ws", ep)
 
+	files := files.Controller{
+		Dependencies: deps,
+		Scheme:       scheme(),
+		ContentHost:  config.UserContentHost,
+		ViewerLimit:  config.ViewerLimit,
+	}
+	thumbnails := thumbnails.Controller{Dependencies: deps}
+	images := newImages(deps)
+	dash := dashboard.Controller{Dependencies: deps}
+
+	// Raw user content is redirected to its own host when there is one.
+	download := files.Download
+	thumbnail := thumbnails.Download
+	image := images.Show
+	entry := files.Entry
+	if len(config.UserContentHost) > 0 {
+		download = userContentRedirect
+		thumbnail = userContentRedirect
+		image = userContentRedirect
+		entry = userContentRedirect
+	}
+
 	// Default
 	r.Group(func(r chi.Router) {
 		// r.Use(middleware.CloseNotify)
@@ -34,11 +74,15 @@ func Routes(deps dependencies.Dependencies) http.Handler {
 
 		r.(*chi.Mux).FileServer("/assets", http.Dir("./assets"))
 
-		files := files.Controller{Dependencies: deps}
-		r.Get("/d/:slug", files.Download) // Short DL URL
+		r.Get("/d/:slug", files.Preview(download)) // Short DL URL
+		r.Get("/d/:slug/entry/*", entry)
+		r.Get("/i/:slug", image)
+		r.Get("/oembed", files.OEmbed)
 
 		// Chunks
 		r.Route("/api/v1", func(r chi.Router) {
+			r.Use(controller.Authenticate(deps.DB))
+
 			r.Get("/check/:hash", files.Status) // I dont like this URL
 
 			chunks := chunks.NewController(deps)
@@ -46,19 +90,25 @@ func Routes(deps dependencies.Dependencies) http.Handler {
 				r.Post("/", chunks.Create)
 			})
 
+			stats := stats.Controller{Dependencies: deps}
+			r.With(controller.RequireUser).Get("/stats", stats.Index)
+
 			r.Route("/files", func(r chi.Router) {
 				r.Post("/", files.Create)
 				r.With(controller.Pagination).Get("/", files.Index)
 				r.Get("/:slug", files.Show)
-				r.Get("/:slug/data", files.Download)
+				r.Get("/:slug/data", download)
+				r.With(controller.RequireUser).Get("/:slug/stats", stats.File)
+				r.Get("/:slug/metadata", files.Metadata)
+				r.Get("/:slug/similar", files.Similar)
+				r.Get("/:slug/entries", files.Entries)
+				r.With(controller.RequireUser).Put("/:slug/metadata", files.HideMetadata)
+				r.With(controller.RequireUser).Post("/:slug/reprocess", files.Reprocess)
 				r.Delete("/:slug/delete", files.Delete)
 			})
-			// r.Get("/files/:slug/process", files.Process)
 
-			thumbnails := thumbnails.Controller{Dependencies: deps}
-			r.Get("/thumbnails/:id", thumbnails.Download)
+			r.Get("/thumbnails/:id", thumbnail)
 
-			dash := dashboard.Controller{Dependencies: deps}
 			r.With(controller.Pagination).Get("/dashboard", dash.Index)
 		})
 
@@ -93,6 +143,31 @@ func Routes(deps dependencies.Dependencies) http.Handler {
 	return r
 }
 
+// userContentRoutes only serves raw file data and thumbnails. Nothing on this
+// host shares an origin with the app or the websocket.
+func userContentRoutes(deps dependencies.Dependencies) http.Handler {
+	r := chi.NewRouter()
+
+	r.Use(middleware.RequestID)
+	r.Use(controller.RealIP)
+	r.Use(middleware.Logger)
+	r.Use(middleware.Recoverer)
+
+	files := files.Controller{Dependencies: deps}
+	thumbnails := thumbnails.Controller{Dependencies: deps}
+	images := newImages(deps)
+
+	r.Get("/d/:slug", files.Download)
+	r.Get("/d/:slug/entry/*", files.Entry)
+	r.Get("/i/:slug", images.Show)
+	r.Get("/api/v1/files/:slug/data", files.Download)
+	r.Get("/api/v1/thumbnails/:id", thumbnails.Download)
+
+	r.NotFound(appRedirect)
+
+	return r
+}
+
 func secureRedirect() http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		redir := "https://" + req.Host + req.RequestURI
@@ -100,3 +175,11 @@ func secureRedirect() http.HandlerFunc {
 		http.Redirect(w, req, redir, http.StatusMovedPermanently)
 	}
 }
+
+func newImages(deps dependencies.Dependencies) images.Controller {
+	return images.Controller{
+		Dependencies: deps,
+		Presets:      imagePresets,
+		Secret:       []byte(config.ImageSecret),
+	}
+}
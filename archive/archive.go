@@ -0,0 +1,421 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+)
+
+// Kinds of archives that can be listed.
+const (
+	Zip     = "zip"
+	Tar     = "tar"
+	TarGzip = "tar.gz"
+	TarZstd = "tar.zst"
+)
+
+// Limits that keep a hostile archive from eating the disk, memory or CPU.
+var (
+	// MaxEntries listed, the rest of the manifest is truncated.
+	MaxEntries = 10000
+	// MaxScanned is how many bytes of a compressed tarball are decompressed
+	// while listing it.
+	MaxScanned int64 = 8 << 30
+	// MaxEntrySize is the biggest entry that can be downloaded on its own.
+	MaxEntrySize int64 = 1 << 30
+	// MaxEntryScan is how far into a compressed tarball an entry may start
+	// and still be downloaded on its own. Everything before it has to be
+	// decompressed to get there, on every download.
+	MaxEntryScan int64 = 256 << 20
+	// MaxRatio is how much smaller than its contents a zip entry may be
+	// before it is considered a bomb and never decompressed.
+	MaxRatio = 100.0
+)
+
+// Zstd frames can ask for windows of gigabytes.
+const maxZstdWindow = 64 << 20
+
+var (
+	// ErrNotArchive is returned for files that aren't an archive we know.
+	ErrNotArchive = errors.New("Not an archive")
+	// ErrNotFound is returned for paths that aren't in the archive.
+	ErrNotFound = errors.New("No such entry")
+	// ErrNotDownloadable is returned for directories, links, entries with
+	// unsafe paths and anything that looks like a bomb.
+	ErrNotDownloadable = errors.New("Entry can't be downloaded")
+)
+
+// File is what an archive is read from. Zip needs random access, tarballs
+// are read from the start.
+type File interface {
+	io.Reader
+	io.ReaderAt
+	io.Seeker
+}
+
+// Entry is a file or directory in an archive.
+type Entry struct {
+	Path           string    `json:"path"`
+	Size           int64     `json:"size"`
+	CompressedSize int64     `json:"compressed_size,omitempty"`
+	Ratio          float64   `json:"ratio,omitempty"`
+	Modified       time.Time `json:"modified"`
+	Dir            bool      `json:"dir,omitempty"`
+	Link           bool      `json:"link,omitempty"`
+	Downloadable   bool      `json:"downloadable"`
+}
+
+// Manifest lists what is in an archive. Ratio is the uncompressed size over
+// the size of the archive.
+type Manifest struct {
+	Kind      string  `json:"kind"`
+	Entries   []Entry `json:"entries"`
+	Size      int64   `json:"size"`
+	Ratio     float64 `json:"ratio"`
+	Truncated bool    `json:"truncated,omitempty"`
+}
+
+// Detect works out which kind of archive f is from its first bytes.
+// Compressed files only count when there is a tarball inside.
+func Detect(f File) (string, error) {
+	head, err := peek(f, 512)
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")), bytes.HasPrefix(head, []byte("PK\x05\x06")):
+		return Zip, nil
+	case isTar(head):
+		return Tar, nil
+	case bytes.HasPrefix(head, []byte{0x1f, 0x8b}):
+		if compressedTar(f, TarGzip) {
+			return TarGzip, nil
+		}
+	case bytes.HasPrefix(head, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		if compressedTar(f, TarZstd) {
+			return TarZstd, nil
+		}
+	}
+
+	return "", ErrNotArchive
+}
+
+// List reads the manifest of an archive of size bytes.
+func List(f File, size int64) (*Manifest, error) {
+	kind, err := Detect(f)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Manifest{Kind: kind}
+	if kind == Zip {
+		err = listZip(f, size, m)
+	} else {
+		err = listTar(f, m)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if size > 0 {
+		m.Ratio = float64(m.Size) / float64(size)
+	}
+
+	return m, nil
+}
+
+// Open finds the entry at name and returns its contents.
+func Open(f File, size int64, name string) (*Entry, io.ReadCloser, error) {
+	clean, ok := SafePath(name)
+	if !ok {
+		return nil, nil, ErrNotFound
+	}
+
+	kind, err := Detect(f)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if kind == Zip {
+		return openZip(f, size, clean)
+	}
+
+	return openTar(f, kind, clean)
+}
+
+// SafePath cleans the path of an entry. It is not ok when extracting it
+// would end up outside of the directory it is extracted to.
+func SafePath(name string) (string, bool) {
+	if strings.ContainsRune(name, 0) {
+		return name, false
+	}
+
+	name = strings.Replace(name, "\\", "/", -1)
+
+	// Absolute paths and Windows drive letters.
+	if strings.HasPrefix(name, "/") || (len(name) > 1 && name[1] == ':') {
+		return name, false
+	}
+
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return name, false
+		}
+	}
+
+	clean := path.Clean(name)
+	if clean == "." {
+		return clean, false
+	}
+
+	return clean, true
+}
+
+func listZip(f File, size int64, m *Manifest) error {
+	zr, err := zip.NewReader(f, size)
+	if err != nil {
+		return errors.Wrap(err, "Failed to read zip")
+	}
+
+	for _, zf := range zr.File {
+		e := zipEntry(zf)
+		m.Size += e.Size
+
+		if len(m.Entries) == MaxEntries {
+			m.Truncated = true
+			continue
+		}
+
+		m.Entries = append(m.Entries, e)
+	}
+
+	return nil
+}
+
+func zipEntry(zf *zip.File) Entry {
+	clean, safe := SafePath(zf.Name)
+	mode := zf.Mode()
+
+	e := Entry{
+		Path:           clean,
+		Size:           int64(zf.UncompressedSize64),
+		CompressedSize: int64(zf.CompressedSize64),
+		Modified:       zf.Modified.UTC(),
+		Dir:            mode.IsDir(),
+		Link:           mode&os.ModeSymlink != 0,
+	}
+
+	if e.CompressedSize > 0 {
+		e.Ratio = float64(e.Size) / float64(e.CompressedSize)
+	}
+
+	e.Downloadable = safe && !e.Dir && !e.Link && e.Size <= MaxEntrySize &&
+		(e.CompressedSize > 0 || e.Size == 0) && e.Ratio <= MaxRatio
+
+	return e
+}
+
+func listTar(f File, m *Manifest) error {
+	r, err := decompress(f, m.Kind)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	// Skipping over entries still decompresses them.
+	limited := &io.LimitedReader{R: r, N: MaxScanned}
+	tr := tar.NewReader(limited)
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			if limited.N <= 0 || len(m.Entries) > 0 {
+				// Ran out of budget or hit garbage part way through, what
+				// was read so far is still worth showing.
+				m.Truncated = true
+				return nil
+			}
+			return errors.Wrap(err, "Failed to read tar")
+		}
+
+		if hdr.Typeflag == tar.TypeXGlobalHeader {
+			continue
+		}
+
+		e := tarEntry(hdr)
+		m.Size += e.Size
+
+		if m.Kind != Tar && MaxScanned-limited.N > MaxEntryScan {
+			e.Downloadable = false
+		}
+
+		if len(m.Entries) == MaxEntries {
+			m.Truncated = true
+			return nil
+		}
+
+		m.Entries = append(m.Entries, e)
+	}
+}
+
+func tarEntry(hdr *tar.Header) Entry {
+	clean, safe := SafePath(hdr.Name)
+
+	e := Entry{
+		Path:     clean,
+		Size:     hdr.Size,
+		Modified: hdr.ModTime.UTC(),
+		Dir:      hdr.Typeflag == tar.TypeDir,
+		Link:     hdr.Typeflag == tar.TypeSymlink || hdr.Typeflag == tar.TypeLink,
+	}
+
+	e.Downloadable = safe && hdr.Typeflag == tar.TypeReg && e.Size <= MaxEntrySize
+
+	return e
+}
+
+func openZip(f File, size int64, name string) (*Entry, io.ReadCloser, error) {
+	zr, err := zip.NewReader(f, size)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "Failed to read zip")
+	}
+
+	for _, zf := range zr.File {
+		e := zipEntry(zf)
+		if e.Path != name {
+			continue
+		}
+
+		if !e.Downloadable {
+			return nil, nil, ErrNotDownloadable
+		}
+
+		rc, err := zf.Open()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// Never trust the size in the header to stop the inflater.
+		return &e, limitedReadCloser{io.LimitReader(rc, e.Size), rc}, nil
+	}
+
+	return nil, nil, ErrNotFound
+}
+
+// Plain tarballs are skipped through by seeking. Compressed ones are only
+// decompressed up to MaxEntryScan looking for the entry, then for as long as
+// the entry is.
+func openTar(f File, kind, name string) (*Entry, io.ReadCloser, error) {
+	r, err := decompress(f, kind)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	limited := &io.LimitedReader{R: r, N: MaxEntryScan}
+	var src io.Reader = limited
+	if kind == Tar {
+		src = f
+	}
+
+	tr := tar.NewReader(src)
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			r.Close()
+			if kind != Tar && limited.N <= 0 {
+				return nil, nil, ErrNotDownloadable
+			}
+			if err == io.EOF {
+				return nil, nil, ErrNotFound
+			}
+			return nil, nil, errors.Wrap(err, "Failed to read tar")
+		}
+
+		e := tarEntry(hdr)
+		if e.Path != name {
+			continue
+		}
+
+		if !e.Downloadable {
+			r.Close()
+			return nil, nil, ErrNotDownloadable
+		}
+
+		// Room for the entry and the padding after it.
+		limited.N += e.Size + 512
+		return &e, limitedReadCloser{tr, r}, nil
+	}
+}
+
+type limitedReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// decompress returns the tarball inside f, starting from the beginning.
+func decompress(f File, kind string) (io.ReadCloser, error) {
+	if _, err := f.Seek(0, os.SEEK_SET); err != nil {
+		return nil, err
+	}
+
+	switch kind {
+	case Tar:
+		return ioutil.NopCloser(f), nil
+	case TarGzip:
+		return gzip.NewReader(f)
+	case TarZstd:
+		d, err := zstd.NewReader(f, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxWindow(maxZstdWindow))
+		if err != nil {
+			return nil, err
+		}
+		return d.IOReadCloser(), nil
+	}
+
+	return nil, ErrNotArchive
+}
+
+func compressedTar(f File, kind string) bool {
+	r, err := decompress(f, kind)
+	if err != nil {
+		return false
+	}
+	defer r.Close()
+
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(r, head)
+	return isTar(head[:n])
+}
+
+// Both POSIX and GNU tar headers carry "ustar" at offset 257.
+func isTar(head []byte) bool {
+	return len(head) >= 262 && string(head[257:262]) == "ustar"
+}
+
+func peek(f File, n int) ([]byte, error) {
+	if _, err := f.Seek(0, os.SEEK_SET); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, n)
+	read, err := io.ReadFull(f, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+
+	return buf[:read], nil
+}
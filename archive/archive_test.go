@@ -0,0 +1,260 @@
+package archive_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/zqzca/back/archive"
+)
+
+var modified = time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+
+func makeZip(t *testing.T, files map[string][]byte) *bytes.Reader {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	for name, data := range files {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(data)
+	}
+
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return bytes.NewReader(buf.Bytes())
+}
+
+func makeTar(t *testing.T, compress func(io.Writer) io.WriteCloser) *bytes.Reader {
+	var buf bytes.Buffer
+	out := compress(&buf)
+	tw := tar.NewWriter(out)
+
+	tw.WriteHeader(&tar.Header{Name: "dir/", Typeflag: tar.TypeDir, Mode: 0755, ModTime: modified})
+	tw.WriteHeader(&tar.Header{Name: "dir/hello.txt", Typeflag: tar.TypeReg, Mode: 0644, Size: 5, ModTime: modified})
+	tw.Write([]byte("hello"))
+	tw.WriteHeader(&tar.Header{Name: "../../etc/passwd", Typeflag: tar.TypeReg, Mode: 0644, Size: 1, ModTime: modified})
+	tw.Write([]byte("x"))
+	tw.WriteHeader(&tar.Header{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd", ModTime: modified})
+
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := out.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return bytes.NewReader(buf.Bytes())
+}
+
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+func TestSafePath(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	for name, want := range map[string]string{
+		"a/b.txt":     "a/b.txt",
+		"./a//b.txt":  "a/b.txt",
+		"dir/":        "dir",
+		`win\path.go`: "win/path.go",
+	} {
+		clean, ok := archive.SafePath(name)
+		a.True(ok, name)
+		a.Equal(want, clean)
+	}
+
+	for _, name := range []string{"../x", "a/../../x", "/etc/passwd", `..\x`, `C:\x`, "a\x00b", "."} {
+		_, ok := archive.SafePath(name)
+		a.False(ok, name)
+	}
+}
+
+func TestListZip(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	r := makeZip(t, map[string][]byte{
+		"readme.txt": []byte("hello world"),
+		"../evil":    []byte("nope"),
+		"bomb.bin":   make([]byte, 1<<20),
+	})
+
+	m, err := archive.List(r, r.Size())
+	a.NoError(err)
+	a.Equal(archive.Zip, m.Kind)
+	a.Len(m.Entries, 3)
+	a.Equal(int64(11+4+1<<20), m.Size)
+
+	entries := map[string]archive.Entry{}
+	for _, e := range m.Entries {
+		entries[e.Path] = e
+	}
+
+	a.True(entries["readme.txt"].Downloadable)
+	a.Equal(modified, entries["readme.txt"].Modified)
+	a.False(entries["../evil"].Downloadable)
+	a.True(entries["bomb.bin"].Ratio > archive.MaxRatio)
+	a.False(entries["bomb.bin"].Downloadable)
+
+	e, rc, err := archive.Open(r, r.Size(), "readme.txt")
+	a.NoError(err)
+	data, _ := ioutil.ReadAll(rc)
+	rc.Close()
+	a.Equal("hello world", string(data))
+	a.Equal(int64(11), e.Size)
+
+	_, _, err = archive.Open(r, r.Size(), "../evil")
+	a.Equal(archive.ErrNotFound, err)
+	_, _, err = archive.Open(r, r.Size(), "bomb.bin")
+	a.Equal(archive.ErrNotDownloadable, err)
+	_, _, err = archive.Open(r, r.Size(), "missing")
+	a.Equal(archive.ErrNotFound, err)
+}
+
+func TestListTar(t *testing.T) {
+	t.Parallel()
+
+	kinds := map[string]func(io.Writer) io.WriteCloser{
+		archive.Tar: func(w io.Writer) io.WriteCloser { return nopWriteCloser{w} },
+		archive.TarGzip: func(w io.Writer) io.WriteCloser {
+			return gzip.NewWriter(w)
+		},
+		archive.TarZstd: func(w io.Writer) io.WriteCloser {
+			zw, _ := zstd.NewWriter(w)
+			return zw
+		},
+	}
+
+	for kind, compress := range kinds {
+		a := assert.New(t)
+		r := makeTar(t, compress)
+
+		m, err := archive.List(r, r.Size())
+		a.NoError(err, kind)
+		a.Equal(kind, m.Kind)
+		a.Len(m.Entries, 4, kind)
+		a.Equal(int64(6), m.Size, kind)
+
+		a.True(m.Entries[0].Dir)
+		a.False(m.Entries[0].Downloadable)
+		a.Equal("dir/hello.txt", m.Entries[1].Path)
+		a.True(m.Entries[1].Downloadable)
+		a.False(m.Entries[2].Downloadable)
+		a.True(m.Entries[3].Link)
+		a.False(m.Entries[3].Downloadable)
+
+		_, rc, err := archive.Open(r, r.Size(), "dir/hello.txt")
+		a.NoError(err, kind)
+		data, _ := ioutil.ReadAll(rc)
+		rc.Close()
+		a.Equal("hello", string(data), kind)
+
+		_, _, err = archive.Open(r, r.Size(), "link")
+		a.Equal(archive.ErrNotDownloadable, err, kind)
+	}
+}
+
+func TestEntryScanLimit(t *testing.T) {
+	a := assert.New(t)
+
+	big := bytes.Repeat([]byte("0123456789abcdef"), 256)
+	tarball := func(compress io.WriteCloser) {
+		tw := tar.NewWriter(compress)
+		tw.WriteHeader(&tar.Header{Name: "big.txt", Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(big)), ModTime: modified})
+		tw.Write(big)
+		tw.WriteHeader(&tar.Header{Name: "late.txt", Typeflag: tar.TypeReg, Mode: 0644, Size: 4, ModTime: modified})
+		tw.Write([]byte("late"))
+		tw.Close()
+		compress.Close()
+	}
+
+	var plain, gz bytes.Buffer
+	tarball(nopWriteCloser{&plain})
+	tarball(gzip.NewWriter(&gz))
+
+	max := archive.MaxEntryScan
+	archive.MaxEntryScan = 2048
+	defer func() { archive.MaxEntryScan = max }()
+
+	r := bytes.NewReader(gz.Bytes())
+	m, err := archive.List(r, r.Size())
+	a.NoError(err)
+	a.True(m.Entries[0].Downloadable)
+	a.False(m.Entries[1].Downloadable)
+
+	// The entry starts within the limit, it can be as big as it likes.
+	_, rc, err := archive.Open(r, r.Size(), "big.txt")
+	a.NoError(err)
+	data, _ := ioutil.ReadAll(rc)
+	rc.Close()
+	a.Equal(big, data)
+
+	_, _, err = archive.Open(r, r.Size(), "late.txt")
+	a.Equal(archive.ErrNotDownloadable, err)
+
+	_, _, err = archive.Open(r, r.Size(), "missing.txt")
+	a.Equal(archive.ErrNotDownloadable, err)
+
+	// Nothing is decompressed to get through a plain tarball.
+	r = bytes.NewReader(plain.Bytes())
+	m, err = archive.List(r, r.Size())
+	a.NoError(err)
+	a.True(m.Entries[1].Downloadable)
+
+	_, rc, err = archive.Open(r, r.Size(), "late.txt")
+	a.NoError(err)
+	data, _ = ioutil.ReadAll(rc)
+	rc.Close()
+	a.Equal("late", string(data))
+}
+
+func TestListTruncated(t *testing.T) {
+	a := assert.New(t)
+
+	files := map[string][]byte{}
+	for _, name := range []string{"a", "b", "c"} {
+		files[name] = []byte(name)
+	}
+	r := makeZip(t, files)
+
+	max := archive.MaxEntries
+	archive.MaxEntries = 2
+	defer func() { archive.MaxEntries = max }()
+
+	m, err := archive.List(r, r.Size())
+	a.NoError(err)
+	a.Len(m.Entries, 2)
+	a.True(m.Truncated)
+	a.Equal(int64(3), m.Size)
+}
+
+func TestNotArchive(t *testing.T) {
+	t.Parallel()
+
+	r := bytes.NewReader([]byte("just some text"))
+	_, err := archive.List(r, r.Size())
+	assert.Equal(t, archive.ErrNotArchive, err)
+
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	gw.Write([]byte("compressed text, not a tarball"))
+	gw.Close()
+
+	r = bytes.NewReader(buf.Bytes())
+	_, err = archive.List(r, r.Size())
+	assert.Equal(t, archive.ErrNotArchive, err)
+}
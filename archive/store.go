@@ -0,0 +1,56 @@
+package archive
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	"github.com/zqzca/back/db"
+)
+
+const loadManifestSQL = `
+	SELECT kind, entries, size, ratio, truncated
+	FROM archive_manifests
+	WHERE file_id = $1
+`
+
+const saveManifestSQL = `
+	INSERT INTO archive_manifests (file_id, kind, entries, size, ratio, truncated)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	ON CONFLICT (file_id) DO UPDATE SET
+	kind = EXCLUDED.kind, entries = EXCLUDED.entries, size = EXCLUDED.size,
+	ratio = EXCLUDED.ratio, truncated = EXCLUDED.truncated
+`
+
+// LoadManifest returns nil without an error when a file has none.
+func LoadManifest(ex db.Executor, fileID string) (*Manifest, error) {
+	var (
+		m       Manifest
+		entries []byte
+	)
+
+	err := ex.QueryRow(loadManifestSQL, fileID).Scan(&m.Kind, &entries, &m.Size, &m.Ratio, &m.Truncated)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to load manifest")
+	}
+
+	if err = json.Unmarshal(entries, &m.Entries); err != nil {
+		return nil, errors.Wrap(err, "Failed to decode manifest")
+	}
+
+	return &m, nil
+}
+
+// SaveManifest stores the manifest of a file, replacing any it had.
+func SaveManifest(ex db.Executor, fileID string, m *Manifest) error {
+	entries, err := json.Marshal(m.Entries)
+	if err != nil {
+		return err
+	}
+
+	_, err = ex.Exec(saveManifestSQL, fileID, m.Kind, entries, m.Size, m.Ratio, m.Truncated)
+	return errors.Wrap(err, "Failed to save manifest")
+}
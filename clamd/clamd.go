@@ -0,0 +1,175 @@
+// Package clamd scans uploads with a ClamAV daemon using its INSTREAM
+// command.
+package clamd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultTimeout bounds a whole scan, including sending the file.
+const DefaultTimeout = 5 * time.Minute
+
+// Size of the chunks a file is streamed in.
+const chunkSize = 64 << 10
+
+// ErrSizeLimit is returned when a file is bigger than clamd's StreamMaxLength.
+var ErrSizeLimit = errors.New("File is bigger than clamd accepts")
+
+// Verdict is what clamd made of a file. Unscanned files were too big to be
+// sent to clamd at all.
+type Verdict struct {
+	Infected  bool      `json:"infected"`
+	Unscanned bool      `json:"unscanned,omitempty"`
+	Signature string    `json:"signature,omitempty"`
+	ScannedAt time.Time `json:"scanned_at"`
+}
+
+// Client connects to clamd over TCP, eg. localhost:3310, or a unix socket.
+type Client struct {
+	Network string
+	Address string
+	Timeout time.Duration
+}
+
+// New makes a client for an address written as tcp://host:port,
+// unix:///path, host:port or /path.
+func New(addr string) *Client {
+	c := &Client{Network: "tcp", Address: addr, Timeout: DefaultTimeout}
+
+	switch {
+	case strings.HasPrefix(addr, "tcp://"):
+		c.Address = strings.TrimPrefix(addr, "tcp://")
+	case strings.HasPrefix(addr, "unix://"):
+		c.Network, c.Address = "unix", strings.TrimPrefix(addr, "unix://")
+	case strings.HasPrefix(addr, "/"):
+		c.Network = "unix"
+	}
+
+	return c
+}
+
+func (c *Client) dial() (net.Conn, error) {
+	conn, err := net.DialTimeout(c.Network, c.Address, c.Timeout)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to connect to clamd")
+	}
+
+	if c.Timeout > 0 {
+		conn.SetDeadline(time.Now().Add(c.Timeout))
+	}
+
+	return conn, nil
+}
+
+// Ping checks clamd is up.
+func (c *Client) Ping() error {
+	conn, err := c.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err = conn.Write([]byte("zPING\x00")); err != nil {
+		return errors.Wrap(err, "Failed to send PING")
+	}
+
+	reply, err := readReply(conn)
+	if err != nil {
+		return err
+	}
+
+	if reply != "PONG" {
+		return errors.Errorf("Unexpected reply from clamd: %q", reply)
+	}
+
+	return nil
+}
+
+// Scan streams r to clamd and waits for the verdict. An error means the file
+// wasn't scanned, not that it is infected.
+func (c *Client) Scan(r io.Reader) (*Verdict, error) {
+	conn, err := c.dial()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	werr := stream(conn, r)
+
+	// clamd answers and hangs up as soon as a file is over its limit, so a
+	// failed write might still have a reply waiting.
+	reply, err := readReply(conn)
+	if err != nil {
+		if werr != nil {
+			return nil, werr
+		}
+		return nil, err
+	}
+
+	return parseReply(reply)
+}
+
+func stream(w io.Writer, r io.Reader) error {
+	if _, err := w.Write([]byte("zINSTREAM\x00")); err != nil {
+		return errors.Wrap(err, "Failed to send INSTREAM")
+	}
+
+	buf := make([]byte, 4+chunkSize)
+	for {
+		n, err := io.ReadFull(r, buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf, uint32(n))
+			if _, werr := w.Write(buf[:4+n]); werr != nil {
+				return errors.Wrap(werr, "Failed to stream file to clamd")
+			}
+		}
+
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return errors.Wrap(err, "Failed to read file")
+		}
+	}
+
+	// A zero length chunk ends the stream.
+	_, err := w.Write([]byte{0, 0, 0, 0})
+	return errors.Wrap(err, "Failed to end stream")
+}
+
+// Replies end with a NUL since commands were sent with the z prefix.
+func readReply(r io.Reader) (string, error) {
+	reply, err := bufio.NewReader(r).ReadBytes(0)
+	if err != nil && (err != io.EOF || len(reply) == 0) {
+		return "", errors.Wrap(err, "Failed to read reply from clamd")
+	}
+
+	return string(bytes.TrimRight(reply, "\x00\n")), nil
+}
+
+// Replies look like "stream: OK", "stream: Eicar-Signature FOUND" or
+// "INSTREAM size limit exceeded. ERROR".
+func parseReply(reply string) (*Verdict, error) {
+	v := &Verdict{ScannedAt: time.Now().UTC()}
+
+	switch {
+	case strings.HasSuffix(reply, " FOUND"):
+		v.Infected = true
+		v.Signature = strings.TrimSuffix(strings.TrimPrefix(reply, "stream: "), " FOUND")
+		return v, nil
+	case strings.HasSuffix(reply, " OK"):
+		return v, nil
+	case strings.Contains(reply, "size limit exceeded"):
+		return nil, ErrSizeLimit
+	default:
+		return nil, errors.Errorf("clamd failed to scan: %s", reply)
+	}
+}
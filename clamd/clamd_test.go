@@ -0,0 +1,105 @@
+package clamd_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zqzca/back/clamd"
+	"github.com/zqzca/back/clamd/clamdtest"
+)
+
+func TestScanClean(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	c, done := clamdtest.Listen(t, 1<<20)
+	defer done()
+
+	// Bigger than a chunk so it is streamed in pieces.
+	v, err := c.Scan(bytes.NewReader(bytes.Repeat([]byte("harmless "), 20000)))
+	a.NoError(err)
+	if a.NotNil(v) {
+		a.False(v.Infected)
+		a.Empty(v.Signature)
+		a.False(v.ScannedAt.IsZero())
+	}
+}
+
+func TestScanInfected(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	c, done := clamdtest.Listen(t, 1<<20)
+	defer done()
+
+	v, err := c.Scan(strings.NewReader(clamdtest.Eicar))
+	a.NoError(err)
+	if a.NotNil(v) {
+		a.True(v.Infected)
+		a.Equal("Eicar-Signature", v.Signature)
+	}
+}
+
+func TestScanSizeLimit(t *testing.T) {
+	t.Parallel()
+
+	c, done := clamdtest.Listen(t, 1000)
+	defer done()
+
+	_, err := c.Scan(bytes.NewReader(make([]byte, 300000)))
+	assert.Equal(t, clamd.ErrSizeLimit, err)
+}
+
+func TestScanUnreachable(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	c, done := clamdtest.Listen(t, 1000)
+	done()
+
+	v, err := c.Scan(strings.NewReader(clamdtest.Eicar))
+	a.Error(err)
+	a.Nil(v)
+}
+
+func TestPingUnixSocket(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "clamd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "clamd.sock")
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go clamdtest.Serve(l, 1000)
+
+	assert.NoError(t, clamd.New("unix://"+path).Ping())
+}
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	c := clamd.New("tcp://clamav:3310")
+	a.Equal("tcp", c.Network)
+	a.Equal("clamav:3310", c.Address)
+
+	c = clamd.New("/run/clamav/clamd.ctl")
+	a.Equal("unix", c.Network)
+	a.Equal("/run/clamav/clamd.ctl", c.Address)
+
+	c = clamd.New("localhost:3310")
+	a.Equal("tcp", c.Network)
+}
@@ -0,0 +1,88 @@
+// Package clamdtest runs a fake clamd for tests.
+package clamdtest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/zqzca/back/clamd"
+)
+
+// Eicar is the standard antivirus test file, the fake finds nothing else.
+const Eicar = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+
+// Serve answers PING and INSTREAM on l like clamd does, finding Eicar and
+// refusing anything over limit bytes, until l is closed.
+func Serve(l net.Listener, limit int) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+
+		go handle(conn, limit)
+	}
+}
+
+// Listen serves on a local TCP port and returns a client for it along with a
+// func that stops the server.
+func Listen(t testing.TB, limit int) (*clamd.Client, func()) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go Serve(l, limit)
+	return clamd.New(l.Addr().String()), func() { l.Close() }
+}
+
+func handle(conn net.Conn, limit int) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	cmd, err := r.ReadString(0)
+	if err != nil {
+		return
+	}
+
+	switch cmd {
+	case "zPING\x00":
+		conn.Write([]byte("PONG\x00"))
+		return
+	case "zINSTREAM\x00":
+	default:
+		conn.Write([]byte("UNKNOWN COMMAND\x00"))
+		return
+	}
+
+	var data bytes.Buffer
+	for {
+		var size uint32
+		if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+			return
+		}
+		if size == 0 {
+			break
+		}
+
+		if data.Len()+int(size) > limit {
+			conn.Write([]byte("INSTREAM size limit exceeded. ERROR\x00"))
+			return
+		}
+
+		if _, err := io.CopyN(&data, r, int64(size)); err != nil {
+			return
+		}
+	}
+
+	if strings.Contains(data.String(), Eicar) {
+		conn.Write([]byte("stream: Eicar-Signature FOUND\x00"))
+		return
+	}
+	conn.Write([]byte("stream: OK\x00"))
+}
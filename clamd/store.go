@@ -0,0 +1,49 @@
+package clamd
+
+import (
+	"database/sql"
+
+	"github.com/pkg/errors"
+	"github.com/zqzca/back/db"
+)
+
+const loadVerdictSQL = `
+	SELECT infected, unscanned, signature, scanned_at
+	FROM scan_results
+	WHERE file_id = $1
+`
+
+const saveVerdictSQL = `
+	INSERT INTO scan_results (file_id, infected, unscanned, signature, scanned_at)
+	VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT (file_id) DO UPDATE SET
+	infected = EXCLUDED.infected, unscanned = EXCLUDED.unscanned,
+	signature = EXCLUDED.signature, scanned_at = EXCLUDED.scanned_at
+`
+
+// LoadVerdict returns nil without an error when a file wasn't scanned.
+func LoadVerdict(ex db.Executor, fileID string) (*Verdict, error) {
+	var (
+		v         Verdict
+		signature sql.NullString
+	)
+
+	err := ex.QueryRow(loadVerdictSQL, fileID).Scan(&v.Infected, &v.Unscanned, &signature, &v.ScannedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to load scan result")
+	}
+
+	v.Signature = signature.String
+	return &v, nil
+}
+
+// SaveVerdict stores the latest scan of a file.
+func SaveVerdict(ex db.Executor, fileID string, v *Verdict) error {
+	signature := sql.NullString{String: v.Signature, Valid: v.Signature != ""}
+
+	_, err := ex.Exec(saveVerdictSQL, fileID, v.Infected, v.Unscanned, signature, v.ScannedAt)
+	return errors.Wrap(err, "Failed to save scan result")
+}
@@ -0,0 +1,69 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/vattle/sqlboiler/boil"
+	"github.com/vattle/sqlboiler/queries/qm"
+	"github.com/zqzca/back/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type userKey struct{}
+
+// Authenticate checks HTTP basic credentials against the users table and
+// stores the user on the request. Requests without credentials carry on
+// anonymously, bad credentials are rejected.
+func Authenticate(ex boil.Executor) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			username, password, ok := r.BasicAuth()
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			user, err := models.Users(ex, qm.Where("username=$1", username)).One()
+			if err != nil || user.Banned || bcrypt.CompareHashAndPassword([]byte(user.Hash), []byte(password)) != nil {
+				unauthorized(w)
+				return
+			}
+
+			next.ServeHTTP(w, WithUser(r, user))
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// RequireUser rejects requests that weren't authenticated.
+func RequireUser(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		if CurrentUser(r) == nil {
+			unauthorized(w)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+
+	return http.HandlerFunc(fn)
+}
+
+// CurrentUser returns the authenticated user or nil.
+func CurrentUser(r *http.Request) *models.User {
+	user, _ := r.Context().Value(userKey{}).(*models.User)
+	return user
+}
+
+// WithUser stores an authenticated user on the request context.
+func WithUser(r *http.Request, user *models.User) *http.Request {
+	ctx := context.WithValue(r.Context(), userKey{}, user)
+	return r.WithContext(ctx)
+}
+
+func unauthorized(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Basic realm="zqz"`)
+	http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+}
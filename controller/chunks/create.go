@@ -7,9 +7,9 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/zqzca/back/jobs"
 	"github.com/zqzca/back/models"
 	"github.com/zqzca/back/processors"
-	"github.com/zqzca/back/serializer"
 
 	"github.com/vattle/sqlboiler/queries/qm"
 )
@@ -128,29 +128,18 @@ func (c Controller) checkFinished(f *models.File) {
 		return
 	}
 
-	go func() {
-		c.wsFileIDsLock.RLock()
-		wsID := c.wsFileIDs[f.ID]
-		c.wsFileIDsLock.RUnlock()
+	c.wsFileIDsLock.RLock()
+	wsID := c.wsFileIDs[f.ID]
+	c.wsFileIDsLock.RUnlock()
 
-		err = processors.CompleteFile(c.Dependencies, *f)
-
-		if err != nil {
-			c.Error("Failed to finish file", "error", err, "name", f.Name, "id", f.ID)
-			return
-		}
-
-		if len(wsID) > 0 {
-			c.Info("Sending WS msg", "ws", wsID)
-			c.WS.WriteClient(wsID, "file:completed", f)
-			de := serializer.NewDashboardItemFromFile(c.DB, f)
-			c.WS.Broadcast("file:added", de)
-		} else {
-			c.Info("No WS ID")
-		}
+	if len(wsID) == 0 {
+		c.Info("No WS ID")
+	}
 
-		c.Info("Finished File", "name", f.Name, "id", f.ID)
-	}()
+	err = c.Jobs.Enqueue(jobs.CompleteFile, f.ID, processors.UploadArgs(wsID))
+	if err != nil {
+		c.Error("Failed to queue file", "error", err, "name", f.Name, "id", f.ID)
+	}
 }
 
 func parseRequest(r *http.Request) *upload {
@@ -7,9 +7,11 @@ import (
 	"math"
 	"net/http"
 
+	"github.com/lib/pq"
 	"github.com/pressly/chi/render"
 	"github.com/zqzca/back/db"
 	"github.com/zqzca/back/dependencies"
+	"github.com/zqzca/back/lib"
 	"github.com/zqzca/back/serializer"
 )
 
@@ -26,16 +28,26 @@ type dashboardData struct {
 
 const paginationSQL = `
 	SELECT
-	f.name, t.id, f.slug, f.created_at
+	f.name, t.id, a.id, f.blurhash, f.colors, f.slug, f.created_at
 	FROM files AS f
-	LEFT JOIN thumbnails as t
-	ON t.file_id = f.id
+	LEFT JOIN LATERAL (
+		SELECT id FROM thumbnails
+		WHERE file_id = f.id AND format = 'jpeg' AND kind IN ('square', 'fit')
+		ORDER BY kind = 'square' DESC, width ASC
+		LIMIT 1
+	) AS t ON true
+	LEFT JOIN LATERAL (
+		SELECT id FROM thumbnails
+		WHERE file_id = f.id AND kind = 'animated'
+		LIMIT 1
+	) AS a ON true
+	WHERE f.state <> $3
 	ORDER BY f.created_at DESC
 	OFFSET $1
 	LIMIT $2
 `
 
-const totalPagesSQL = `SELECT count(*) FROM files`
+const totalPagesSQL = `SELECT count(*) FROM files WHERE state <> $1`
 
 func (c Controller) LoaderIO(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, "loaderio-be3da1f0d6cfb2791e752a0ecc4995b2")
@@ -70,7 +82,7 @@ func (c Controller) Index(w http.ResponseWriter, r *http.Request) {
 func totalPages(ex db.Executor, perPage int) int {
 	var count int
 
-	err := ex.QueryRow(totalPagesSQL).Scan(&count)
+	err := ex.QueryRow(totalPagesSQL, lib.FileQuarantined).Scan(&count)
 
 	if err != nil {
 		return 0
@@ -86,7 +98,7 @@ func pagination(ex db.Executor, page int, perPage int) (*[]serializer.DashboardI
 
 	offset := perPage * page
 
-	if rows, err = ex.Query(paginationSQL, offset, perPage); err != nil {
+	if rows, err = ex.Query(paginationSQL, offset, perPage, lib.FileQuarantined); err != nil {
 		return &entries, err
 	}
 	defer rows.Close()
@@ -95,7 +107,8 @@ func pagination(ex db.Executor, page int, perPage int) (*[]serializer.DashboardI
 		var e serializer.DashboardItem
 
 		err = rows.Scan(
-			&e.Name, &e.ThumbnailID, &e.Slug, &e.CreatedAt,
+			&e.Name, &e.ThumbnailID, &e.AnimatedThumbnailID,
+			&e.BlurHash, pq.Array(&e.Colors), &e.Slug, &e.CreatedAt,
 		)
 
 		if err != nil {
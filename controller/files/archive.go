@@ -0,0 +1,97 @@
+package files
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+
+	"github.com/pressly/chi"
+	"github.com/pressly/chi/render"
+	"github.com/vattle/sqlboiler/queries/qm"
+	"github.com/zqzca/back/archive"
+	"github.com/zqzca/back/lib"
+	"github.com/zqzca/back/models"
+)
+
+// Entries lists what is inside a zip or tarball.
+func (f Controller) Entries(w http.ResponseWriter, r *http.Request) {
+	slug := chi.URLParam(r, "slug")
+	file, err := models.Files(f.DB, qm.Where("slug=$1", slug)).One()
+	if err != nil || lib.Quarantined(file) {
+		http.Error(w, "File not found", 404)
+		return
+	}
+
+	m, err := archive.LoadManifest(f.DB, file.ID)
+	if err != nil {
+		f.Error("Failed to load manifest", "slug", slug, "err", err)
+		http.Error(w, http.StatusText(500), 500)
+		return
+	}
+
+	if m == nil {
+		http.Error(w, "Not an archive", 404)
+		return
+	}
+
+	render.JSON(w, r, m)
+}
+
+// Entry sends a single file out of an archive. Directories, links, entries
+// whose path leaves the archive and anything that looks like a zip bomb
+// can't be downloaded.
+func (f Controller) Entry(w http.ResponseWriter, r *http.Request) {
+	slug := chi.URLParam(r, "slug")
+	name := chi.URLParam(r, "*")
+
+	file, err := models.Files(f.DB, qm.Where("slug=$1", slug)).One()
+	if err != nil || !lib.Servable(file) {
+		http.Error(w, "File not found", 404)
+		return
+	}
+
+	data, err := lib.OpenStored(f.DB, f.Fs, file)
+	if err != nil {
+		http.Error(w, "File not found", 404)
+		return
+	}
+	defer data.Close()
+
+	entry, rc, err := archive.Open(data, data.Size(), name)
+	switch err {
+	case nil:
+	case archive.ErrNotArchive, archive.ErrNotFound:
+		http.Error(w, "Entry not found", 404)
+		return
+	case archive.ErrNotDownloadable:
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	default:
+		f.Error("Failed to open archive entry", "slug", slug, "entry", name, "err", err)
+		http.Error(w, http.StatusText(500), 500)
+		return
+	}
+	defer rc.Close()
+
+	contentType := mime.TypeByExtension(path.Ext(entry.Path))
+	if len(contentType) == 0 {
+		contentType = "application/octet-stream"
+	}
+
+	disposition := "inline"
+	if lib.ActiveType(contentType) {
+		disposition = "attachment"
+		w.Header().Set("Content-Security-Policy", "sandbox; default-src 'none'")
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Length", strconv.FormatInt(entry.Size, 10))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": path.Base(entry.Path)}))
+
+	if _, err = io.Copy(w, rc); err != nil {
+		f.Warn("Failed to send archive entry", "slug", slug, "entry", name, "err", err)
+	}
+}
@@ -5,4 +5,12 @@ import "github.com/zqzca/back/dependencies"
 // Controller carries dependencies
 type Controller struct {
 	dependencies.Dependencies
+
+	// Used to build absolute links to file data and thumbnails. ContentHost
+	// is empty when they are served from the same host as the app.
+	Scheme      string
+	ContentHost string
+
+	// How many bytes of a text file the preview page renders.
+	ViewerLimit int64
 }
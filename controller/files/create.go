@@ -3,12 +3,16 @@ package files
 import (
 	"net/http"
 
+	"github.com/pkg/errors"
 	"github.com/pressly/chi/render"
 	"github.com/vattle/sqlboiler/queries/qm"
+	"github.com/zqzca/back/controller"
 	"github.com/zqzca/back/lib"
 	"github.com/zqzca/back/models"
+	"github.com/zqzca/back/processors"
 
 	"github.com/vattle/sqlboiler/boil"
+	null "gopkg.in/nullbio/null.v5"
 )
 
 func fileExistsWithHash(ex boil.Executor, hash string) (bool, error) {
@@ -21,21 +25,95 @@ func fileExistsWithHash(ex boil.Executor, hash string) (bool, error) {
 	return count > 0, nil
 }
 
+// How many random slugs are tried before giving up.
+const slugAttempts = 5
+
+var errSlugTaken = errors.New("Slug Taken")
+
+func slugExists(ex boil.Executor, slug string) (bool, error) {
+	count, err := models.Files(ex, qm.Where("slug=$1", slug)).Count()
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// insertWithSlug inserts file with a freshly generated slug, retrying on
+// collisions. A custom slug is only tried once.
+func insertWithSlug(ex boil.Executor, file *models.File, custom bool) error {
+	for i := 0; i < slugAttempts; i++ {
+		if !custom {
+			slug, err := lib.Slugs.Generate()
+			if err != nil {
+				return err
+			}
+			file.Slug = slug
+		}
+
+		exists, err := slugExists(ex, file.Slug)
+		if err != nil {
+			return err
+		}
+
+		if !exists {
+			err = file.Insert(ex)
+			// Lost a race with another upload for the same slug.
+			if !lib.UniqueViolation(err, "files_slug_key") {
+				return err
+			}
+		}
+
+		if custom {
+			return errSlugTaken
+		}
+	}
+
+	return errors.New("Failed to find a free slug")
+}
+
+// createRequest is everything a client gets to say about a new upload, the
+// rest of the file is ours to fill in.
+type createRequest struct {
+	Name      string    `json:"name"`
+	Size      int       `json:"size"`
+	Hash      string    `json:"hash"`
+	NumChunks int       `json:"num_chunks"`
+	Type      string    `json:"type"`
+	Slug      string    `json:"slug"`
+	Privacy   null.Bool `json:"privacy"`
+}
+
 // Create creates a file container in the database.
 func (f Controller) Create(w http.ResponseWriter, r *http.Request) {
-	file := &models.File{}
+	req := &createRequest{}
 
-	if err := render.Bind(r.Body, file); err != nil {
+	if err := render.Bind(r.Body, req); err != nil {
 		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
 
 		return
 	}
 
+	file := &models.File{
+		Name:      req.Name,
+		Size:      req.Size,
+		Hash:      req.Hash,
+		NumChunks: req.NumChunks,
+		Type:      req.Type,
+		Slug:      req.Slug,
+		Privacy:   req.Privacy,
+	}
+
 	if file.NumChunks < 1 {
 		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
 		return
 	}
 
+	if processors.ScanRejects(int64(file.Size)) {
+		http.Error(w, "File is too big to scan", http.StatusRequestEntityTooLarge)
+		return
+	}
+
 	exists, err := fileExistsWithHash(f.DB, file.Hash)
 	if err != nil {
 		http.Error(w, http.StatusText(500), 500)
@@ -49,10 +127,38 @@ func (f Controller) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Uploads belong to whoever is signed in, never to who the body says.
+	user := controller.CurrentUser(r)
+	if user != nil {
+		file.UserID = null.StringFrom(user.ID)
+	}
+
+	// Only signed in users get to pick their slug.
+	custom := len(file.Slug) > 0
+	if custom {
+		if user == nil {
+			http.Error(w, "Sign in to choose a slug", http.StatusUnauthorized)
+			return
+		}
+
+		if err := lib.ValidSlug(file.Slug); err != nil {
+			render.Status(r, http.StatusUnprocessableEntity)
+			render.JSON(w, r, map[string]string{"error": err.Error()})
+			return
+		}
+	}
+
 	f.Debug("file doesnt exist with hash", "hash", file.Hash)
 	file.State = lib.FileIncomplete
 
-	if err := file.Insert(f.DB); err != nil {
+	if err := insertWithSlug(f.DB, file, custom); err != nil {
+		if err == errSlugTaken {
+			render.Status(r, http.StatusConflict)
+			render.JSON(w, r, map[string]string{"error": err.Error()})
+			return
+		}
+
+		f.Error("Failed to create file", "err", err)
 		http.Error(w, http.StatusText(500), 500)
 		return
 	}
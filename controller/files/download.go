@@ -1,11 +1,10 @@
 package files
 
 import (
-	"fmt"
-	"io"
+	"mime"
 	"net/http"
-	"os"
 	"strings"
+	"time"
 
 	"github.com/pressly/chi"
 	"github.com/pressly/chi/render"
@@ -15,7 +14,8 @@ import (
 	"github.com/vattle/sqlboiler/queries/qm"
 )
 
-// Download sends the entire file to the client.
+// Download sends the entire file to the client, once the processors are done
+// with it.
 func (f Controller) Download(w http.ResponseWriter, r *http.Request) {
 	slug := chi.URLParam(r, "slug")
 	if len(slug) == 0 {
@@ -25,31 +25,40 @@ func (f Controller) Download(w http.ResponseWriter, r *http.Request) {
 	}
 
 	file, err := models.Files(f.DB, qm.Where("slug=$1", slug)).One()
-	if err != nil {
+	if err != nil || !lib.Servable(file) {
 		render.Status(r, http.StatusNotFound)
 		render.PlainText(w, r, "")
 		return
 	}
 
 	// Build Etag
-	etag := file.Hash
-	w.Header().Set("Content-Type", file.Type)
+	stored := lib.StoredHash(file)
+	etag := stored
+	contentType := servedType(file)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
 	w.Header().Set("Etag", etag)
 	w.Header().Set("Cache-Control", "no-cache")
-	disposition := fmt.Sprintf("inline; filename=%s", file.Name)
-	w.Header().Set("Content-Disposition", disposition)
+
+	disposition := "inline"
+	if lib.ActiveType(contentType) {
+		// Anything that can run script on our origin is only ever a download.
+		disposition = "attachment"
+		w.Header().Set("Content-Security-Policy", "sandbox; default-src 'none'")
+	}
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": file.Name}))
 
 	// If set just return early.
 	if match := r.Header.Get("If-None-Match"); match != "" {
 		if strings.Contains(match, etag) {
-			go lib.TrackDownload(f.DB, file.ID, r, true)
+			go lib.TrackDownload(f.DB, file.ID, r, lib.Transfer{CacheHit: true, Completed: true})
 			render.Status(r, http.StatusNotModified)
 			render.PlainText(w, r, "")
 			return
 		}
 	}
 
-	data, err := os.Open(lib.LocalPath(file.Hash))
+	data, err := lib.OpenStored(f.DB, f.Fs, file)
 	if err != nil {
 		render.Status(r, http.StatusNotModified)
 		render.PlainText(w, r, "")
@@ -57,9 +66,38 @@ func (f Controller) Download(w http.ResponseWriter, r *http.Request) {
 	}
 	defer data.Close()
 
-	go lib.TrackDownload(f.DB, file.ID, r, false)
+	// ServeContent answers Range requests. A download is only complete once
+	// every byte was sent, a sanitized copy is smaller than what was uploaded.
+	cw := &countingWriter{ResponseWriter: w}
+	http.ServeContent(cw, r, file.Name, time.Time{}, data)
+
+	go lib.TrackDownload(f.DB, file.ID, r, lib.Transfer{
+		Bytes:     cw.n,
+		Completed: cw.n == data.Size(),
+	})
+}
+
+type countingWriter struct {
+	http.ResponseWriter
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.ResponseWriter.Write(p)
+	c.n += int64(n)
+	return n, err
+}
 
-	if _, err := io.Copy(w, data); err != nil {
-		http.Error(w, "Failed to write response", 500)
+// servedType is the sniffed type, or the declared one for files that haven't
+// been processed yet.
+func servedType(file *models.File) string {
+	if file.DetectedType.Valid {
+		return file.DetectedType.String
 	}
+
+	if len(file.Type) == 0 {
+		return "application/octet-stream"
+	}
+
+	return file.Type
 }
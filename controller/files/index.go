@@ -5,7 +5,9 @@ import (
 
 	"github.com/pressly/chi/render"
 	"github.com/vattle/sqlboiler/queries/qm"
+	"github.com/zqzca/back/lib"
 	"github.com/zqzca/back/models"
+	"github.com/zqzca/back/serializer"
 )
 
 //Index returns a list of files
@@ -16,6 +18,7 @@ func (c Controller) Index(w http.ResponseWriter, r *http.Request) {
 
 	files, err := models.Files(
 		c.DB,
+		qm.Where("state <> $1", lib.FileQuarantined),
 		qm.OrderBy("created_at desc"),
 		qm.Limit(perPage),
 		qm.Offset(page*perPage),
@@ -26,5 +29,10 @@ func (c Controller) Index(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	render.JSON(w, r, files)
+	out := make([]serializer.File, len(files))
+	for i, f := range files {
+		out[i] = serializer.ForFile(c.DB, f)
+	}
+
+	render.JSON(w, r, out)
 }
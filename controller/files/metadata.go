@@ -0,0 +1,91 @@
+package files
+
+import (
+	"net/http"
+
+	"github.com/pressly/chi"
+	"github.com/pressly/chi/render"
+	"github.com/vattle/sqlboiler/queries/qm"
+	"github.com/zqzca/back/controller"
+	"github.com/zqzca/back/lib"
+	"github.com/zqzca/back/models"
+)
+
+type hideRequest struct {
+	Hidden []string `json:"hidden"`
+}
+
+// Metadata returns the EXIF metadata of an image. The owner sees everything
+// along with what is hidden, everyone else only sees what isn't.
+func (f Controller) Metadata(w http.ResponseWriter, r *http.Request) {
+	file, m, ok := f.loadMetadata(w, r)
+	if !ok {
+		return
+	}
+
+	if !owns(r, file) {
+		redacted := m.Redacted()
+		m = &redacted
+	}
+
+	render.JSON(w, r, m)
+}
+
+// HideMetadata lets the owner of a file pick which groups of metadata are
+// hidden from everyone else.
+func (f Controller) HideMetadata(w http.ResponseWriter, r *http.Request) {
+	file, m, ok := f.loadMetadata(w, r)
+	if !ok {
+		return
+	}
+
+	if !owns(r, file) {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	req := &hideRequest{}
+	if err := render.Bind(r.Body, req); err != nil || !lib.ValidMetadataGroups(req.Hidden) {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	if err := lib.HideImageMetadata(f.DB, file.ID, req.Hidden); err != nil {
+		f.Error("Failed to hide metadata", "slug", file.Slug, "err", err)
+		http.Error(w, http.StatusText(500), 500)
+		return
+	}
+
+	m.Hidden = req.Hidden
+	render.JSON(w, r, m)
+}
+
+func (f Controller) loadMetadata(w http.ResponseWriter, r *http.Request) (*models.File, *lib.ImageMetadata, bool) {
+	slug := chi.URLParam(r, "slug")
+	file, err := models.Files(f.DB, qm.Where("slug=$1", slug)).One()
+	if err != nil || lib.Quarantined(file) {
+		http.Error(w, "File not found", 404)
+		return nil, nil, false
+	}
+
+	m, err := lib.LoadImageMetadata(f.DB, file.ID)
+	if err != nil {
+		f.Error("Failed to load metadata", "slug", slug, "err", err)
+		http.Error(w, http.StatusText(500), 500)
+		return nil, nil, false
+	}
+
+	if m == nil {
+		http.Error(w, "No metadata", 404)
+		return nil, nil, false
+	}
+
+	m.Width, m.Height = file.Width.Int, file.Height.Int
+	return file, m, true
+}
+
+// owns reports whether the signed in user uploaded file.
+func owns(r *http.Request, file *models.File) bool {
+	user := controller.CurrentUser(r)
+	return user != nil && file.UserID.Valid && file.UserID.String == user.ID
+}
@@ -0,0 +1,129 @@
+package files
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pressly/chi/render"
+	"github.com/vattle/sqlboiler/queries/qm"
+	"github.com/zqzca/back/lib"
+	"github.com/zqzca/back/models"
+	"github.com/zqzca/back/serializer"
+)
+
+// Paths we hand out for a single file.
+var oembedPath = regexp.MustCompile(`^/(?:d|api/v1/files)/([A-Za-z0-9_-]+)/?$`)
+
+// Size of the embed when we don't know the dimensions of the media.
+const (
+	defaultVideoWidth  = 640
+	defaultVideoHeight = 360
+	audioWidth         = 300
+	audioHeight        = 54
+)
+
+// OEmbed implements an oEmbed provider for file links.
+func (f Controller) OEmbed(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	format := q.Get("format")
+	if len(format) == 0 {
+		format = "json"
+	}
+
+	if format != "json" && format != "xml" {
+		http.Error(w, "Unsupported format", http.StatusNotImplemented)
+		return
+	}
+
+	u, err := url.Parse(q.Get("url"))
+	if err != nil || !f.ownHost(r, u.Host) {
+		http.Error(w, "File not found", 404)
+		return
+	}
+
+	m := oembedPath.FindStringSubmatch(u.Path)
+	if m == nil {
+		http.Error(w, "File not found", 404)
+		return
+	}
+
+	file, err := models.Files(f.DB, qm.Where("slug=$1", m[1])).One()
+	if err != nil || lib.Quarantined(file) {
+		http.Error(w, "File not found", 404)
+		return
+	}
+
+	maxWidth, _ := strconv.Atoi(q.Get("maxwidth"))
+	maxHeight, _ := strconv.Atoi(q.Get("maxheight"))
+	o := f.oembedFor(r, file, maxWidth, maxHeight)
+
+	if format == "xml" {
+		render.XML(w, r, o)
+		return
+	}
+
+	render.JSON(w, r, o)
+}
+
+func (f Controller) ownHost(r *http.Request, host string) bool {
+	host = strings.ToLower(host)
+	return host == strings.ToLower(r.Host) ||
+		(len(f.ContentHost) > 0 && host == strings.ToLower(f.ContentHost))
+}
+
+func (f Controller) oembedFor(r *http.Request, file *models.File, maxWidth, maxHeight int) serializer.OEmbed {
+	o := serializer.OEmbed{
+		Type:         "link",
+		Version:      "1.0",
+		Title:        file.Name,
+		ProviderName: "zqz.ca",
+		ProviderURL:  f.appURL(r),
+	}
+
+	if thumb := f.thumbnail(file.ID, lib.ThumbnailFit, 400); thumb != nil {
+		o.ThumbnailURL = f.contentURL(r) + "/api/v1/thumbnails/" + thumb.ID
+		o.ThumbnailWidth = thumb.Width
+		o.ThumbnailHeight = thumb.Height
+	}
+
+	contentType := servedType(file)
+	if lib.ActiveType(contentType) {
+		return o
+	}
+
+	raw := html.EscapeString(f.RawURL(r, file))
+
+	switch strings.Split(lib.MediaType(contentType), "/")[0] {
+	case "image":
+		if !file.Width.Valid || !file.Height.Valid {
+			return o
+		}
+
+		o.Type = "photo"
+		o.URL = f.RawURL(r, file)
+		o.Width = file.Width.Int
+		o.Height = file.Height.Int
+		o.Fit(maxWidth, maxHeight)
+	case "video":
+		o.Type = "video"
+		o.Width, o.Height = defaultVideoWidth, defaultVideoHeight
+		if file.Width.Valid && file.Height.Valid {
+			o.Width, o.Height = file.Width.Int, file.Height.Int
+		}
+		o.Fit(maxWidth, maxHeight)
+		o.HTML = fmt.Sprintf(`<video src="%s" width="%d" height="%d" controls></video>`, raw, o.Width, o.Height)
+	case "audio":
+		o.Type = "rich"
+		o.Width, o.Height = audioWidth, audioHeight
+		o.Fit(maxWidth, maxHeight)
+		o.HTML = fmt.Sprintf(`<audio src="%s" style="width:%dpx" controls></audio>`, raw, o.Width)
+	}
+
+	return o
+}
@@ -0,0 +1,330 @@
+package files
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pressly/chi"
+	"github.com/pressly/chi/render"
+	"github.com/vattle/sqlboiler/queries/qm"
+	"github.com/zqzca/back/archive"
+	"github.com/zqzca/back/lib"
+	"github.com/zqzca/back/models"
+	"github.com/zqzca/back/serializer"
+	"github.com/zqzca/back/viewer"
+)
+
+type previewData struct {
+	Name      string
+	Type      string
+	Kind      string
+	Size      string
+	Downloads int
+	PageURL   string
+	RawURL    string
+	ThumbURL  string
+	CardType  string
+	OEmbedURL string
+	Text      *viewer.Document
+	Archive   *archivePreview
+}
+
+// Only the start of a big archive is listed on the page.
+const previewEntries = 1000
+
+type archivePreview struct {
+	Kind      string
+	Size      string
+	Entries   []archiveRow
+	Truncated bool
+}
+
+type archiveRow struct {
+	Path     string
+	Size     string
+	Modified string
+	Ratio    string
+	URL      string
+}
+
+var previewTemplate = template.Must(template.New("File Preview").Parse(`<!DOCTYPE HTML>
+<html>
+  <head>
+    <meta http-equiv='content-type' content='text/html; charset=utf-8'>
+    <meta name="viewport" content="width=device-width">
+    <title>{{ .Name }} - zqz.ca</title>
+    <meta property="og:site_name" content="zqz.ca">
+    <meta property="og:title" content="{{ .Name }}">
+    <meta property="og:url" content="{{ .PageURL }}">
+    <meta property="og:description" content="{{ .Size }}, downloaded {{ .Downloads }} times">
+    {{- with .ThumbURL }}
+    <meta property="og:image" content="{{ . }}">
+    {{- end }}
+    {{- if eq .Kind "image" }}
+    <meta property="og:type" content="website">
+    {{- else if eq .Kind "video" }}
+    <meta property="og:type" content="video.other">
+    <meta property="og:video" content="{{ .RawURL }}">
+    <meta property="og:video:type" content="{{ .Type }}">
+    {{- else if eq .Kind "audio" }}
+    <meta property="og:type" content="music.song">
+    <meta property="og:audio" content="{{ .RawURL }}">
+    <meta property="og:audio:type" content="{{ .Type }}">
+    {{- else }}
+    <meta property="og:type" content="website">
+    {{- end }}
+    <link rel="alternate" type="application/json+oembed" href="{{ .OEmbedURL }}&amp;format=json" title="{{ .Name }}">
+    <link rel="alternate" type="text/xml+oembed" href="{{ .OEmbedURL }}&amp;format=xml" title="{{ .Name }}">
+    <meta name="twitter:card" content="{{ .CardType }}">
+    <meta name="twitter:title" content="{{ .Name }}">
+    {{- with .ThumbURL }}
+    <meta name="twitter:image" content="{{ . }}">
+    {{- end }}
+    <style>
+      body { font-family: sans-serif; margin: 2em auto; max-width: 60em; padding: 0 1em; }
+      img, video { max-width: 100%; }
+      .meta { color: #666; }
+      .viewer { overflow-x: auto; }
+      .viewer pre { margin: 0; }
+      .viewer a { color: inherit; text-decoration: none; }
+      .archive { border-collapse: collapse; width: 100%; }
+      .archive td, .archive th { padding: 0.2em 0.5em; text-align: left; }
+      .archive td + td { color: #666; white-space: nowrap; }
+    </style>
+    {{- with .Text }}
+    <style>{{ .CSS }}</style>
+    {{- end }}
+  </head>
+  <body>
+    <h1>{{ .Name }}</h1>
+    {{- if eq .Kind "image" }}
+    <img src="{{ .RawURL }}" alt="{{ .Name }}">
+    {{- else if eq .Kind "video" }}
+    <video src="{{ .RawURL }}" controls></video>
+    {{- else if eq .Kind "audio" }}
+    <audio src="{{ .RawURL }}" controls></audio>
+    {{- end }}
+    <p class="meta">{{ .Type }} &middot; {{ .Size }} &middot; {{ .Downloads }} downloads</p>
+    <p><a href="{{ .RawURL }}" download>Download</a>{{ if .Text }} &middot; <a href="{{ .RawURL }}">Raw</a>{{ end }}</p>
+    {{- with .Text }}
+    {{- if .Truncated }}
+    <p class="meta">This file is too big to show in full. Download it to see the rest.</p>
+    {{- end }}
+    <div class="viewer">{{ .HTML }}</div>
+    {{- end }}
+    {{- with .Archive }}
+    <h2>Contents</h2>
+    <p class="meta">{{ .Kind }} &middot; {{ .Size }} unpacked</p>
+    <table class="archive">
+      <tr><th>Path</th><th>Size</th><th>Modified</th><th>Ratio</th></tr>
+      {{- range .Entries }}
+      <tr>
+        <td>{{ if .URL }}<a href="{{ .URL }}">{{ .Path }}</a>{{ else }}{{ .Path }}{{ end }}</td>
+        <td>{{ .Size }}</td>
+        <td>{{ .Modified }}</td>
+        <td>{{ .Ratio }}</td>
+      </tr>
+      {{- end }}
+    </table>
+    {{- if .Truncated }}
+    <p class="meta">Only part of this archive is listed.</p>
+    {{- end }}
+    {{- end }}
+  </body>
+</html>`))
+
+// Preview renders an HTML page with OpenGraph and Twitter card tags for
+// browsers and link unfurlers. Everything else is handed to raw.
+func (f Controller) Preview(raw http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept, User-Agent")
+
+		if !lib.WantsPreview(r) {
+			raw(w, r)
+			return
+		}
+
+		slug := chi.URLParam(r, "slug")
+		file, err := models.Files(f.DB, qm.Where("slug=$1", slug)).One()
+		if err != nil || !lib.Servable(file) {
+			http.Error(w, "File not found", 404)
+			return
+		}
+
+		var out bytes.Buffer
+		if err = previewTemplate.Execute(&out, f.previewData(r, file)); err != nil {
+			f.Error("Failed to render preview", "err", err)
+			http.Error(w, http.StatusText(500), 500)
+			return
+		}
+
+		render.HTML(w, r, out.String())
+	}
+}
+
+func (f Controller) previewData(r *http.Request, file *models.File) previewData {
+	contentType := servedType(file)
+	kind := strings.Split(lib.MediaType(contentType), "/")[0]
+
+	// Never embed something that can run script, even on another origin.
+	if lib.ActiveType(contentType) {
+		kind = "other"
+	}
+
+	d := previewData{
+		Name:      file.Name,
+		Type:      contentType,
+		Kind:      kind,
+		Size:      humanSize(file.Size),
+		Downloads: serializer.FileDownloads(f.DB, file),
+		PageURL:   f.appURL(r) + "/d/" + file.Slug,
+		RawURL:    f.RawURL(r, file),
+		CardType:  "summary",
+	}
+	d.OEmbedURL = f.appURL(r) + "/oembed?url=" + url.QueryEscape(d.PageURL)
+
+	if thumb := f.thumbnail(file.ID, lib.ThumbnailFit, 800); thumb != nil {
+		d.ThumbURL = f.contentURL(r) + "/api/v1/thumbnails/" + thumb.ID
+	}
+
+	if viewer.Viewable(file.Name, contentType) {
+		d.Text = f.renderText(file, contentType)
+	}
+
+	if m, err := archive.LoadManifest(f.DB, file.ID); err == nil && m != nil {
+		d.Archive = f.archivePreview(r, file, m)
+	}
+
+	if kind == "image" {
+		d.CardType = "summary_large_image"
+		if len(d.ThumbURL) == 0 {
+			d.ThumbURL = d.RawURL
+		}
+	}
+
+	return d
+}
+
+// Only the start of the stored blob is read, big files are truncated.
+func (f Controller) renderText(file *models.File, contentType string) *viewer.Document {
+	data, err := lib.OpenStored(f.DB, f.Fs, file)
+	if err != nil {
+		f.Error("Failed to open file for viewer", "slug", file.Slug, "err", err)
+		return nil
+	}
+	defer data.Close()
+
+	doc, err := viewer.Render(data, file.Name, contentType, f.ViewerLimit)
+	if err != nil {
+		if err != viewer.ErrBinary {
+			f.Error("Failed to render file", "slug", file.Slug, "err", err)
+		}
+		return nil
+	}
+
+	return doc
+}
+
+func (f Controller) archivePreview(r *http.Request, file *models.File, m *archive.Manifest) *archivePreview {
+	p := &archivePreview{
+		Kind:      m.Kind,
+		Size:      humanSize(int(m.Size)),
+		Truncated: m.Truncated || len(m.Entries) > previewEntries,
+	}
+
+	base := f.contentURL(r) + "/d/" + file.Slug + "/entry/"
+	for i, e := range m.Entries {
+		if i == previewEntries {
+			break
+		}
+
+		row := archiveRow{Path: e.Path}
+		if !e.Dir {
+			row.Size = humanSize(int(e.Size))
+		}
+		if !e.Modified.IsZero() {
+			row.Modified = e.Modified.Format("2006-01-02 15:04")
+		}
+		if e.Ratio > 0 {
+			row.Ratio = fmt.Sprintf("%.1f:1", e.Ratio)
+		}
+		if e.Downloadable {
+			row.URL = base + escapePath(e.Path)
+		}
+
+		p.Entries = append(p.Entries, row)
+	}
+
+	return p
+}
+
+func escapePath(p string) string {
+	parts := strings.Split(p, "/")
+	for i, part := range parts {
+		parts[i] = url.PathEscape(part)
+	}
+
+	return strings.Join(parts, "/")
+}
+
+// thumbnail finds the JPEG rendition of a file closest to kind and size.
+func (f Controller) thumbnail(fileID, kind string, size int) *models.Thumbnail {
+	thumbs, err := models.Thumbnails(f.DB, qm.Where("file_id=$1", fileID)).All()
+	if err != nil {
+		return nil
+	}
+
+	return lib.PickThumbnail(thumbs, kind, size, lib.ThumbnailJPEG)
+}
+
+// RawURL is an absolute link to the bytes of a file.
+func (f Controller) RawURL(r *http.Request, file *models.File) string {
+	if len(f.ContentHost) > 0 {
+		return f.contentURL(r) + "/d/" + file.Slug
+	}
+
+	return f.appURL(r) + "/d/" + file.Slug + "?raw=1"
+}
+
+func (f Controller) appURL(r *http.Request) string {
+	return f.scheme(r) + "://" + r.Host
+}
+
+func (f Controller) contentURL(r *http.Request) string {
+	if len(f.ContentHost) > 0 {
+		return f.scheme(r) + "://" + f.ContentHost
+	}
+
+	return f.appURL(r)
+}
+
+func (f Controller) scheme(r *http.Request) string {
+	if len(f.Scheme) > 0 {
+		return f.Scheme
+	}
+
+	if r.TLS != nil {
+		return "https"
+	}
+
+	return "http"
+}
+
+func humanSize(size int) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	div, exp := unit, 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
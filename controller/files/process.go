@@ -3,38 +3,50 @@ package files
 import (
 	"net/http"
 
-	"github.com/labstack/echo"
+	"github.com/pressly/chi"
+	"github.com/zqzca/back/jobs"
 	"github.com/zqzca/back/lib"
 	"github.com/zqzca/back/models"
-	"github.com/zqzca/back/processors"
 
 	"github.com/vattle/sqlboiler/queries/qm"
 )
 
-// Process builds thumbnails
-func (f Controller) Process(e echo.Context) error {
-	f.Debug("processing")
-
-	slug := e.Param("slug")
+// Reprocess queues a finished file to run through the processors again, eg.
+// after a new one was added. Files someone uploaded can only be reprocessed
+// by them.
+func (f Controller) Reprocess(w http.ResponseWriter, r *http.Request) {
+	slug := chi.URLParam(r, "slug")
 	file, err := models.Files(f.DB, qm.Where("slug=$1", slug)).One()
-
 	if err != nil {
-		return err
+		http.Error(w, "File not found", 404)
+		return
 	}
 
-	if file.State == lib.FileProcessing {
-		f.Debug("file already being processed")
-		return e.NoContent(http.StatusConflict)
+	if status, msg := checkReprocess(r, file); status != 0 {
+		f.Debug("file can't be reprocessed", "slug", slug, "reason", msg)
+		http.Error(w, msg, status)
+		return
 	}
 
-	err = processors.CompleteFile(f.Dependencies, *file)
+	if err = f.Jobs.Enqueue(jobs.ReprocessFile, file.ID, nil); err != nil {
+		f.Error("Failed to queue reprocessing", "slug", slug, "err", err)
+		http.Error(w, http.StatusText(500), 500)
+		return
+	}
 
-	if err != nil {
-		f.Debug("failed to complete file", "err", err)
-		return err
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// checkReprocess returns the status and message a request to reprocess file
+// is refused with, or 0 when it can go ahead.
+func checkReprocess(r *http.Request, file *models.File) (int, string) {
+	if file.UserID.Valid && !owns(r, file) {
+		return http.StatusForbidden, http.StatusText(http.StatusForbidden)
 	}
 
-	f.Info("Finished File", "name", file.Name, "id", file.ID)
+	if file.State != lib.FileFinished && !lib.Quarantined(file) {
+		return http.StatusConflict, "File is not finished"
+	}
 
-	return e.NoContent(http.StatusOK)
+	return 0, ""
 }
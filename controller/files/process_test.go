@@ -0,0 +1,52 @@
+package files
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zqzca/back/controller"
+	"github.com/zqzca/back/lib"
+	"github.com/zqzca/back/models"
+	null "gopkg.in/nullbio/null.v5"
+)
+
+func TestCheckReprocess(t *testing.T) {
+	t.Parallel()
+
+	owner := &models.User{ID: "owner"}
+	other := &models.User{ID: "other"}
+
+	tests := []struct {
+		name  string
+		user  *models.User
+		owned bool
+		state int
+		want  int
+	}{
+		{"owner", owner, true, lib.FileFinished, 0},
+		{"someone else's", other, true, lib.FileFinished, http.StatusForbidden},
+		{"signed out", nil, true, lib.FileFinished, http.StatusForbidden},
+		{"anonymous upload", other, false, lib.FileFinished, 0},
+		{"quarantined", owner, true, lib.FileQuarantined, 0},
+		{"processing", owner, true, lib.FileProcessing, http.StatusConflict},
+		{"incomplete", other, false, lib.FileIncomplete, http.StatusConflict},
+		{"someone else's unfinished", other, true, lib.FileProcessing, http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest("POST", "/api/v1/files/abc/reprocess", nil)
+		if test.user != nil {
+			r = controller.WithUser(r, test.user)
+		}
+
+		file := &models.File{State: test.state}
+		if test.owned {
+			file.UserID = null.StringFrom(owner.ID)
+		}
+
+		status, _ := checkReprocess(r, file)
+		assert.Equal(t, test.want, status, test.name)
+	}
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/pressly/chi"
 	"github.com/pressly/chi/render"
+	"github.com/zqzca/back/lib"
 	"github.com/zqzca/back/models"
 	"github.com/zqzca/back/serializer"
 
@@ -15,7 +16,7 @@ import (
 func (c Controller) Show(w http.ResponseWriter, r *http.Request) {
 	slug := chi.URLParam(r, "slug")
 	f, err := models.Files(c.DB, qm.Where("slug=$1", slug)).One()
-	if err != nil {
+	if err != nil || lib.Quarantined(f) {
 		http.Error(w, "File not found", 404)
 		return
 	}
@@ -0,0 +1,84 @@
+package files
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/pressly/chi"
+	"github.com/pressly/chi/render"
+	"github.com/vattle/sqlboiler/queries/qm"
+	"github.com/zqzca/back/lib"
+	"github.com/zqzca/back/models"
+	"github.com/zqzca/back/serializer"
+)
+
+const (
+	defaultSimilarLimit = 20
+	maxSimilarLimit     = 100
+)
+
+// Similar lists uploads that look like an image, closest first. distance is
+// how many bits of the perceptual hashes may differ. Files that aren't images
+// have no similar ones.
+func (f Controller) Similar(w http.ResponseWriter, r *http.Request) {
+	slug := chi.URLParam(r, "slug")
+	file, err := models.Files(f.DB, qm.Where("slug=$1", slug)).One()
+	if err != nil || lib.Quarantined(file) {
+		http.Error(w, "File not found", 404)
+		return
+	}
+
+	distance, ok := intParam(r, "distance", lib.DefaultSimilarDistance, lib.MaxSimilarDistance)
+	if !ok {
+		http.Error(w, "Invalid distance", http.StatusBadRequest)
+		return
+	}
+
+	limit, ok := intParam(r, "limit", defaultSimilarLimit, maxSimilarLimit)
+	if !ok || limit < 1 {
+		http.Error(w, "Invalid limit", http.StatusBadRequest)
+		return
+	}
+
+	out := []serializer.SimilarFile{}
+	if !file.Phash.Valid {
+		render.JSON(w, r, out)
+		return
+	}
+
+	similar, err := lib.FindSimilar(f.DB, file.ID, file.Phash.Int64, distance, limit)
+	if err != nil {
+		f.Error("Failed to find similar files", "slug", slug, "err", err)
+		http.Error(w, http.StatusText(500), 500)
+		return
+	}
+
+	for _, s := range similar {
+		other, err := models.FindFile(f.DB, s.FileID)
+		if err != nil {
+			continue
+		}
+
+		out = append(out, serializer.SimilarFile{
+			File:     serializer.ForFile(f.DB, other),
+			Distance: s.Distance,
+		})
+	}
+
+	render.JSON(w, r, out)
+}
+
+// intParam reads a query parameter between 0 and max, or def when missing.
+func intParam(r *http.Request, name string, def, max int) (int, bool) {
+	v := r.URL.Query().Get(name)
+	if len(v) == 0 {
+		return def, true
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 || n > max {
+		return 0, false
+	}
+
+	return n, true
+}
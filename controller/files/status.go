@@ -6,8 +6,10 @@ import (
 	"github.com/pressly/chi"
 	"github.com/pressly/chi/render"
 	"github.com/vattle/sqlboiler/boil"
+	"github.com/zqzca/back/clamd"
 	"github.com/zqzca/back/lib"
 	"github.com/zqzca/back/models"
+	"github.com/zqzca/back/processors"
 
 	"github.com/vattle/sqlboiler/queries/qm"
 )
@@ -18,6 +20,9 @@ type fileStatus struct {
 	ChunksReceived []string `json:"chunks_received,omitempty"`
 	ChunksNeeded   int      `json:"chunks_needed,omitempty"`
 	Slug           string   `json:"slug,omitempty"`
+
+	Processors []processors.Run `json:"processors,omitempty"`
+	Scan       *clamd.Verdict   `json:"scan,omitempty"`
 }
 
 // FileStatus represents the files... status...
@@ -31,6 +36,8 @@ func (s fileState) String() string {
 		return "processing"
 	case lib.FileFinished:
 		return "finished"
+	case lib.FileQuarantined:
+		return "quarantined"
 	default:
 		return "unknown"
 	}
@@ -54,6 +61,16 @@ func statusForFile(ex boil.Executor, f *models.File) (*fileStatus, error) {
 		}
 	}
 
+	runs, err := processors.LoadRuns(ex, f.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	scan, err := clamd.LoadVerdict(ex, f.ID)
+	if err != nil {
+		return nil, err
+	}
+
 	state := fileState(f.State)
 	return &fileStatus{
 		ID:             f.ID,
@@ -61,6 +78,8 @@ func statusForFile(ex boil.Executor, f *models.File) (*fileStatus, error) {
 		ChunksReceived: chunksReceived,
 		ChunksNeeded:   chunksNeeded,
 		Slug:           f.Slug,
+		Processors:     runs,
+		Scan:           scan,
 	}, nil
 }
 
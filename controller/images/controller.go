@@ -0,0 +1,16 @@
+package images
+
+import (
+	"github.com/zqzca/back/dependencies"
+	"github.com/zqzca/back/lib"
+)
+
+// Controller carries dependencies
+type Controller struct {
+	dependencies.Dependencies
+
+	// Anyone can ask for a preset. Anything else needs a signature made
+	// with Secret.
+	Presets lib.ImagePresets
+	Secret  []byte
+}
@@ -0,0 +1,50 @@
+package images
+
+import (
+	"runtime"
+	"sync"
+)
+
+// Decoding is memory hungry, only a few images are rendered at once.
+var decodes = make(chan struct{}, runtime.NumCPU())
+
+// renders makes sure a variant is only rendered once when many requests ask
+// for it before it is stored.
+var renders = &flight{calls: map[string]*render{}}
+
+type render struct {
+	wg   sync.WaitGroup
+	hash string
+	err  error
+}
+
+type flight struct {
+	mu    sync.Mutex
+	calls map[string]*render
+}
+
+// do runs fn unless it is already running for key, in which case it waits
+// for and shares that result.
+func (g *flight) do(key string, fn func() (string, error)) (string, error) {
+	g.mu.Lock()
+	if r, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		r.wg.Wait()
+		return r.hash, r.err
+	}
+
+	r := &render{}
+	r.wg.Add(1)
+	g.calls[key] = r
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		r.wg.Done()
+	}()
+
+	r.hash, r.err = fn()
+	return r.hash, r.err
+}
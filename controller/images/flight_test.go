@@ -0,0 +1,44 @@
+package images
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlightShares(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	g := &flight{calls: map[string]*render{}}
+	start := make(chan struct{})
+	var runs int32
+
+	var wg sync.WaitGroup
+	hashes := make([]string, 10)
+	for i := range hashes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			hashes[i], _ = g.do("key", func() (string, error) {
+				atomic.AddInt32(&runs, 1)
+				<-start
+				return "hash", nil
+			})
+		}(i)
+	}
+
+	// Give every caller time to join the running call.
+	time.Sleep(50 * time.Millisecond)
+	close(start)
+	wg.Wait()
+
+	a.Empty(g.calls)
+	for _, h := range hashes {
+		a.Equal("hash", h)
+	}
+	a.Equal(int32(1), atomic.LoadInt32(&runs))
+}
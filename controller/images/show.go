@@ -0,0 +1,127 @@
+package images
+
+import (
+	"database/sql"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/pressly/chi"
+	"github.com/vattle/sqlboiler/queries/qm"
+	"github.com/zqzca/back/db"
+	"github.com/zqzca/back/lib"
+	"github.com/zqzca/back/models"
+	"github.com/zqzca/back/processors"
+)
+
+const findDerivedSQL = `
+	SELECT hash FROM derived_images
+	WHERE source_hash = $1 AND variant = $2
+`
+
+const insertDerivedSQL = `
+	INSERT INTO derived_images (source_hash, variant, hash, size, format)
+	VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT (source_hash, variant) DO NOTHING
+`
+
+// Show sends a resized, cropped or converted version of an image upload,
+// rendering it the first time it is asked for.
+func (c Controller) Show(w http.ResponseWriter, r *http.Request) {
+	slug := chi.URLParam(r, "slug")
+	file, err := models.Files(c.DB, qm.Where("slug=$1", slug)).One()
+	if err != nil {
+		http.Error(w, "File not found", 404)
+		return
+	}
+
+	detected := lib.MediaType(file.DetectedType.String)
+	if !lib.Servable(file) || !strings.HasPrefix(detected, "image/") || lib.ActiveType(detected) {
+		http.Error(w, "Not an image", http.StatusUnprocessableEntity)
+		return
+	}
+
+	q := r.URL.Query()
+	o, err := lib.ParseImageOptions(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !c.Presets.Allowed(o) && !lib.VerifyImage(c.Secret, slug, o, q.Get("s")) {
+		http.Error(w, "Size not allowed", http.StatusForbidden)
+		return
+	}
+
+	hash, err := c.derive(file, o)
+	if errors.Cause(err) == processors.ErrImageTooBig {
+		http.Error(w, "Image too big", http.StatusUnprocessableEntity)
+		return
+	}
+	if err != nil {
+		c.Error("Failed to derive image", "slug", slug, "variant", o.Key(), "err", err)
+		http.Error(w, http.StatusText(500), 500)
+		return
+	}
+
+	// The slug can be pointed at other content, eg. a reprocessed file, so
+	// the variant is only cached for a day.
+	w.Header().Set("Content-Type", "image/"+o.Format)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	w.Header().Set("Etag", "\""+hash+"\"")
+	http.ServeFile(w, r, lib.LocalPath(hash))
+}
+
+// derive finds the blob for a variant, making it when needed. Variants are
+// keyed on the source blob so duplicate uploads share them.
+func (c Controller) derive(file *models.File, o lib.ImageOptions) (string, error) {
+	source := lib.StoredHash(file)
+
+	hash, err := findDerived(c.DB, source, o)
+	if hash != "" || err != nil {
+		return hash, err
+	}
+
+	return renders.do(source+"/"+o.Key(), func() (string, error) {
+		decodes <- struct{}{}
+		defer func() { <-decodes }()
+
+		// Someone else might have rendered it while we waited.
+		hash, err := findDerived(c.DB, source, o)
+		if hash != "" || err != nil {
+			return hash, err
+		}
+
+		src, err := lib.OpenStored(c.DB, c.Fs, file)
+		if err != nil {
+			return "", err
+		}
+		defer src.Close()
+
+		hash, size, err := processors.DeriveImage(c.Dependencies, src, o)
+		if err != nil {
+			return "", err
+		}
+
+		return hash, record(c.DB, source, o, hash, size)
+	})
+}
+
+// findDerived returns the blob of a variant that was already rendered, or
+// nothing.
+func findDerived(ex db.Executor, source string, o lib.ImageOptions) (string, error) {
+	var hash string
+
+	err := ex.QueryRow(findDerivedSQL, source, o.Key()).Scan(&hash)
+	if err == sql.ErrNoRows || (err == nil && !lib.ExistsOnDisk(hash)) {
+		return "", nil
+	}
+
+	return hash, err
+}
+
+func record(ex db.Executor, source string, o lib.ImageOptions, hash string, size int) error {
+	_, err := ex.Exec(insertDerivedSQL, source, o.Key(), hash, size, o.Format)
+	return err
+}
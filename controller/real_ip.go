@@ -0,0 +1,24 @@
+package controller
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/zqzca/back/lib"
+)
+
+// RealIP resolves the client IP against the trusted proxy list and stores it
+// on the request. RemoteAddr is rewritten so request logs show the client
+// rather than the proxy.
+func RealIP(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		if ip, err := lib.ClientIP(r); err == nil {
+			r = lib.WithClientIP(r, ip)
+			r.RemoteAddr = net.JoinHostPort(ip, "0")
+		}
+
+		next.ServeHTTP(w, r)
+	}
+
+	return http.HandlerFunc(fn)
+}
@@ -0,0 +1,8 @@
+package stats
+
+import "github.com/zqzca/back/dependencies"
+
+// Controller carries dependencies
+type Controller struct {
+	dependencies.Dependencies
+}
@@ -0,0 +1,177 @@
+package stats
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/zqzca/back/db"
+	"github.com/zqzca/back/serializer"
+)
+
+const topLimit = 10
+
+// Stats cover a single file, $1 is its id, or every file a user uploaded, $1
+// is the user id. The scope goes in for %s, $2 is the start of the window.
+const (
+	fileScope = `file_id = $1::uuid`
+	userScope = `file_id IN (SELECT id FROM files WHERE user_id = $1::uuid)`
+)
+
+const summarySQL = `
+	SELECT
+	count(*), count(DISTINCT ip), count(*) FILTER (WHERE cache_hit),
+	count(*) FILTER (WHERE bot), count(*) FILTER (WHERE completed)
+	FROM downloads
+	WHERE %s
+	AND created_at >= $2
+`
+
+const seriesSQL = `
+	SELECT
+	date_trunc($3, created_at) AS bucket,
+	count(*), count(DISTINCT ip), count(*) FILTER (WHERE cache_hit)
+	FROM downloads
+	WHERE %s
+	AND created_at >= $2
+	GROUP BY bucket
+	ORDER BY bucket ASC
+`
+
+const topReferrersSQL = `
+	SELECT referrer_host, count(*)
+	FROM downloads
+	WHERE %s
+	AND created_at >= $2
+	AND referrer_host IS NOT NULL
+	GROUP BY referrer_host
+	ORDER BY count(*) DESC
+	LIMIT $3
+`
+
+const topUserAgentsSQL = `
+	SELECT ua_family, count(*)
+	FROM downloads
+	WHERE %s
+	AND created_at >= $2
+	AND ua_family IS NOT NULL
+	GROUP BY ua_family
+	ORDER BY count(*) DESC
+	LIMIT $3
+`
+
+const topCountriesSQL = `
+	SELECT country, count(*)
+	FROM downloads
+	WHERE %s
+	AND created_at >= $2
+	AND country IS NOT NULL
+	GROUP BY country
+	ORDER BY count(*) DESC
+	LIMIT $3
+`
+
+const topASNsSQL = `
+	SELECT asn::text, count(*)
+	FROM downloads
+	WHERE %s
+	AND created_at >= $2
+	AND asn IS NOT NULL
+	GROUP BY asn
+	ORDER BY count(*) DESC
+	LIMIT $3
+`
+
+// interval is how downloads are bucketed and how far back we look.
+type interval struct {
+	name   string
+	window time.Duration
+}
+
+var intervals = map[string]interval{
+	"hour": {"hour", 48 * time.Hour},
+	"day":  {"day", 30 * 24 * time.Hour},
+}
+
+// scope is fileScope or userScope, id the file or user it is about.
+func buildStats(ex db.Executor, scope, id string, iv interval) (*serializer.Stats, error) {
+	since := time.Now().UTC().Add(-iv.window).Truncate(time.Hour)
+	s := &serializer.Stats{
+		Interval:   iv.name,
+		Since:      since,
+		Series:     []serializer.StatsBucket{},
+		Referrers:  []serializer.StatsCount{},
+		UserAgents: []serializer.StatsCount{},
+		Countries:  []serializer.StatsCount{},
+		ASNs:       []serializer.StatsCount{},
+	}
+
+	var hits int
+	err := ex.QueryRow(fmt.Sprintf(summarySQL, scope), id, since).Scan(
+		&s.Downloads, &s.UniqueVisitors, &hits, &s.Bots, &s.Completed,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.Downloads > 0 {
+		s.CacheHitRatio = float64(hits) / float64(s.Downloads)
+	}
+
+	rows, err := ex.Query(fmt.Sprintf(seriesSQL, scope), id, since, iv.name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var b serializer.StatsBucket
+		if err = rows.Scan(&b.Time, &b.Downloads, &b.UniqueVisitors, &b.CacheHits); err != nil {
+			return nil, err
+		}
+
+		s.Series = append(s.Series, b)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if s.Referrers, err = topCounts(ex, fmt.Sprintf(topReferrersSQL, scope), id, since); err != nil {
+		return nil, err
+	}
+
+	if s.UserAgents, err = topCounts(ex, fmt.Sprintf(topUserAgentsSQL, scope), id, since); err != nil {
+		return nil, err
+	}
+
+	if s.Countries, err = topCounts(ex, fmt.Sprintf(topCountriesSQL, scope), id, since); err != nil {
+		return nil, err
+	}
+
+	if s.ASNs, err = topCounts(ex, fmt.Sprintf(topASNsSQL, scope), id, since); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func topCounts(ex db.Executor, query, id string, since time.Time) ([]serializer.StatsCount, error) {
+	counts := []serializer.StatsCount{}
+
+	rows, err := ex.Query(query, id, since, topLimit)
+	if err != nil {
+		return counts, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c serializer.StatsCount
+		if err = rows.Scan(&c.Value, &c.Count); err != nil {
+			return counts, err
+		}
+
+		counts = append(counts, c)
+	}
+
+	return counts, rows.Err()
+}
@@ -0,0 +1,86 @@
+package stats
+
+import (
+	"net/http"
+
+	"github.com/pressly/chi"
+	"github.com/pressly/chi/render"
+	"github.com/vattle/sqlboiler/queries/qm"
+	"github.com/zqzca/back/controller"
+	"github.com/zqzca/back/models"
+	"github.com/zqzca/back/serializer"
+)
+
+// Index returns download stats for every file the signed in user uploaded.
+func (c Controller) Index(w http.ResponseWriter, r *http.Request) {
+	c.respond(w, r, userScope, controller.CurrentUser(r).ID)
+}
+
+// File returns download stats for a single file, only to whoever uploaded
+// it.
+func (c Controller) File(w http.ResponseWriter, r *http.Request) {
+	slug := chi.URLParam(r, "slug")
+	f, err := models.Files(c.DB, qm.Select("id", "user_id"), qm.Where("slug=$1", slug)).One()
+	if err != nil {
+		http.Error(w, "File not found", 404)
+		return
+	}
+
+	user := controller.CurrentUser(r)
+	if user == nil || !f.UserID.Valid || f.UserID.String != user.ID {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	c.respond(w, r, fileScope, f.ID)
+}
+
+// The interval param is hour or day (default) and format is json (default) or
+// csv. A csv only holds one table so section picks series (default),
+// referrers, user_agents, countries or asns.
+func (c Controller) respond(w http.ResponseWriter, r *http.Request, scope, id string) {
+	q := r.URL.Query()
+
+	name := q.Get("interval")
+	if len(name) == 0 {
+		name = "day"
+	}
+
+	iv, ok := intervals[name]
+	if !ok {
+		http.Error(w, "Invalid interval", http.StatusBadRequest)
+		return
+	}
+
+	s, err := buildStats(c.DB, scope, id, iv)
+	if err != nil {
+		c.Error("Failed to build stats", "err", err)
+		http.Error(w, http.StatusText(500), 500)
+		return
+	}
+
+	if q.Get("format") != "csv" {
+		render.JSON(w, r, s)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", "attachment; filename=stats.csv")
+
+	switch q.Get("section") {
+	case "referrers":
+		err = serializer.WriteCountsCSV(w, "referrer", s.Referrers)
+	case "user_agents":
+		err = serializer.WriteCountsCSV(w, "user_agent", s.UserAgents)
+	case "countries":
+		err = serializer.WriteCountsCSV(w, "country", s.Countries)
+	case "asns":
+		err = serializer.WriteCountsCSV(w, "asn", s.ASNs)
+	default:
+		err = s.WriteSeriesCSV(w)
+	}
+
+	if err != nil {
+		c.Error("Failed to write stats csv", "err", err)
+	}
+}
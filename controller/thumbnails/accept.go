@@ -0,0 +1,25 @@
+package thumbnails
+
+import (
+	"mime"
+	"strconv"
+	"strings"
+)
+
+// acceptsWebP reports whether an Accept header lists WebP without a q of 0.
+func acceptsWebP(accept string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		t, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil || t != "image/webp" {
+			continue
+		}
+
+		if q, err := strconv.ParseFloat(params["q"], 64); err == nil && q == 0 {
+			return false
+		}
+
+		return true
+	}
+
+	return false
+}
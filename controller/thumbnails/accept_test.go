@@ -0,0 +1,18 @@
+package thumbnails
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAcceptsWebP(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	a.True(acceptsWebP("image/avif,image/webp,image/apng,*/*;q=0.8"))
+	a.True(acceptsWebP("image/webp;q=0.5"))
+	a.False(acceptsWebP("image/webp;q=0"))
+	a.False(acceptsWebP("image/png,*/*"))
+	a.False(acceptsWebP(""))
+}
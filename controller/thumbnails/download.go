@@ -2,13 +2,17 @@ package thumbnails
 
 import (
 	"net/http"
+	"strconv"
 
 	"github.com/pressly/chi"
+	"github.com/vattle/sqlboiler/queries/qm"
 	"github.com/zqzca/back/lib"
 	"github.com/zqzca/back/models"
 )
 
-// Download a file
+// Download sends a thumbnail. The id picks the file, the size and kind query
+// params and the Accept header pick which of its renditions is sent. Without
+// them the thumbnail with that id is sent as JPEG or WebP.
 func (t Controller) Download(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 
@@ -23,5 +27,34 @@ func (t Controller) Download(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	kind := r.URL.Query().Get("kind")
+	if len(kind) == 0 {
+		kind = thumb.Kind
+	}
+
+	size, err := strconv.Atoi(r.URL.Query().Get("size"))
+	if err != nil || size < 1 {
+		size = thumb.Width
+	}
+
+	format := lib.ThumbnailJPEG
+	if acceptsWebP(r.Header.Get("Accept")) {
+		format = lib.ThumbnailWebP
+	}
+
+	renditions, err := models.Thumbnails(t.DB, qm.Where("file_id=$1", thumb.FileID)).All()
+	if err != nil {
+		t.Error("Failed to look up renditions", "file_id", thumb.FileID, "err", err)
+		http.Error(w, http.StatusText(500), 500)
+		return
+	}
+
+	if best := lib.PickThumbnail(renditions, kind, size, format); best != nil {
+		thumb = best
+	}
+
+	w.Header().Add("Vary", "Accept")
+	w.Header().Set("Content-Type", "image/"+thumb.Format)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
 	http.ServeFile(w, r, lib.LocalPath(thumb.Hash))
 }
@@ -0,0 +1,12 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+ALTER TABLE downloads ADD COLUMN referrer TEXT;
+ALTER TABLE downloads ADD COLUMN user_agent TEXT;
+
+CREATE INDEX index_downloads_on_created_at ON downloads (created_at);
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP INDEX index_downloads_on_created_at;
+ALTER TABLE downloads DROP COLUMN user_agent;
+ALTER TABLE downloads DROP COLUMN referrer;
@@ -0,0 +1,15 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+ALTER TABLE downloads ADD COLUMN referrer_host TEXT;
+ALTER TABLE downloads ADD COLUMN ua_family TEXT;
+ALTER TABLE downloads ADD COLUMN bot BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE downloads ADD COLUMN bytes_sent BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE downloads ADD COLUMN completed BOOLEAN NOT NULL DEFAULT false;
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+ALTER TABLE downloads DROP COLUMN completed;
+ALTER TABLE downloads DROP COLUMN bytes_sent;
+ALTER TABLE downloads DROP COLUMN bot;
+ALTER TABLE downloads DROP COLUMN ua_family;
+ALTER TABLE downloads DROP COLUMN referrer_host;
@@ -0,0 +1,9 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+ALTER TABLE downloads ADD COLUMN country CHAR(2);
+ALTER TABLE downloads ADD COLUMN asn BIGINT;
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+ALTER TABLE downloads DROP COLUMN asn;
+ALTER TABLE downloads DROP COLUMN country;
@@ -0,0 +1,8 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+-- "type" stays as whatever the client declared.
+ALTER TABLE files ADD COLUMN detected_type TEXT;
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+ALTER TABLE files DROP COLUMN detected_type;
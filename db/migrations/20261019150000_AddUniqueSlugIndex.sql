@@ -0,0 +1,8 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+-- Slugs are generated by the app now, identifier() is only a fallback.
+CREATE UNIQUE INDEX files_slug_key ON files (slug);
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP INDEX IF EXISTS files_slug_key;
@@ -0,0 +1,38 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE TABLE jobs (
+  id BIGSERIAL PRIMARY KEY,
+  kind TEXT NOT NULL,
+  file_id UUID REFERENCES files (id) ON DELETE CASCADE,
+  args JSONB NOT NULL DEFAULT '{}',
+  state TEXT NOT NULL DEFAULT 'queued',
+  attempts INTEGER NOT NULL DEFAULT 0,
+  max_attempts INTEGER NOT NULL DEFAULT 5,
+  run_at TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT (now() at time zone 'utc'),
+  locked_at TIMESTAMP WITHOUT TIME ZONE,
+  last_error TEXT,
+  created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+  updated_at TIMESTAMP WITHOUT TIME ZONE NOT NULL
+);
+
+CREATE INDEX index_jobs_on_run_at ON jobs (run_at) WHERE state = 'queued';
+CREATE INDEX index_jobs_on_locked_at ON jobs (locked_at) WHERE state = 'running';
+
+-- A file only ever has one pending job of each kind.
+CREATE UNIQUE INDEX index_jobs_on_kind_and_file_id ON jobs (kind, file_id)
+  WHERE state IN ('queued', 'running');
+
+-- Auto update created_at and updated_at
+CREATE TRIGGER jobs_trigger_set_created_at
+  BEFORE INSERT ON jobs
+  FOR EACH ROW EXECUTE PROCEDURE set_created_at();
+
+CREATE TRIGGER jobs_trigger_set_updated_at
+  BEFORE UPDATE ON jobs
+  FOR EACH ROW EXECUTE PROCEDURE set_updated_at();
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TRIGGER jobs_trigger_set_created_at ON jobs;
+DROP TRIGGER jobs_trigger_set_updated_at ON jobs;
+DROP TABLE jobs;
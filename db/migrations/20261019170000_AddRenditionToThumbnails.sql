@@ -0,0 +1,17 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+-- Every thumbnail made so far is a 200x200 center crop JPEG.
+ALTER TABLE thumbnails ADD COLUMN width INTEGER NOT NULL DEFAULT 200;
+ALTER TABLE thumbnails ADD COLUMN height INTEGER NOT NULL DEFAULT 200;
+ALTER TABLE thumbnails ADD COLUMN format TEXT NOT NULL DEFAULT 'jpeg';
+ALTER TABLE thumbnails ADD COLUMN kind TEXT NOT NULL DEFAULT 'square';
+
+CREATE INDEX index_thumbnails_on_file_id_and_kind ON thumbnails (file_id, kind, width);
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP INDEX IF EXISTS index_thumbnails_on_file_id_and_kind;
+ALTER TABLE thumbnails DROP COLUMN kind;
+ALTER TABLE thumbnails DROP COLUMN format;
+ALTER TABLE thumbnails DROP COLUMN height;
+ALTER TABLE thumbnails DROP COLUMN width;
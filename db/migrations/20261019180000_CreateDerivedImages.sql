@@ -0,0 +1,27 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+-- Resized and converted copies of uploads, keyed by the hash of the source
+-- blob and the canonical options they were made with.
+CREATE TABLE derived_images (
+  id SERIAL PRIMARY KEY,
+  source_hash TEXT NOT NULL,
+  variant TEXT NOT NULL,
+  hash TEXT NOT NULL,
+  size INTEGER NOT NULL,
+  format TEXT NOT NULL,
+  created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+  updated_at TIMESTAMP WITHOUT TIME ZONE NOT NULL
+);
+
+CREATE UNIQUE INDEX index_derived_images_on_source_hash_and_variant
+  ON derived_images (source_hash, variant);
+
+-- Auto update created_at
+CREATE TRIGGER derived_images_trigger_set_created_at
+  BEFORE INSERT ON derived_images
+  FOR EACH ROW EXECUTE PROCEDURE set_created_at();
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TRIGGER derived_images_trigger_set_created_at ON derived_images;
+DROP TABLE derived_images;
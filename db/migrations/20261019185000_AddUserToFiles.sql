@@ -0,0 +1,11 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+-- Signed in uploads belong to a user who can hide their metadata.
+ALTER TABLE files ADD COLUMN user_id UUID REFERENCES users (id);
+
+CREATE INDEX index_files_on_user_id ON files (user_id);
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP INDEX IF EXISTS index_files_on_user_id;
+ALTER TABLE files DROP COLUMN user_id;
@@ -0,0 +1,33 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE TABLE file_metadata (
+  file_id UUID PRIMARY KEY REFERENCES files (id) ON DELETE CASCADE,
+  camera_make TEXT,
+  camera_model TEXT,
+  lens TEXT,
+  exposure_time TEXT,
+  f_number DOUBLE PRECISION,
+  iso INTEGER,
+  focal_length DOUBLE PRECISION,
+  taken_at TIMESTAMP WITHOUT TIME ZONE,
+  latitude DOUBLE PRECISION,
+  longitude DOUBLE PRECISION,
+  hidden TEXT[] NOT NULL DEFAULT '{}',
+  created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+  updated_at TIMESTAMP WITHOUT TIME ZONE NOT NULL
+);
+
+-- Auto update created_at and updated_at
+CREATE TRIGGER file_metadata_trigger_set_created_at
+  BEFORE INSERT ON file_metadata
+  FOR EACH ROW EXECUTE PROCEDURE set_created_at();
+
+CREATE TRIGGER file_metadata_trigger_set_updated_at
+  BEFORE UPDATE ON file_metadata
+  FOR EACH ROW EXECUTE PROCEDURE set_updated_at();
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TRIGGER file_metadata_trigger_set_created_at ON file_metadata;
+DROP TRIGGER file_metadata_trigger_set_updated_at ON file_metadata;
+DROP TABLE file_metadata;
@@ -0,0 +1,10 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+-- privacy is NULL when the uploader left it to the server default.
+ALTER TABLE files ADD COLUMN privacy BOOLEAN;
+ALTER TABLE files ADD COLUMN sanitized_hash TEXT;
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+ALTER TABLE files DROP COLUMN sanitized_hash;
+ALTER TABLE files DROP COLUMN privacy;
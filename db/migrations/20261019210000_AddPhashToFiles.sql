@@ -0,0 +1,10 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+-- 64 bit difference hash of images, compared by Hamming distance.
+ALTER TABLE files ADD COLUMN phash BIGINT;
+CREATE INDEX files_phash_idx ON files (phash) WHERE phash IS NOT NULL;
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP INDEX files_phash_idx;
+ALTER TABLE files DROP COLUMN phash;
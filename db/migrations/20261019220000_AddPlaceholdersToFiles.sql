@@ -0,0 +1,10 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+-- Shown while an image's thumbnail loads. colors are hex, most common first.
+ALTER TABLE files ADD COLUMN blurhash TEXT;
+ALTER TABLE files ADD COLUMN colors TEXT[];
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+ALTER TABLE files DROP COLUMN colors;
+ALTER TABLE files DROP COLUMN blurhash;
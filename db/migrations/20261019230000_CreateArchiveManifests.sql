@@ -0,0 +1,27 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE TABLE archive_manifests (
+  file_id UUID PRIMARY KEY REFERENCES files (id) ON DELETE CASCADE,
+  kind TEXT NOT NULL,
+  entries JSONB NOT NULL,
+  size BIGINT NOT NULL,
+  ratio DOUBLE PRECISION NOT NULL,
+  truncated BOOLEAN NOT NULL DEFAULT false,
+  created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+  updated_at TIMESTAMP WITHOUT TIME ZONE NOT NULL
+);
+
+-- Auto update created_at and updated_at
+CREATE TRIGGER archive_manifests_trigger_set_created_at
+  BEFORE INSERT ON archive_manifests
+  FOR EACH ROW EXECUTE PROCEDURE set_created_at();
+
+CREATE TRIGGER archive_manifests_trigger_set_updated_at
+  BEFORE UPDATE ON archive_manifests
+  FOR EACH ROW EXECUTE PROCEDURE set_updated_at();
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TRIGGER archive_manifests_trigger_set_created_at ON archive_manifests;
+DROP TRIGGER archive_manifests_trigger_set_updated_at ON archive_manifests;
+DROP TABLE archive_manifests;
@@ -0,0 +1,32 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE TABLE media_info (
+  file_id UUID PRIMARY KEY REFERENCES files (id) ON DELETE CASCADE,
+  format TEXT NOT NULL,
+  duration DOUBLE PRECISION NOT NULL DEFAULT 0,
+  width INTEGER NOT NULL DEFAULT 0,
+  height INTEGER NOT NULL DEFAULT 0,
+  video_codec TEXT NOT NULL DEFAULT '',
+  audio_codec TEXT NOT NULL DEFAULT '',
+  bitrate INTEGER NOT NULL DEFAULT 0,
+  title TEXT NOT NULL DEFAULT '',
+  artist TEXT NOT NULL DEFAULT '',
+  album TEXT NOT NULL DEFAULT '',
+  created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+  updated_at TIMESTAMP WITHOUT TIME ZONE NOT NULL
+);
+
+-- Auto update created_at and updated_at
+CREATE TRIGGER media_info_trigger_set_created_at
+  BEFORE INSERT ON media_info
+  FOR EACH ROW EXECUTE PROCEDURE set_created_at();
+
+CREATE TRIGGER media_info_trigger_set_updated_at
+  BEFORE UPDATE ON media_info
+  FOR EACH ROW EXECUTE PROCEDURE set_updated_at();
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TRIGGER media_info_trigger_set_created_at ON media_info;
+DROP TRIGGER media_info_trigger_set_updated_at ON media_info;
+DROP TABLE media_info;
@@ -0,0 +1,28 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+-- The last outcome of every processor that ran on a file.
+CREATE TABLE processor_runs (
+  file_id UUID NOT NULL REFERENCES files (id) ON DELETE CASCADE,
+  processor TEXT NOT NULL,
+  succeeded BOOLEAN NOT NULL,
+  error TEXT,
+  duration_ms BIGINT NOT NULL DEFAULT 0,
+  created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+  updated_at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+  PRIMARY KEY (file_id, processor)
+);
+
+-- Auto update created_at and updated_at
+CREATE TRIGGER processor_runs_trigger_set_created_at
+  BEFORE INSERT ON processor_runs
+  FOR EACH ROW EXECUTE PROCEDURE set_created_at();
+
+CREATE TRIGGER processor_runs_trigger_set_updated_at
+  BEFORE UPDATE ON processor_runs
+  FOR EACH ROW EXECUTE PROCEDURE set_updated_at();
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TRIGGER processor_runs_trigger_set_created_at ON processor_runs;
+DROP TRIGGER processor_runs_trigger_set_updated_at ON processor_runs;
+DROP TABLE processor_runs;
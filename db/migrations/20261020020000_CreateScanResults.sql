@@ -0,0 +1,26 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+-- The latest antivirus scan of a file.
+CREATE TABLE scan_results (
+  file_id UUID PRIMARY KEY REFERENCES files (id) ON DELETE CASCADE,
+  infected BOOLEAN NOT NULL,
+  signature TEXT,
+  scanned_at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+  created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+  updated_at TIMESTAMP WITHOUT TIME ZONE NOT NULL
+);
+
+-- Auto update created_at and updated_at
+CREATE TRIGGER scan_results_trigger_set_created_at
+  BEFORE INSERT ON scan_results
+  FOR EACH ROW EXECUTE PROCEDURE set_created_at();
+
+CREATE TRIGGER scan_results_trigger_set_updated_at
+  BEFORE UPDATE ON scan_results
+  FOR EACH ROW EXECUTE PROCEDURE set_updated_at();
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TRIGGER scan_results_trigger_set_created_at ON scan_results;
+DROP TRIGGER scan_results_trigger_set_updated_at ON scan_results;
+DROP TABLE scan_results;
@@ -0,0 +1,29 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+-- Files stored as their chunks instead of a single blob, in order.
+CREATE TABLE file_manifests (
+  file_id UUID PRIMARY KEY REFERENCES files (id) ON DELETE CASCADE,
+  chunks TEXT[] NOT NULL,
+  sizes BIGINT[] NOT NULL,
+  created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+  updated_at TIMESTAMP WITHOUT TIME ZONE NOT NULL
+);
+
+-- Chunks are only removed once no manifest needs them.
+CREATE INDEX file_manifests_chunks_idx ON file_manifests USING GIN (chunks);
+
+-- Auto update created_at and updated_at
+CREATE TRIGGER file_manifests_trigger_set_created_at
+  BEFORE INSERT ON file_manifests
+  FOR EACH ROW EXECUTE PROCEDURE set_created_at();
+
+CREATE TRIGGER file_manifests_trigger_set_updated_at
+  BEFORE UPDATE ON file_manifests
+  FOR EACH ROW EXECUTE PROCEDURE set_updated_at();
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TRIGGER file_manifests_trigger_set_created_at ON file_manifests;
+DROP TRIGGER file_manifests_trigger_set_updated_at ON file_manifests;
+DROP INDEX file_manifests_chunks_idx;
+DROP TABLE file_manifests;
@@ -0,0 +1,18 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+-- A btree on the whole hash can't answer Hamming distance queries. The hash
+-- is indexed as four 16 bit bands instead, a match within distance d has a
+-- band within d/4 bits of the same band of the hash.
+DROP INDEX files_phash_idx;
+CREATE INDEX files_phash_b0_idx ON files (((phash >> 48) & 65535)) WHERE phash IS NOT NULL;
+CREATE INDEX files_phash_b1_idx ON files (((phash >> 32) & 65535)) WHERE phash IS NOT NULL;
+CREATE INDEX files_phash_b2_idx ON files (((phash >> 16) & 65535)) WHERE phash IS NOT NULL;
+CREATE INDEX files_phash_b3_idx ON files ((phash & 65535)) WHERE phash IS NOT NULL;
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP INDEX files_phash_b0_idx;
+DROP INDEX files_phash_b1_idx;
+DROP INDEX files_phash_b2_idx;
+DROP INDEX files_phash_b3_idx;
+CREATE INDEX files_phash_idx ON files (phash) WHERE phash IS NOT NULL;
@@ -0,0 +1,8 @@
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+-- Files too big for clamd are recorded as never scanned.
+ALTER TABLE scan_results ADD COLUMN unscanned BOOLEAN NOT NULL DEFAULT false;
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+ALTER TABLE scan_results DROP COLUMN unscanned;
@@ -12,12 +12,18 @@ type WebsocketClientWriter interface {
 	Broadcast(string, interface{})
 }
 
+// JobQueue schedules background work on a file.
+type JobQueue interface {
+	Enqueue(kind, fileID string, args map[string]string) error
+}
+
 // Dependencies are used throughout the app.
 type Dependencies struct {
 	*logrus.Logger
 	*sqlx.DB
 	afero.Fs
-	WS WebsocketClientWriter
+	WS   WebsocketClientWriter
+	Jobs JobQueue
 }
 
 // New dependencies for non test
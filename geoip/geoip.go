@@ -0,0 +1,141 @@
+package geoip
+
+import (
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	maxminddb "github.com/oschwald/maxminddb-golang"
+	"github.com/pkg/errors"
+)
+
+// Record is what we know about an IP.
+type Record struct {
+	Country string
+	ASN     uint
+}
+
+// Works with GeoLite2/GeoIP2 Country, City and ASN databases as well as
+// combined databases that carry both sets of fields.
+type mmdbRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	ASN uint `maxminddb:"autonomous_system_number"`
+}
+
+// DB is a MaxMind format database that is swapped out when the file on disk
+// changes. A nil *DB is valid and finds nothing.
+type DB struct {
+	path string
+
+	mu      sync.RWMutex
+	reader  *maxminddb.Reader
+	modTime time.Time
+}
+
+// Open loads the database at path.
+func Open(path string) (*DB, error) {
+	d := &DB{path: path}
+
+	if _, err := d.Reload(); err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// Reload opens the database again if the file was modified since it was last
+// loaded. It reports whether a new database was loaded.
+func (d *DB) Reload() (bool, error) {
+	info, err := os.Stat(d.path)
+	if err != nil {
+		return false, errors.Wrap(err, "Failed to stat GeoIP database")
+	}
+
+	d.mu.RLock()
+	unchanged := d.reader != nil && info.ModTime().Equal(d.modTime)
+	d.mu.RUnlock()
+
+	if unchanged {
+		return false, nil
+	}
+
+	r, err := maxminddb.Open(d.path)
+	if err != nil {
+		return false, errors.Wrap(err, "Failed to open GeoIP database")
+	}
+
+	d.mu.Lock()
+	old := d.reader
+	d.reader = r
+	d.modTime = info.ModTime()
+	d.mu.Unlock()
+
+	// Lookups hold the read lock, so nothing is using the old reader now.
+	if old != nil {
+		old.Close()
+	}
+
+	return true, nil
+}
+
+// Watch polls the file for changes until done is closed. Errors are passed
+// to onError so a bad file being copied into place doesn't kill the watcher;
+// the previous database stays loaded.
+func (d *DB) Watch(interval time.Duration, done <-chan struct{}, onError func(error)) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			if _, err := d.Reload(); err != nil && onError != nil {
+				onError(err)
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// Lookup finds the country and ASN for ip.
+func (d *DB) Lookup(ip net.IP) Record {
+	if d == nil || ip == nil {
+		return Record{}
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	// Closed.
+	if d.reader == nil {
+		return Record{}
+	}
+
+	var r mmdbRecord
+	if err := d.reader.Lookup(ip, &r); err != nil {
+		return Record{}
+	}
+
+	return Record{Country: r.Country.ISOCode, ASN: r.ASN}
+}
+
+// Close releases the database.
+func (d *DB) Close() error {
+	if d == nil {
+		return nil
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.reader == nil {
+		return nil
+	}
+
+	err := d.reader.Close()
+	d.reader = nil
+	return err
+}
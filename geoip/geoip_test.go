@@ -0,0 +1,100 @@
+package geoip_test
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/zqzca/back/geoip"
+)
+
+// writeFixture builds a database mapping network to country and asn.
+func writeFixture(t *testing.T, path string, network string, country string, asn uint32) {
+	tree, err := mmdbwriter.New(mmdbwriter.Options{DatabaseType: "zqz-Test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, n, _ := net.ParseCIDR(network)
+	err = tree.Insert(n, mmdbtype.Map{
+		"country": mmdbtype.Map{
+			"iso_code": mmdbtype.String(country),
+		},
+		"autonomous_system_number": mmdbtype.Uint32(asn),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = tree.WriteTo(f); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	if err = os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	a := assert.New(t)
+
+	dir, _ := ioutil.TempDir("", "geoip")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "test.mmdb")
+	writeFixture(t, path, "1.2.3.0/24", "CA", 64512)
+
+	d, err := geoip.Open(path)
+	a.Nil(err)
+	defer d.Close()
+
+	a.Equal(geoip.Record{Country: "CA", ASN: 64512}, d.Lookup(net.ParseIP("1.2.3.4")))
+	a.Equal(geoip.Record{}, d.Lookup(net.ParseIP("5.6.7.8")))
+
+	// Requests still running at shutdown find nothing.
+	a.Nil(d.Close())
+	a.Equal(geoip.Record{}, d.Lookup(net.ParseIP("1.2.3.4")))
+}
+
+func TestReload(t *testing.T) {
+	a := assert.New(t)
+
+	dir, _ := ioutil.TempDir("", "geoip")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "test.mmdb")
+	writeFixture(t, path, "1.2.3.0/24", "CA", 64512)
+
+	d, err := geoip.Open(path)
+	a.Nil(err)
+	defer d.Close()
+
+	changed, err := d.Reload()
+	a.Nil(err)
+	a.False(changed)
+
+	writeFixture(t, path, "1.2.3.0/24", "DE", 64513)
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(path, later, later)
+
+	changed, err = d.Reload()
+	a.Nil(err)
+	a.True(changed)
+	a.Equal(geoip.Record{Country: "DE", ASN: 64513}, d.Lookup(net.ParseIP("1.2.3.4")))
+}
+
+func TestNilDB(t *testing.T) {
+	var d *geoip.DB
+	assert.Equal(t, geoip.Record{}, d.Lookup(net.ParseIP("1.2.3.4")))
+}
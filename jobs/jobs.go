@@ -0,0 +1,151 @@
+package jobs
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/zqzca/back/db"
+)
+
+// Job kinds.
+const (
+	CompleteFile  = "complete_file"
+	ThumbnailFile = "thumbnail_file"
+	ReprocessFile = "reprocess_file"
+	CompactFile   = "compact_file"
+	CleanupChunks = "cleanup_chunks"
+)
+
+// Job states.
+const (
+	StateQueued  = "queued"
+	StateRunning = "running"
+	StateDone    = "done"
+	StateDead    = "dead"
+)
+
+// Job is a unit of work on a file.
+type Job struct {
+	ID          int64
+	Kind        string
+	FileID      string
+	Args        map[string]string
+	Attempts    int
+	MaxAttempts int
+}
+
+// Handler does the work for one kind of job. Returning an error schedules a
+// retry until the job runs out of attempts.
+type Handler func(job *Job) error
+
+const enqueueSQL = `
+	INSERT INTO jobs (kind, file_id, args, run_at)
+	VALUES ($1, $2, $3, now() at time zone 'utc' + $4 * interval '1 second')
+	ON CONFLICT (kind, file_id) WHERE state IN ('queued', 'running') DO NOTHING
+`
+
+// Claims the next due job. The row lock only lives for this statement, the
+// running state is what keeps other workers away from it afterwards.
+const claimSQL = `
+	UPDATE jobs SET
+	state = 'running', attempts = attempts + 1,
+	locked_at = now() at time zone 'utc'
+	WHERE id = (
+		SELECT id FROM jobs
+		WHERE state = 'queued' AND run_at <= now() at time zone 'utc'
+		ORDER BY run_at ASC
+		LIMIT 1
+		FOR UPDATE SKIP LOCKED
+	)
+	RETURNING id, kind, file_id, args, attempts, max_attempts
+`
+
+const heartbeatSQL = `
+	UPDATE jobs SET locked_at = now() at time zone 'utc'
+	WHERE id = $1 AND state = 'running'
+`
+
+const finishSQL = `
+	UPDATE jobs SET state = 'done', locked_at = NULL, last_error = NULL
+	WHERE id = $1
+`
+
+const retrySQL = `
+	UPDATE jobs SET
+	state = 'queued', locked_at = NULL, last_error = $2,
+	run_at = now() at time zone 'utc' + $3 * interval '1 second'
+	WHERE id = $1
+`
+
+const buryJobSQL = `
+	UPDATE jobs SET state = 'dead', locked_at = NULL, last_error = $2
+	WHERE id = $1
+`
+
+// Running jobs whose worker stopped sending heartbeats go back in the queue.
+const recoverSQL = `
+	UPDATE jobs SET state = 'queued', locked_at = NULL
+	WHERE state = 'running'
+	AND locked_at < now() at time zone 'utc' - $1 * interval '1 second'
+`
+
+// Enqueue schedules a job for a file. Nothing happens if the file already
+// has a pending job of the same kind.
+func Enqueue(ex db.Executor, kind, fileID string, args map[string]string) error {
+	return EnqueueIn(ex, kind, fileID, args, 0)
+}
+
+// EnqueueIn schedules a job for a file to run once wait has passed.
+func EnqueueIn(ex db.Executor, kind, fileID string, args map[string]string, wait time.Duration) error {
+	if args == nil {
+		args = map[string]string{}
+	}
+
+	raw, err := json.Marshal(args)
+	if err != nil {
+		return err
+	}
+
+	_, err = ex.Exec(enqueueSQL, kind, fileID, raw, wait.Seconds())
+	return errors.Wrap(err, "Failed to enqueue job")
+}
+
+func claim(ex db.Executor) (*Job, error) {
+	var (
+		j      Job
+		fileID sql.NullString
+		args   []byte
+	)
+
+	err := ex.QueryRow(claimSQL).Scan(&j.ID, &j.Kind, &fileID, &args, &j.Attempts, &j.MaxAttempts)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to claim job")
+	}
+
+	j.FileID = fileID.String
+	if err = json.Unmarshal(args, &j.Args); err != nil {
+		return nil, errors.Wrap(err, "Failed to read job args")
+	}
+
+	return &j, nil
+}
+
+// Backoff is how long to wait before trying a job again after its nth
+// failed attempt. It doubles from 10 seconds up to an hour.
+func Backoff(attempt int) time.Duration {
+	d := 10 * time.Second
+	for i := 1; i < attempt && d < time.Hour; i++ {
+		d *= 2
+	}
+
+	if d > time.Hour {
+		d = time.Hour
+	}
+
+	return d
+}
@@ -0,0 +1,20 @@
+package jobs_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zqzca/back/jobs"
+)
+
+func TestBackoff(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	a.Equal(10*time.Second, jobs.Backoff(0))
+	a.Equal(10*time.Second, jobs.Backoff(1))
+	a.Equal(20*time.Second, jobs.Backoff(2))
+	a.Equal(80*time.Second, jobs.Backoff(4))
+	a.Equal(time.Hour, jobs.Backoff(20))
+}
@@ -0,0 +1,194 @@
+package jobs
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/zqzca/back/dependencies"
+)
+
+// Queue runs jobs from the jobs table on a pool of workers.
+type Queue struct {
+	dependencies.Dependencies
+
+	// Number of jobs run at the same time.
+	Concurrency int
+	// How often idle workers look for new jobs.
+	PollInterval time.Duration
+	// Running jobs that haven't sent a heartbeat for this long are assumed
+	// to belong to a dead process and are queued again.
+	StuckAfter time.Duration
+
+	handlers map[string]Handler
+	wake     chan struct{}
+}
+
+// NewQueue creates a queue with sensible defaults.
+func NewQueue(deps dependencies.Dependencies, concurrency int) *Queue {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	return &Queue{
+		Dependencies: deps,
+		Concurrency:  concurrency,
+		PollInterval: 5 * time.Second,
+		StuckAfter:   2 * time.Minute,
+		handlers:     make(map[string]Handler),
+		wake:         make(chan struct{}, 1),
+	}
+}
+
+// Register sets the handler for a kind of job.
+func (q *Queue) Register(kind string, h Handler) {
+	q.handlers[kind] = h
+}
+
+// Enqueue schedules a job and wakes up an idle worker.
+func (q *Queue) Enqueue(kind, fileID string, args map[string]string) error {
+	if err := Enqueue(q.DB, kind, fileID, args); err != nil {
+		return err
+	}
+
+	q.Notify()
+	return nil
+}
+
+// Notify lets an idle worker know there is work without waiting for the
+// next poll.
+func (q *Queue) Notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Recover puts stuck jobs back in the queue.
+func (q *Queue) Recover() (int64, error) {
+	res, err := q.DB.Exec(recoverSQL, q.StuckAfter.Seconds())
+	if err != nil {
+		return 0, errors.Wrap(err, "Failed to recover stuck jobs")
+	}
+
+	return res.RowsAffected()
+}
+
+// Start recovers stuck jobs and runs workers until done is closed. It
+// blocks until every worker has finished its current job.
+func (q *Queue) Start(done <-chan struct{}) {
+	if n, err := q.Recover(); err != nil {
+		q.Error("Failed to recover jobs", "err", err)
+	} else if n > 0 {
+		q.Info("Recovered stuck jobs", "count", n)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < q.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.work(done)
+		}()
+	}
+
+	// Keep recovering jobs from other instances that died.
+	t := time.NewTicker(q.StuckAfter)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-done:
+			wg.Wait()
+			return
+		case <-t.C:
+			if n, err := q.Recover(); err != nil {
+				q.Error("Failed to recover jobs", "err", err)
+			} else if n > 0 {
+				q.Info("Recovered stuck jobs", "count", n)
+				q.Notify()
+			}
+		}
+	}
+}
+
+func (q *Queue) work(done <-chan struct{}) {
+	for {
+		job, err := claim(q.DB)
+		if err != nil {
+			q.Error("Failed to claim job", "err", err)
+		}
+
+		if job != nil {
+			q.run(job)
+			continue
+		}
+
+		select {
+		case <-done:
+			return
+		case <-q.wake:
+		case <-time.After(q.PollInterval):
+		}
+	}
+}
+
+func (q *Queue) run(job *Job) {
+	q.Info("Running job", "id", job.ID, "kind", job.Kind, "file", job.FileID, "attempt", job.Attempts)
+
+	stop := make(chan struct{})
+	go q.heartbeat(job, stop)
+
+	err := q.handle(job)
+	close(stop)
+
+	switch {
+	case err == nil:
+		_, err = q.DB.Exec(finishSQL, job.ID)
+	case job.Attempts >= job.MaxAttempts:
+		q.Error("Job failed for good", "id", job.ID, "kind", job.Kind, "err", err)
+		_, err = q.DB.Exec(buryJobSQL, job.ID, err.Error())
+	default:
+		wait := Backoff(job.Attempts)
+		q.Warn("Job failed, retrying", "id", job.ID, "kind", job.Kind, "in", wait, "err", err)
+		_, err = q.DB.Exec(retrySQL, job.ID, err.Error(), wait.Seconds())
+	}
+
+	if err != nil {
+		q.Error("Failed to update job", "id", job.ID, "err", err)
+	}
+}
+
+// A panicking handler counts as a failed attempt rather than taking the
+// worker down with it.
+func (q *Queue) handle(job *Job) (err error) {
+	h, ok := q.handlers[job.Kind]
+	if !ok {
+		return errors.New("No handler for job kind " + job.Kind)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("Job panicked: %v", r)
+		}
+	}()
+
+	return h(job)
+}
+
+func (q *Queue) heartbeat(job *Job, stop <-chan struct{}) {
+	t := time.NewTicker(q.StuckAfter / 3)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+			if _, err := q.DB.Exec(heartbeatSQL, job.ID); err != nil {
+				q.Warn("Failed to update job heartbeat", "id", job.ID, "err", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package jobs
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/zqzca/back/dependencies"
+)
+
+// These tests need real row locks so they run against the database make
+// test points DATABASE_URL at. They commit, so they don't run in parallel
+// and remove their files, and with them their jobs, when done.
+func testQueue(t *testing.T) *Queue {
+	url := os.Getenv("DATABASE_URL")
+	if len(url) == 0 {
+		t.Skip("DATABASE_URL is not set")
+	}
+
+	conn, err := sqlx.Open("postgres", url)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger := logrus.New()
+	logger.Out = ioutil.Discard
+	return NewQueue(dependencies.Dependencies{Logger: logger, DB: conn}, 1)
+}
+
+func testFile(t *testing.T, q *Queue) (string, func()) {
+	var id string
+	err := q.DB.QueryRow(`
+		INSERT INTO files (size, state, name, hash, type)
+		VALUES (1, 0, 'job.txt', 'hash', 'text/plain')
+		RETURNING id
+	`).Scan(&id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return id, func() {
+		q.DB.Exec("DELETE FROM files WHERE id = $1", id)
+	}
+}
+
+type jobRow struct {
+	State     string
+	Attempts  int
+	LastError sql.NullString
+	Due       bool
+}
+
+func findJob(t *testing.T, q *Queue, id int64) jobRow {
+	var j jobRow
+	err := q.DB.QueryRow(`
+		SELECT state, attempts, last_error, run_at <= now() at time zone 'utc'
+		FROM jobs WHERE id = $1
+	`, id).Scan(&j.State, &j.Attempts, &j.LastError, &j.Due)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return j
+}
+
+// Jobs run an hour ago are claimed before anything else in the queue.
+const overdue = -time.Hour
+
+func TestEnqueueDedupes(t *testing.T) {
+	a := assert.New(t)
+	q := testQueue(t)
+
+	file, cleanup := testFile(t, q)
+	defer cleanup()
+
+	count := func() int {
+		var n int
+		a.Nil(q.DB.QueryRow("SELECT count(*) FROM jobs WHERE file_id = $1", file).Scan(&n))
+		return n
+	}
+
+	a.Nil(Enqueue(q.DB, CompleteFile, file, nil))
+	a.Nil(Enqueue(q.DB, CompleteFile, file, map[string]string{"again": "yes"}))
+	a.Equal(1, count())
+
+	// Other kinds are separate.
+	a.Nil(Enqueue(q.DB, ThumbnailFile, file, nil))
+	a.Equal(2, count())
+
+	// Once it ran the same kind can be queued again.
+	_, err := q.DB.Exec("UPDATE jobs SET state = 'done' WHERE file_id = $1 AND kind = $2", file, CompleteFile)
+	a.Nil(err)
+	a.Nil(Enqueue(q.DB, CompleteFile, file, nil))
+	a.Equal(3, count())
+}
+
+func TestClaimSkipsLocked(t *testing.T) {
+	a := assert.New(t)
+	q := testQueue(t)
+
+	first, cleanup := testFile(t, q)
+	defer cleanup()
+	second, cleanup2 := testFile(t, q)
+	defer cleanup2()
+
+	a.Nil(EnqueueIn(q.DB, CompleteFile, first, nil, overdue))
+	a.Nil(EnqueueIn(q.DB, CompleteFile, second, nil, overdue))
+
+	// Neither transaction commits so both claims keep their row locked.
+	tx1, err := q.DB.Begin()
+	a.Nil(err)
+	defer tx1.Rollback()
+	tx2, err := q.DB.Begin()
+	a.Nil(err)
+	defer tx2.Rollback()
+
+	j1, err := claim(tx1)
+	a.Nil(err)
+	j2, err := claim(tx2)
+	a.Nil(err)
+
+	if a.NotNil(j1) && a.NotNil(j2) {
+		a.NotEqual(j1.ID, j2.ID)
+		a.Equal(map[string]bool{first: true, second: true}, map[string]bool{j1.FileID: true, j2.FileID: true})
+		a.Equal(1, j1.Attempts)
+	}
+}
+
+func TestRunRetriesThenBuries(t *testing.T) {
+	a := assert.New(t)
+	q := testQueue(t)
+
+	file, cleanup := testFile(t, q)
+	defer cleanup()
+
+	a.Nil(EnqueueIn(q.DB, CompleteFile, file, nil, overdue))
+	_, err := q.DB.Exec("UPDATE jobs SET max_attempts = 2 WHERE file_id = $1", file)
+	a.Nil(err)
+
+	q.Register(CompleteFile, func(*Job) error { return errors.New("boom") })
+
+	job, err := claim(q.DB)
+	a.Nil(err)
+	if !a.NotNil(job) || !a.Equal(file, job.FileID) {
+		return
+	}
+
+	q.run(job)
+	j := findJob(t, q, job.ID)
+	a.Equal(StateQueued, j.State)
+	a.Equal(1, j.Attempts)
+	a.Equal("boom", j.LastError.String)
+	a.False(j.Due)
+
+	_, err = q.DB.Exec("UPDATE jobs SET run_at = run_at - interval '1 day' WHERE id = $1", job.ID)
+	a.Nil(err)
+
+	job, err = claim(q.DB)
+	a.Nil(err)
+	if !a.NotNil(job) {
+		return
+	}
+	a.Equal(2, job.Attempts)
+
+	q.run(job)
+	j = findJob(t, q, job.ID)
+	a.Equal(StateDead, j.State)
+	a.Equal(2, j.Attempts)
+	a.Equal("boom", j.LastError.String)
+}
+
+func TestRunFinishes(t *testing.T) {
+	a := assert.New(t)
+	q := testQueue(t)
+
+	file, cleanup := testFile(t, q)
+	defer cleanup()
+
+	a.Nil(EnqueueIn(q.DB, CompleteFile, file, nil, overdue))
+	q.Register(CompleteFile, func(*Job) error { return nil })
+
+	job, err := claim(q.DB)
+	a.Nil(err)
+	if !a.NotNil(job) {
+		return
+	}
+
+	q.run(job)
+	a.Equal(StateDone, findJob(t, q, job.ID).State)
+}
+
+func TestRecoverStuckJobs(t *testing.T) {
+	a := assert.New(t)
+	q := testQueue(t)
+	q.StuckAfter = time.Minute
+
+	stuck, cleanup := testFile(t, q)
+	defer cleanup()
+	alive, cleanup2 := testFile(t, q)
+	defer cleanup2()
+
+	a.Nil(EnqueueIn(q.DB, CompleteFile, stuck, nil, overdue))
+	a.Nil(EnqueueIn(q.DB, CompleteFile, alive, nil, overdue))
+
+	stuckJob, err := claim(q.DB)
+	a.Nil(err)
+	aliveJob, err := claim(q.DB)
+	a.Nil(err)
+	if !a.NotNil(stuckJob) || !a.NotNil(aliveJob) {
+		return
+	}
+
+	// The first worker stopped sending heartbeats an hour ago.
+	_, err = q.DB.Exec(`
+		UPDATE jobs SET locked_at = now() at time zone 'utc' - interval '1 hour'
+		WHERE id = $1
+	`, stuckJob.ID)
+	a.Nil(err)
+
+	n, err := q.Recover()
+	a.Nil(err)
+	a.True(n >= 1)
+
+	a.Equal(StateQueued, findJob(t, q, stuckJob.ID).State)
+	a.Equal(StateRunning, findJob(t, q, aliveJob.ID).State)
+}
@@ -0,0 +1,203 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type clientIPKey struct{}
+
+// Proxies is a list of networks whose forwarding headers are trusted.
+type Proxies []*net.IPNet
+
+// TrustedProxies is consulted by ClientIP. It is empty by default which means
+// forwarding headers are ignored and the peer address is always used.
+var TrustedProxies Proxies
+
+// Forwarding headers a proxy might write.
+const (
+	HeaderXForwardedFor = "X-Forwarded-For"
+	HeaderForwarded     = "Forwarded"
+)
+
+// ForwardedHeader is the one header trusted proxies write. The other one is
+// ignored since the client can put anything in it and a proxy that doesn't
+// know about it passes it along untouched.
+var ForwardedHeader = HeaderXForwardedFor
+
+// ParseProxies converts a list of CIDRs or bare IPs into Proxies.
+func ParseProxies(cidrs []string) (Proxies, error) {
+	var p Proxies
+
+	for _, c := range cidrs {
+		c = strings.TrimSpace(c)
+		if len(c) == 0 {
+			continue
+		}
+
+		if !strings.Contains(c, "/") {
+			ip := net.ParseIP(c)
+			if ip == nil {
+				return nil, errors.New("Invalid proxy address: " + c)
+			}
+
+			bits := 128
+			if ip.To4() != nil {
+				bits = 32
+			}
+			c = c + "/" + strconv.Itoa(bits)
+		}
+
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			return nil, err
+		}
+
+		p = append(p, n)
+	}
+
+	return p, nil
+}
+
+// Trusted reports whether ip belongs to one of the trusted proxy networks.
+func (p Proxies) Trusted(ip net.IP) bool {
+	for _, n := range p {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ClientIP resolves the IP of the client that made the request using
+// ForwardedHeader.
+func (p Proxies) ClientIP(r *http.Request) (string, error) {
+	return p.ClientIPFrom(r, ForwardedHeader)
+}
+
+// ClientIPFrom resolves the IP of the client that made the request. header,
+// Forwarded or X-Forwarded-For, is only read when the peer is a trusted
+// proxy, in which case the chain is walked from the right and the first
+// untrusted hop is the client.
+func (p Proxies) ClientIPFrom(r *http.Request, header string) (string, error) {
+	peer, err := extractIP(r.RemoteAddr)
+	if err != nil {
+		return "", err
+	}
+
+	if !p.Trusted(net.ParseIP(peer)) {
+		return peer, nil
+	}
+
+	var hops []string
+	switch header {
+	case HeaderForwarded:
+		hops = forwardedFor(r.Header)
+	case HeaderXForwardedFor:
+		hops = xForwardedFor(r.Header)
+	default:
+		return "", errors.New("Unknown forwarding header: " + header)
+	}
+
+	client := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := net.ParseIP(hops[i])
+		if ip == nil {
+			// Anything left of a garbage entry can't be trusted.
+			break
+		}
+
+		client = ip.String()
+		if !p.Trusted(ip) {
+			break
+		}
+	}
+
+	return client, nil
+}
+
+// ClientIP returns the IP resolved by the RealIP middleware, falling back to
+// resolving it against TrustedProxies.
+func ClientIP(r *http.Request) (string, error) {
+	if ip, ok := r.Context().Value(clientIPKey{}).(string); ok {
+		return ip, nil
+	}
+
+	return TrustedProxies.ClientIP(r)
+}
+
+// WithClientIP stores a resolved client IP on the request context.
+func WithClientIP(r *http.Request, ip string) *http.Request {
+	ctx := context.WithValue(r.Context(), clientIPKey{}, ip)
+	return r.WithContext(ctx)
+}
+
+// AddrIP returns the IP portion of a network address such as the one
+// returned by net.Conn.RemoteAddr.
+func AddrIP(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+
+	ip, err := extractIP(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+
+	return ip
+}
+
+// X-Forwarded-For: client, proxy1, proxy2
+func xForwardedFor(h http.Header) []string {
+	var hops []string
+
+	for _, v := range h["X-Forwarded-For"] {
+		for _, hop := range strings.Split(v, ",") {
+			hops = append(hops, strings.TrimSpace(hop))
+		}
+	}
+
+	return hops
+}
+
+// Forwarded: for=192.0.2.60;proto=http, for="[2001:db8::1]:4711"
+func forwardedFor(h http.Header) []string {
+	var hops []string
+
+	for _, v := range h["Forwarded"] {
+		for _, elem := range strings.Split(v, ",") {
+			for _, pair := range strings.Split(elem, ";") {
+				kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+				if len(kv) != 2 || !strings.EqualFold(kv[0], "for") {
+					continue
+				}
+
+				hops = append(hops, forwardedNode(kv[1]))
+			}
+		}
+	}
+
+	return hops
+}
+
+// Strips quotes, brackets and ports from a Forwarded node.
+func forwardedNode(node string) string {
+	node = strings.Trim(node, `"`)
+
+	if strings.HasPrefix(node, "[") {
+		if end := strings.Index(node, "]"); end > 0 {
+			return node[1:end]
+		}
+	}
+
+	if strings.Count(node, ":") == 1 {
+		return node[:strings.Index(node, ":")]
+	}
+
+	return node
+}
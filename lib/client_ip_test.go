@@ -0,0 +1,107 @@
+package lib_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zqzca/back/lib"
+)
+
+func request(remote string, headers map[string]string) *http.Request {
+	r, _ := http.NewRequest("GET", "/", nil)
+	r.RemoteAddr = remote
+
+	for k, v := range headers {
+		r.Header.Set(k, v)
+	}
+
+	return r
+}
+
+func TestClientIPUntrustedPeer(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p, err := lib.ParseProxies([]string{"10.0.0.0/8"})
+	a.Nil(err)
+
+	r := request("1.2.3.4:5555", map[string]string{"X-Forwarded-For": "9.9.9.9"})
+	ip, err := p.ClientIP(r)
+	a.Nil(err)
+	a.Equal("1.2.3.4", ip)
+}
+
+func TestClientIPXForwardedFor(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p, err := lib.ParseProxies([]string{"10.0.0.0/8", "127.0.0.1"})
+	a.Nil(err)
+
+	r := request("127.0.0.1:5555", map[string]string{
+		"X-Forwarded-For": "6.6.6.6, 1.2.3.4, 10.1.1.1",
+	})
+	ip, err := p.ClientIP(r)
+	a.Nil(err)
+	a.Equal("1.2.3.4", ip)
+}
+
+func TestClientIPForwarded(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p, err := lib.ParseProxies([]string{"10.0.0.0/8"})
+	a.Nil(err)
+
+	r := request("10.0.0.1:5555", map[string]string{
+		"Forwarded":       `for="[2001:db8::1]:4711";proto=https, for=10.0.0.2`,
+		"X-Forwarded-For": "6.6.6.6",
+	})
+	ip, err := p.ClientIPFrom(r, lib.HeaderForwarded)
+	a.Nil(err)
+	a.Equal("2001:db8::1", ip)
+}
+
+func TestClientIPIgnoresOtherHeader(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p, err := lib.ParseProxies([]string{"10.0.0.0/8"})
+	a.Nil(err)
+
+	// A proxy that only appends X-Forwarded-For passes a spoofed Forwarded
+	// along untouched.
+	r := request("10.0.0.1:5555", map[string]string{
+		"Forwarded":       "for=6.6.6.6",
+		"X-Forwarded-For": "1.2.3.4",
+	})
+	ip, err := p.ClientIPFrom(r, lib.HeaderXForwardedFor)
+	a.Nil(err)
+	a.Equal("1.2.3.4", ip)
+
+	r = request("10.0.0.1:5555", map[string]string{"Forwarded": "for=6.6.6.6"})
+	ip, err = p.ClientIPFrom(r, lib.HeaderXForwardedFor)
+	a.Nil(err)
+	a.Equal("10.0.0.1", ip)
+}
+
+func TestClientIPGarbageHeader(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p, err := lib.ParseProxies([]string{"10.0.0.0/8"})
+	a.Nil(err)
+
+	r := request("10.0.0.1:5555", map[string]string{"X-Forwarded-For": "1.2.3.4, nope"})
+	ip, err := p.ClientIP(r)
+	a.Nil(err)
+	a.Equal("10.0.0.1", ip)
+}
+
+func TestParseProxiesInvalid(t *testing.T) {
+	t.Parallel()
+
+	_, err := lib.ParseProxies([]string{"not-an-ip"})
+	assert.NotNil(t, err)
+}
@@ -5,4 +5,22 @@ const (
 	FileIncomplete = iota
 	FileProcessing
 	FileFinished
+	// Quarantined files were found to be malware and are never served.
+	FileQuarantined
+)
+
+// Thumbnail kinds. Square thumbnails are center crops, fit thumbnails keep
+// the aspect ratio of the image. Animated thumbnails are square GIFs made
+// from every frame of an animated GIF.
+const (
+	ThumbnailSquare   = "square"
+	ThumbnailFit      = "fit"
+	ThumbnailAnimated = "animated"
+)
+
+// Thumbnail formats
+const (
+	ThumbnailJPEG = "jpeg"
+	ThumbnailWebP = "webp"
+	ThumbnailGIF  = "gif"
 )
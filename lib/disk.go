@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 
 	"github.com/satori/go.uuid"
+	"github.com/zqzca/back/models"
 )
 
 func LocalPath(hash string) string {
@@ -25,3 +26,24 @@ func ExistsOnDisk(hash string) bool {
 
 	return true
 }
+
+// Quarantined reports whether a file must not be served.
+func Quarantined(f *models.File) bool {
+	return f.State == FileQuarantined
+}
+
+// Servable reports whether the bytes of a file can be sent. Until the
+// processors finish, and after they fail, the blob hasn't been scanned.
+func Servable(f *models.File) bool {
+	return f.State == FileFinished
+}
+
+// StoredHash is the blob that is served for a file. Images uploaded in
+// privacy mode are served from a copy without metadata.
+func StoredHash(f *models.File) string {
+	if f.SanitizedHash.Valid {
+		return f.SanitizedHash.String
+	}
+
+	return f.Hash
+}
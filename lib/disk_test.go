@@ -5,6 +5,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/zqzca/back/lib"
+	"github.com/zqzca/back/models"
 )
 
 func TestLocalPath(t *testing.T) {
@@ -13,3 +14,25 @@ func TestLocalPath(t *testing.T) {
 
 	a.Equal("files/foo", lib.LocalPath("foo"))
 }
+
+func TestServable(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		state int
+		want  bool
+	}{
+		// Chunks are still coming in, or the transaction building and
+		// scanning the blob hasn't committed. A failed scan rolls back to
+		// this too.
+		{"incomplete", lib.FileIncomplete, false},
+		{"processing", lib.FileProcessing, false},
+		{"quarantined", lib.FileQuarantined, false},
+		{"finished", lib.FileFinished, true},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.want, lib.Servable(&models.File{State: test.state}), test.name)
+	}
+}
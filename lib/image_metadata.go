@@ -0,0 +1,187 @@
+package lib
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"github.com/zqzca/back/db"
+)
+
+// Groups of metadata an owner can hide.
+const (
+	MetadataDimensions = "dimensions"
+	MetadataCamera     = "camera"
+	MetadataExposure   = "exposure"
+	MetadataTakenAt    = "taken_at"
+	MetadataLocation   = "location"
+)
+
+// MetadataGroups lists every group that can be hidden.
+var MetadataGroups = []string{
+	MetadataDimensions,
+	MetadataCamera,
+	MetadataExposure,
+	MetadataTakenAt,
+	MetadataLocation,
+}
+
+// ImageMetadata is what we know about how a picture was taken.
+type ImageMetadata struct {
+	Width  int `json:"width,omitempty"`
+	Height int `json:"height,omitempty"`
+
+	CameraMake  string `json:"camera_make,omitempty"`
+	CameraModel string `json:"camera_model,omitempty"`
+	Lens        string `json:"lens,omitempty"`
+
+	ExposureTime string  `json:"exposure_time,omitempty"`
+	FNumber      float64 `json:"f_number,omitempty"`
+	ISO          int     `json:"iso,omitempty"`
+	FocalLength  float64 `json:"focal_length,omitempty"`
+
+	TakenAt   *time.Time `json:"taken_at,omitempty"`
+	Latitude  *float64   `json:"latitude,omitempty"`
+	Longitude *float64   `json:"longitude,omitempty"`
+
+	// Groups the owner doesn't want shown to anyone else.
+	Hidden []string `json:"hidden"`
+}
+
+// Redacted is a copy without the hidden groups.
+func (m ImageMetadata) Redacted() ImageMetadata {
+	for _, group := range m.Hidden {
+		switch group {
+		case MetadataDimensions:
+			m.Width, m.Height = 0, 0
+		case MetadataCamera:
+			m.CameraMake, m.CameraModel, m.Lens = "", "", ""
+		case MetadataExposure:
+			m.ExposureTime, m.FNumber, m.ISO, m.FocalLength = "", 0, 0, 0
+		case MetadataTakenAt:
+			m.TakenAt = nil
+		case MetadataLocation:
+			m.Latitude, m.Longitude = nil, nil
+		}
+	}
+
+	m.Hidden = nil
+	return m
+}
+
+// ValidMetadataGroups reports whether every group can be hidden.
+func ValidMetadataGroups(groups []string) bool {
+	for _, g := range groups {
+		found := false
+		for _, known := range MetadataGroups {
+			found = found || g == known
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+const loadMetadataSQL = `
+	SELECT
+	coalesce(camera_make, ''), coalesce(camera_model, ''), coalesce(lens, ''),
+	coalesce(exposure_time, ''), coalesce(f_number, 0), coalesce(iso, 0),
+	coalesce(focal_length, 0), taken_at, latitude, longitude, hidden
+	FROM file_metadata
+	WHERE file_id = $1
+`
+
+// The owner's choice of hidden groups survives reprocessing.
+const saveMetadataSQL = `
+	INSERT INTO file_metadata (
+		file_id, camera_make, camera_model, lens, exposure_time, f_number,
+		iso, focal_length, taken_at, latitude, longitude
+	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	ON CONFLICT (file_id) DO UPDATE SET
+	camera_make = EXCLUDED.camera_make, camera_model = EXCLUDED.camera_model,
+	lens = EXCLUDED.lens, exposure_time = EXCLUDED.exposure_time,
+	f_number = EXCLUDED.f_number, iso = EXCLUDED.iso,
+	focal_length = EXCLUDED.focal_length, taken_at = EXCLUDED.taken_at,
+	latitude = EXCLUDED.latitude, longitude = EXCLUDED.longitude
+`
+
+const deleteMetadataSQL = `
+	DELETE FROM file_metadata WHERE file_id = $1
+`
+
+const hideMetadataSQL = `
+	UPDATE file_metadata SET hidden = $2 WHERE file_id = $1
+`
+
+// LoadImageMetadata returns nil without an error when a file has none.
+func LoadImageMetadata(ex db.Executor, fileID string) (*ImageMetadata, error) {
+	var (
+		m        ImageMetadata
+		takenAt  pq.NullTime
+		lat, lng sql.NullFloat64
+	)
+
+	err := ex.QueryRow(loadMetadataSQL, fileID).Scan(
+		&m.CameraMake, &m.CameraModel, &m.Lens,
+		&m.ExposureTime, &m.FNumber, &m.ISO,
+		&m.FocalLength, &takenAt, &lat, &lng, pq.Array(&m.Hidden),
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to load metadata")
+	}
+
+	if takenAt.Valid {
+		m.TakenAt = &takenAt.Time
+	}
+	if lat.Valid && lng.Valid {
+		m.Latitude, m.Longitude = &lat.Float64, &lng.Float64
+	}
+
+	return &m, nil
+}
+
+// SaveImageMetadata stores the metadata of a file, replacing what was there.
+func SaveImageMetadata(ex db.Executor, fileID string, m *ImageMetadata) error {
+	_, err := ex.Exec(saveMetadataSQL,
+		fileID, nullString(m.CameraMake), nullString(m.CameraModel), nullString(m.Lens),
+		nullString(m.ExposureTime), nullFloat(m.FNumber), nullInt(m.ISO),
+		nullFloat(m.FocalLength), m.TakenAt, m.Latitude, m.Longitude,
+	)
+
+	return errors.Wrap(err, "Failed to save metadata")
+}
+
+// DeleteImageMetadata removes whatever metadata a file has.
+func DeleteImageMetadata(ex db.Executor, fileID string) error {
+	_, err := ex.Exec(deleteMetadataSQL, fileID)
+	return errors.Wrap(err, "Failed to delete metadata")
+}
+
+// HideImageMetadata sets which groups are hidden from everyone but the owner.
+func HideImageMetadata(ex db.Executor, fileID string, groups []string) error {
+	if groups == nil {
+		groups = []string{}
+	}
+
+	_, err := ex.Exec(hideMetadataSQL, fileID, pq.Array(groups))
+	return errors.Wrap(err, "Failed to hide metadata")
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: len(s) > 0}
+}
+
+func nullFloat(f float64) sql.NullFloat64 {
+	return sql.NullFloat64{Float64: f, Valid: f != 0}
+}
+
+func nullInt(i int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(i), Valid: i != 0}
+}
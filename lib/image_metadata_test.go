@@ -0,0 +1,52 @@
+package lib_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zqzca/back/lib"
+)
+
+func TestImageMetadataRedacted(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	taken := time.Date(2016, 7, 3, 12, 0, 0, 0, time.UTC)
+	lat, lng := 43.65, -79.38
+
+	m := lib.ImageMetadata{
+		Width:       4000,
+		Height:      3000,
+		CameraMake:  "Canon",
+		CameraModel: "EOS 5D",
+		ISO:         100,
+		TakenAt:     &taken,
+		Latitude:    &lat,
+		Longitude:   &lng,
+		Hidden:      []string{lib.MetadataLocation, lib.MetadataCamera},
+	}
+
+	r := m.Redacted()
+	a.Nil(r.Latitude)
+	a.Nil(r.Longitude)
+	a.Empty(r.CameraMake)
+	a.Empty(r.CameraModel)
+	a.Nil(r.Hidden)
+	a.Equal(4000, r.Width)
+	a.Equal(100, r.ISO)
+	a.Equal(&taken, r.TakenAt)
+
+	// The original is untouched.
+	a.Equal("Canon", m.CameraMake)
+	a.NotNil(m.Latitude)
+}
+
+func TestValidMetadataGroups(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	a.True(lib.ValidMetadataGroups(nil))
+	a.True(lib.ValidMetadataGroups([]string{lib.MetadataLocation, lib.MetadataTakenAt}))
+	a.False(lib.ValidMetadataGroups([]string{"location", "password"}))
+}
@@ -0,0 +1,162 @@
+package lib
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// Ways an image is made to fit the requested box.
+const (
+	// FitInside scales the image down to fit inside the box.
+	FitInside = "fit"
+	// FitFill scales and center crops the image to fill the box.
+	FitFill = "fill"
+)
+
+// Bounds for derived images.
+const (
+	MaxImageSide   = 4096
+	DefaultQuality = 85
+)
+
+// ImageOptions describe a derived version of an image.
+type ImageOptions struct {
+	Width   int
+	Height  int
+	Fit     string
+	Format  string
+	Quality int
+}
+
+// ParseImageOptions reads w, h, fit, fmt and q query params, filling in
+// defaults so equal requests get equal options.
+func ParseImageOptions(q url.Values) (ImageOptions, error) {
+	o := ImageOptions{
+		Fit:     FitInside,
+		Format:  ThumbnailJPEG,
+		Quality: DefaultQuality,
+	}
+
+	var err error
+	if o.Width, err = optionalInt(q, "w", 0, MaxImageSide); err != nil {
+		return o, err
+	}
+	if o.Height, err = optionalInt(q, "h", 0, MaxImageSide); err != nil {
+		return o, err
+	}
+	if o.Quality, err = optionalInt(q, "q", 1, 100); err != nil {
+		return o, err
+	}
+	if o.Quality == 0 {
+		o.Quality = DefaultQuality
+	}
+
+	if fit := q.Get("fit"); len(fit) > 0 {
+		if fit != FitInside && fit != FitFill {
+			return o, errors.New("fit must be fit or fill")
+		}
+		o.Fit = fit
+	}
+
+	if format := q.Get("fmt"); len(format) > 0 {
+		if format == "jpg" {
+			format = ThumbnailJPEG
+		}
+		// WebP waits for a lossy encoder, lossless ones make variants
+		// bigger than the JPEG they replace.
+		if format != ThumbnailJPEG && format != "png" {
+			return o, errors.New("fmt must be jpeg or png")
+		}
+		o.Format = format
+	}
+
+	if o.Fit == FitFill && (o.Width == 0 || o.Height == 0) {
+		return o, errors.New("fill needs both w and h")
+	}
+
+	// Only JPEG is lossy.
+	if o.Format != ThumbnailJPEG {
+		o.Quality = 0
+	}
+
+	return o, nil
+}
+
+func optionalInt(q url.Values, key string, min, max int) (int, error) {
+	raw := q.Get(key)
+	if len(raw) == 0 {
+		return 0, nil
+	}
+
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < min || n > max {
+		return 0, errors.Errorf("%s must be between %d and %d", key, min, max)
+	}
+
+	return n, nil
+}
+
+// Key is a canonical form of the options, used as a cache key and for
+// signatures.
+func (o ImageOptions) Key() string {
+	v := url.Values{}
+	v.Set("w", strconv.Itoa(o.Width))
+	v.Set("h", strconv.Itoa(o.Height))
+	v.Set("fit", o.Fit)
+	v.Set("fmt", o.Format)
+	v.Set("q", strconv.Itoa(o.Quality))
+
+	// Encode sorts by key.
+	return v.Encode()
+}
+
+// SignImage signs the options for one slug.
+func SignImage(secret []byte, slug string, o ImageOptions) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(slug + "?" + o.Key()))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyImage checks a signature made by SignImage.
+func VerifyImage(secret []byte, slug string, o ImageOptions, sig string) bool {
+	if len(secret) == 0 || len(sig) == 0 {
+		return false
+	}
+
+	return hmac.Equal([]byte(SignImage(secret, slug, o)), []byte(sig))
+}
+
+// ImagePresets is an allowlist of options anyone can ask for.
+type ImagePresets map[string]bool
+
+// ParseImagePresets reads presets written as query strings, eg.
+// w=800&fmt=png.
+func ParseImagePresets(specs []string) (ImagePresets, error) {
+	p := ImagePresets{}
+
+	for _, spec := range specs {
+		q, err := url.ParseQuery(spec)
+		if err != nil {
+			return nil, errors.Wrap(err, "Invalid image preset "+spec)
+		}
+
+		o, err := ParseImageOptions(q)
+		if err != nil {
+			return nil, errors.Wrap(err, "Invalid image preset "+spec)
+		}
+
+		p[o.Key()] = true
+	}
+
+	return p, nil
+}
+
+// Allowed reports whether o is one of the presets.
+func (p ImagePresets) Allowed(o ImageOptions) bool {
+	return p[o.Key()]
+}
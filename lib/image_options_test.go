@@ -0,0 +1,77 @@
+package lib_test
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zqzca/back/lib"
+)
+
+func imageQuery(raw string) url.Values {
+	q, _ := url.ParseQuery(raw)
+	return q
+}
+
+func TestParseImageOptions(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	o, err := lib.ParseImageOptions(imageQuery("w=800"))
+	a.Nil(err)
+	a.Equal(lib.ImageOptions{Width: 800, Fit: lib.FitInside, Format: lib.ThumbnailJPEG, Quality: lib.DefaultQuality}, o)
+
+	o, err = lib.ParseImageOptions(imageQuery("w=100&h=100&fit=fill&fmt=png&q=50"))
+	a.Nil(err)
+	a.Equal(lib.FitFill, o.Fit)
+	a.Equal("png", o.Format)
+	a.Equal(0, o.Quality)
+
+	for _, bad := range []string{"w=-1", "w=5000", "w=abc", "q=0", "fit=stretch", "fmt=gif", "fmt=webp", "w=10&fit=fill"} {
+		_, err = lib.ParseImageOptions(imageQuery(bad))
+		a.NotNil(err, bad)
+	}
+}
+
+func TestImageOptionsKey(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	x, _ := lib.ParseImageOptions(imageQuery("w=800&fmt=jpg"))
+	y, _ := lib.ParseImageOptions(imageQuery("fmt=jpeg&q=85&fit=fit&w=800"))
+	a.Equal(x.Key(), y.Key())
+}
+
+func TestSignImage(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	secret := []byte("secret")
+	o, _ := lib.ParseImageOptions(imageQuery("w=300&h=200&fit=fill"))
+	sig := lib.SignImage(secret, "abc", o)
+
+	a.True(lib.VerifyImage(secret, "abc", o, sig))
+	a.False(lib.VerifyImage(secret, "abd", o, sig))
+	a.False(lib.VerifyImage([]byte("other"), "abc", o, sig))
+	a.False(lib.VerifyImage(nil, "abc", o, sig))
+
+	o.Width = 301
+	a.False(lib.VerifyImage(secret, "abc", o, sig))
+}
+
+func TestImagePresets(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p, err := lib.ParseImagePresets([]string{"w=400", "w=800&fmt=png"})
+	a.Nil(err)
+
+	o, _ := lib.ParseImageOptions(imageQuery("w=800&fmt=png"))
+	a.True(p.Allowed(o))
+
+	o, _ = lib.ParseImageOptions(imageQuery("w=801"))
+	a.False(p.Allowed(o))
+
+	_, err = lib.ParseImagePresets([]string{"w=huge"})
+	a.NotNil(err)
+}
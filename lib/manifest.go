@@ -0,0 +1,260 @@
+package lib
+
+import (
+	"database/sql"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"github.com/spf13/afero"
+	"github.com/zqzca/back/db"
+	"github.com/zqzca/back/models"
+)
+
+// Manifest lists the chunks a file is stitched together from when it is
+// stored without a blob of its own.
+type Manifest struct {
+	Chunks []string
+	Sizes  []int64
+}
+
+// Size of the file the manifest makes up.
+func (m *Manifest) Size() int64 {
+	var size int64
+	for _, s := range m.Sizes {
+		size += s
+	}
+
+	return size
+}
+
+// ChunkPath is where an uploaded chunk is kept.
+func ChunkPath(hash string) string {
+	return filepath.Join("files", "chunks", hash)
+}
+
+const loadManifestSQL = `
+	SELECT chunks, sizes FROM file_manifests WHERE file_id = $1
+`
+
+const saveManifestSQL = `
+	INSERT INTO file_manifests (file_id, chunks, sizes) VALUES ($1, $2, $3)
+	ON CONFLICT (file_id) DO UPDATE SET
+	chunks = EXCLUDED.chunks, sizes = EXCLUDED.sizes
+`
+
+const deleteManifestSQL = `DELETE FROM file_manifests WHERE file_id = $1`
+
+const chunkReferencedSQL = `
+	SELECT EXISTS (SELECT 1 FROM file_manifests WHERE chunks @> ARRAY[$1]::text[])
+`
+
+// LoadManifest returns nil without an error for files stored as a blob.
+func LoadManifest(ex db.Executor, fileID string) (*Manifest, error) {
+	var m Manifest
+
+	err := ex.QueryRow(loadManifestSQL, fileID).Scan(pq.Array(&m.Chunks), pq.Array(&m.Sizes))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to load manifest")
+	}
+
+	return &m, nil
+}
+
+// SaveManifest stores a file as its chunks.
+func SaveManifest(ex db.Executor, fileID string, m *Manifest) error {
+	_, err := ex.Exec(saveManifestSQL, fileID, pq.Array(m.Chunks), pq.Array(m.Sizes))
+	return errors.Wrap(err, "Failed to save manifest")
+}
+
+// DeleteManifest is for once a file has a blob again.
+func DeleteManifest(ex db.Executor, fileID string) error {
+	_, err := ex.Exec(deleteManifestSQL, fileID)
+	return errors.Wrap(err, "Failed to delete manifest")
+}
+
+// ChunkReferenced reports whether a manifest still needs a chunk, in which
+// case it mustn't be removed.
+func ChunkReferenced(ex db.Executor, hash string) (bool, error) {
+	var referenced bool
+	err := ex.QueryRow(chunkReferencedSQL, hash).Scan(&referenced)
+	return referenced, errors.Wrap(err, "Failed to look up chunk")
+}
+
+// StoredFile is the content of a file, read from its blob or stitched
+// together from its chunks.
+type StoredFile interface {
+	io.ReadSeeker
+	io.ReaderAt
+	io.Closer
+	Size() int64
+}
+
+// OpenStored opens what is served for a file. The blob is used when there is
+// one, so a manifest only needs to be removed once it is compacted.
+func OpenStored(ex db.Executor, fs afero.Fs, f *models.File) (StoredFile, error) {
+	data, err := fs.Open(LocalPath(StoredHash(f)))
+	if err == nil {
+		info, err := data.Stat()
+		if err != nil {
+			data.Close()
+			return nil, err
+		}
+
+		return blobFile{data, info.Size()}, nil
+	}
+
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	m, merr := LoadManifest(ex, f.ID)
+	if merr != nil {
+		return nil, merr
+	}
+	if m == nil {
+		return nil, err
+	}
+
+	return OpenManifest(fs, m), nil
+}
+
+type blobFile struct {
+	afero.File
+	size int64
+}
+
+func (b blobFile) Size() int64 { return b.size }
+
+// ManifestReader reads the chunks of a manifest as one file, keeping a
+// single chunk open at a time. It is not safe for concurrent use.
+type ManifestReader struct {
+	fs      afero.Fs
+	chunks  []string
+	offsets []int64
+	pos     int64
+
+	current int
+	file    afero.File
+}
+
+// OpenManifest reads the chunks of m from fs. Chunks are opened as they are
+// needed, so missing ones are only noticed when read.
+func OpenManifest(fs afero.Fs, m *Manifest) *ManifestReader {
+	offsets := make([]int64, len(m.Sizes)+1)
+	for i, s := range m.Sizes {
+		offsets[i+1] = offsets[i] + s
+	}
+
+	return &ManifestReader{fs: fs, chunks: m.Chunks, offsets: offsets, current: -1}
+}
+
+// Size of the stitched file.
+func (r *ManifestReader) Size() int64 {
+	return r.offsets[len(r.offsets)-1]
+}
+
+// ReadAt reads from the chunks covering off.
+func (r *ManifestReader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("Negative offset")
+	}
+
+	n := 0
+	for n < len(p) && off < r.Size() {
+		i := sort.Search(len(r.chunks), func(i int) bool { return r.offsets[i+1] > off })
+
+		chunk, err := r.chunk(i)
+		if err != nil {
+			return n, err
+		}
+
+		end := len(p)
+		if left := r.offsets[i+1] - off; int64(end-n) > left {
+			end = n + int(left)
+		}
+
+		want := end - n
+		read, err := chunk.ReadAt(p[n:end], off-r.offsets[i])
+		n += read
+		off += int64(read)
+
+		if err != nil && err != io.EOF {
+			return n, err
+		}
+		if read < want {
+			// The chunk on disk is shorter than the manifest says.
+			return n, io.ErrUnexpectedEOF
+		}
+	}
+
+	if n < len(p) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+func (r *ManifestReader) Read(p []byte) (int, error) {
+	n, err := r.ReadAt(p, r.pos)
+	r.pos += int64(n)
+
+	if n > 0 && err == io.EOF {
+		err = nil
+	}
+
+	return n, err
+}
+
+// Seek moves where Read reads from next.
+func (r *ManifestReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case os.SEEK_SET:
+	case os.SEEK_CUR:
+		offset += r.pos
+	case os.SEEK_END:
+		offset += r.Size()
+	default:
+		return 0, errors.New("Invalid whence")
+	}
+
+	if offset < 0 {
+		return 0, errors.New("Negative position")
+	}
+
+	r.pos = offset
+	return offset, nil
+}
+
+// Close closes the open chunk.
+func (r *ManifestReader) Close() error {
+	if r.file == nil {
+		return nil
+	}
+
+	err := r.file.Close()
+	r.file, r.current = nil, -1
+	return err
+}
+
+func (r *ManifestReader) chunk(i int) (afero.File, error) {
+	if i == r.current {
+		return r.file, nil
+	}
+
+	r.Close()
+
+	f, err := r.fs.Open(ChunkPath(r.chunks[i]))
+	if err != nil {
+		return nil, errors.Wrapf(err, "Missing chunk %s", r.chunks[i])
+	}
+
+	r.file, r.current = f, i
+	return f, nil
+}
@@ -0,0 +1,94 @@
+package lib_test
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/zqzca/back/lib"
+)
+
+// manifest stores chunks and returns a manifest of them.
+func manifest(t *testing.T, fs afero.Fs, chunks ...string) *lib.Manifest {
+	m := &lib.Manifest{}
+	for i, c := range chunks {
+		hash := string('a' + byte(i))
+		if err := afero.WriteFile(fs, lib.ChunkPath(hash), []byte(c), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		m.Chunks = append(m.Chunks, hash)
+		m.Sizes = append(m.Sizes, int64(len(c)))
+	}
+
+	return m
+}
+
+func TestManifestReader(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	fs := afero.NewMemMapFs()
+	r := lib.OpenManifest(fs, manifest(t, fs, "hello ", "", "wor", "ld"))
+	defer r.Close()
+
+	a.Equal(int64(11), r.Size())
+
+	all, err := ioutil.ReadAll(r)
+	a.NoError(err)
+	a.Equal("hello world", string(all))
+
+	// Across chunk boundaries, including the empty one.
+	p := make([]byte, 5)
+	n, err := r.ReadAt(p, 4)
+	a.NoError(err)
+	a.Equal("o wor", string(p[:n]))
+
+	n, err = r.ReadAt(p, 8)
+	a.Equal(io.EOF, err)
+	a.Equal("rld", string(p[:n]))
+
+	pos, err := r.Seek(-5, os.SEEK_END)
+	a.NoError(err)
+	a.Equal(int64(6), pos)
+
+	rest, err := ioutil.ReadAll(r)
+	a.NoError(err)
+	a.Equal("world", string(rest))
+
+	n, err = r.Read(p)
+	a.Equal(0, n)
+	a.Equal(io.EOF, err)
+}
+
+func TestManifestReaderShortChunk(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	fs := afero.NewMemMapFs()
+	m := manifest(t, fs, "abc", "def")
+	m.Sizes[0] = 5
+
+	_, err := ioutil.ReadAll(lib.OpenManifest(fs, m))
+	a.Equal(io.ErrUnexpectedEOF, err)
+}
+
+func TestManifestReaderMissingChunk(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	fs := afero.NewMemMapFs()
+	m := manifest(t, fs, "abc", "def")
+	fs.Remove(lib.ChunkPath(m.Chunks[1]))
+
+	r := lib.OpenManifest(fs, m)
+	p := make([]byte, 3)
+	_, err := r.ReadAt(p, 0)
+	a.NoError(err)
+
+	_, err = r.ReadAt(p, 3)
+	a.Error(err)
+}
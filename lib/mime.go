@@ -0,0 +1,92 @@
+package lib
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// How much of a file is read to figure out what it is.
+const sniffLen = 512
+
+// Types a browser will execute script from when rendered inline.
+var activeTypes = map[string]bool{
+	"text/html":             true,
+	"application/xhtml+xml": true,
+	"image/svg+xml":         true,
+	"text/xml":              true,
+	"application/xml":       true,
+}
+
+// DetectType works out the content type of r from its contents. The name is
+// only used to refine a sniffed type, never to pick an active one.
+func DetectType(r io.ReadSeeker, name string) (string, error) {
+	if _, err := r.Seek(0, os.SEEK_SET); err != nil {
+		return "", err
+	}
+
+	buf := make([]byte, sniffLen)
+	n, err := io.ReadFull(r, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	buf = buf[:n]
+
+	if _, err := r.Seek(0, os.SEEK_SET); err != nil {
+		return "", err
+	}
+
+	// DetectContentType knows nothing of tarballs or zstd. Tar headers carry
+	// "ustar" at offset 257.
+	switch {
+	case len(buf) >= 262 && string(buf[257:262]) == "ustar":
+		return "application/x-tar", nil
+	case bytes.HasPrefix(buf, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		return "application/zstd", nil
+	}
+
+	sniffed := http.DetectContentType(buf)
+	base := MediaType(sniffed)
+
+	// DetectContentType has no idea about SVG, it calls it XML or text.
+	if (base == "text/xml" || base == "text/plain") && looksLikeSVG(buf) {
+		return "image/svg+xml", nil
+	}
+
+	// Sniffing only knows a handful of binary formats. If the extension
+	// agrees with "some bytes" or "some text", trust it as long as it is
+	// not something a browser would run.
+	if base == "application/octet-stream" || base == "text/plain" {
+		if byExt := mime.TypeByExtension(strings.ToLower(filepath.Ext(name))); len(byExt) > 0 {
+			if !ActiveType(byExt) && (base == "application/octet-stream" || strings.HasPrefix(byExt, "text/")) {
+				return byExt, nil
+			}
+		}
+	}
+
+	return sniffed, nil
+}
+
+func looksLikeSVG(b []byte) bool {
+	return bytes.Contains(bytes.ToLower(b), []byte("<svg"))
+}
+
+// MediaType strips parameters such as charset from a content type.
+func MediaType(contentType string) string {
+	t, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	}
+
+	return t
+}
+
+// ActiveType reports whether a browser could run script from contentType.
+func ActiveType(contentType string) bool {
+	t := MediaType(contentType)
+	return activeTypes[t] || strings.HasSuffix(t, "+xml")
+}
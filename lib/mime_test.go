@@ -0,0 +1,61 @@
+package lib_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zqzca/back/lib"
+)
+
+func TestDetectType(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	png := []byte("\x89PNG\x0D\x0A\x1A\x0A\x00\x00\x00\x0DIHDR")
+	svg := []byte(`<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script></svg>`)
+	html := []byte(`<!DOCTYPE html><html><script>alert(1)</script></html>`)
+	tar := make([]byte, 512)
+	copy(tar, "notes.txt")
+	copy(tar[257:], "ustar\x0000")
+
+	cases := []struct {
+		data     []byte
+		name     string
+		expected string
+	}{
+		{png, "cat.png", "image/png"},
+		{html, "cat.png", "text/html; charset=utf-8"},
+		{svg, "cat.png", "image/svg+xml"},
+		{[]byte("hello"), "notes.html", "text/plain; charset=utf-8"},
+		{[]byte("# hello"), "readme.css", "text/css; charset=utf-8"},
+		{[]byte{0, 1, 2, 3}, "a.zip", "application/zip"},
+		{[]byte{0, 1, 2, 3}, "a.svg", "application/octet-stream"},
+		{tar, "backup", "application/x-tar"},
+		{[]byte{0x28, 0xb5, 0x2f, 0xfd, 0}, "backup", "application/zstd"},
+		{[]byte{0x1f, 0x8b, 8, 0}, "backup", "application/x-gzip"},
+	}
+
+	for _, c := range cases {
+		r := bytes.NewReader(c.data)
+		typ, err := lib.DetectType(r, c.name)
+		a.Nil(err)
+		a.Equal(c.expected, typ, c.name)
+
+		// Leaves the reader at the start for whoever is next.
+		pos, _ := r.Seek(0, 1)
+		a.Equal(int64(0), pos)
+	}
+}
+
+func TestActiveType(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	a.True(lib.ActiveType("text/html; charset=utf-8"))
+	a.True(lib.ActiveType("image/svg+xml"))
+	a.True(lib.ActiveType("application/rss+xml"))
+	a.True(lib.ActiveType("TEXT/XML"))
+	a.False(lib.ActiveType("image/png"))
+	a.False(lib.ActiveType("text/plain; charset=utf-8"))
+}
@@ -0,0 +1,125 @@
+package lib
+
+import (
+	"image"
+	"math/bits"
+
+	"github.com/disintegration/imaging"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"github.com/zqzca/back/db"
+)
+
+// How far apart, in differing bits, two hashes of similar images can be.
+const (
+	DefaultSimilarDistance = 10
+	MaxSimilarDistance     = 20
+)
+
+// DHash is a 64 bit difference hash of an image. The image is shrunk to 9x8
+// grey pixels and each bit tells whether a pixel is brighter than its right
+// neighbour, so resized and re-encoded copies hash the same or nearly so.
+func DHash(img image.Image) uint64 {
+	small := imaging.Grayscale(imaging.Resize(img, 9, 8, imaging.Box))
+
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			left := small.Pix[small.PixOffset(x, y)]
+			right := small.Pix[small.PixOffset(x+1, y)]
+
+			hash <<= 1
+			if left > right {
+				hash |= 1
+			}
+		}
+	}
+
+	return hash
+}
+
+// Hamming returns the number of bits that differ between two hashes.
+func Hamming(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// Similar is a file whose hash is within some distance of another.
+type Similar struct {
+	FileID   string
+	Distance int
+}
+
+// Postgres before 14 has no bit_count, so the differing bits are counted as
+// the ones in its text form. Candidates are found through the band indexes
+// first, the expressions have to match the ones indexed.
+const similarSQL = `
+	SELECT id, distance FROM (
+		SELECT id, created_at,
+		length(replace((phash # $2)::bit(64)::text, '0', '')) AS distance
+		FROM files
+		WHERE phash IS NOT NULL AND id <> $1 AND state = $3
+		AND (((phash >> 48) & 65535) = ANY($6)
+		OR ((phash >> 32) & 65535) = ANY($7)
+		OR ((phash >> 16) & 65535) = ANY($8)
+		OR (phash & 65535) = ANY($9))
+	) AS f
+	WHERE distance <= $4
+	ORDER BY distance ASC, created_at DESC
+	LIMIT $5
+`
+
+// FindSimilar lists finished files other than fileID whose hash is at most
+// distance bits away from hash, closest first.
+func FindSimilar(ex db.Executor, fileID string, hash int64, distance, limit int) ([]Similar, error) {
+	bands := SimilarBands(uint64(hash), distance)
+	rows, err := ex.Query(similarSQL, fileID, hash, FileFinished, distance, limit,
+		pq.Array(bands[0]), pq.Array(bands[1]), pq.Array(bands[2]), pq.Array(bands[3]),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to find similar files")
+	}
+	defer rows.Close()
+
+	var out []Similar
+	for rows.Next() {
+		var s Similar
+		if err = rows.Scan(&s.FileID, &s.Distance); err != nil {
+			return nil, err
+		}
+
+		out = append(out, s)
+	}
+
+	return out, rows.Err()
+}
+
+// SimilarBands lists, for each 16 bit band of hash from the top, the values
+// that band can have in a hash at most distance bits away. By the pigeonhole
+// principle such a hash has at least one band within distance/4 bits of the
+// same band of hash, so one of the lists holds it.
+func SimilarBands(hash uint64, distance int) [4][]int64 {
+	var bands [4][]int64
+
+	for i := range bands {
+		band := (hash >> uint(48-16*i)) & 0xffff
+		bands[i] = flipBits([]int64{int64(band)}, band, 0, distance/4)
+	}
+
+	return bands
+}
+
+// flipBits appends every value that differs from band in at most n of the
+// bits from bit up.
+func flipBits(out []int64, band uint64, bit, n int) []int64 {
+	if n == 0 {
+		return out
+	}
+
+	for ; bit < 16; bit++ {
+		flipped := band ^ 1<<uint(bit)
+		out = append(out, int64(flipped))
+		out = flipBits(out, flipped, bit+1, n-1)
+	}
+
+	return out
+}
@@ -0,0 +1,89 @@
+package lib_test
+
+import (
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+
+	"github.com/disintegration/imaging"
+	"github.com/stretchr/testify/assert"
+	"github.com/zqzca/back/lib"
+)
+
+func gradient(w, h int, flip bool) image.Image {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := uint8(255 * x / w)
+			if flip {
+				v = 255 - v
+			}
+			if (y*4/h)%2 == 1 {
+				v /= 2
+			}
+			img.SetGray(x, y, color.Gray{Y: v})
+		}
+	}
+
+	return img
+}
+
+func TestHamming(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	a.Equal(0, lib.Hamming(0xff, 0xff))
+	a.Equal(8, lib.Hamming(0xff, 0))
+	a.Equal(64, lib.Hamming(0, ^uint64(0)))
+}
+
+func TestDHash(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	orig := gradient(640, 480, false)
+	resized := imaging.Resize(orig, 200, 0, imaging.Lanczos)
+	other := gradient(640, 480, true)
+
+	a.True(lib.Hamming(lib.DHash(orig), lib.DHash(resized)) <= 2)
+	a.True(lib.Hamming(lib.DHash(orig), lib.DHash(other)) > lib.MaxSimilarDistance)
+}
+
+func TestSimilarBands(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	// 1, 1+16, 1+16+120 values a band.
+	a.Len(lib.SimilarBands(0, 3)[0], 1)
+	a.Len(lib.SimilarBands(0, 4)[0], 17)
+	a.Len(lib.SimilarBands(0, 10)[0], 137)
+
+	bands := lib.SimilarBands(0x0123456789abcdef, 0)
+	a.Equal([]int64{0x0123}, bands[0])
+	a.Equal([]int64{0xcdef}, bands[3])
+
+	const hash = 0xf0f0f0f0f0f0f0f0
+	rnd := rand.New(rand.NewSource(1))
+
+	for distance := 0; distance <= lib.MaxSimilarDistance; distance++ {
+		bands := lib.SimilarBands(hash, distance)
+
+		for n := 0; n < 100; n++ {
+			other := uint64(hash)
+			for _, bit := range rnd.Perm(64)[:distance] {
+				other ^= 1 << uint(bit)
+			}
+
+			found := false
+			for i, values := range bands {
+				band := int64(other>>uint(48-16*i)) & 0xffff
+				for _, v := range values {
+					found = found || v == band
+				}
+			}
+
+			a.True(found, "distance %d, %x", distance, other)
+		}
+	}
+}
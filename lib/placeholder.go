@@ -0,0 +1,83 @@
+package lib
+
+import (
+	"fmt"
+	"image"
+	"sort"
+
+	"github.com/buckket/go-blurhash"
+	"github.com/disintegration/imaging"
+)
+
+// PaletteSize is how many dominant colors are kept for an image.
+const PaletteSize = 5
+
+// BlurHash encodes a blurry placeholder for an image. More components are
+// used along the longer side. The image is shrunk first since the hash only
+// keeps the lowest frequencies anyway.
+func BlurHash(img image.Image) (string, error) {
+	x, y := 4, 3
+	if img.Bounds().Dy() > img.Bounds().Dx() {
+		x, y = 3, 4
+	}
+
+	return blurhash.Encode(x, y, imaging.Fit(img, 32, 32, imaging.Box))
+}
+
+// DominantColors returns up to n of the most common colors in an image as
+// hex strings, most common first. Similar colors are counted together and
+// transparent pixels are ignored.
+func DominantColors(img image.Image, n int) []string {
+	small := imaging.Fit(img, 64, 64, imaging.Box)
+
+	type bucket struct {
+		r, g, b, count int
+	}
+
+	// 3 bits per channel is coarse enough to merge shades of the same color.
+	buckets := map[int]*bucket{}
+	for i := 0; i+3 < len(small.Pix); i += 4 {
+		if small.Pix[i+3] < 128 {
+			continue
+		}
+
+		r, g, b := int(small.Pix[i]), int(small.Pix[i+1]), int(small.Pix[i+2])
+		key := r>>5<<6 | g>>5<<3 | b>>5
+
+		bk, ok := buckets[key]
+		if !ok {
+			bk = &bucket{}
+			buckets[key] = bk
+		}
+
+		bk.r += r
+		bk.g += g
+		bk.b += b
+		bk.count++
+	}
+
+	sorted := make([]*bucket, 0, len(buckets))
+	for _, bk := range buckets {
+		sorted = append(sorted, bk)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].count != sorted[j].count {
+			return sorted[i].count > sorted[j].count
+		}
+
+		// Keep ties stable between runs.
+		return sorted[i].r+sorted[i].g+sorted[i].b > sorted[j].r+sorted[j].g+sorted[j].b
+	})
+
+	var colors []string
+	for _, bk := range sorted {
+		if len(colors) == n {
+			break
+		}
+
+		colors = append(colors, fmt.Sprintf("#%02x%02x%02x", bk.r/bk.count, bk.g/bk.count, bk.b/bk.count))
+	}
+
+	return colors
+}
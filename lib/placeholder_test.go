@@ -0,0 +1,38 @@
+package lib_test
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zqzca/back/lib"
+)
+
+func TestBlurHash(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	hash, err := lib.BlurHash(gradient(640, 480, false))
+	a.NoError(err)
+	// 1 + 1 + 4 + 2 * (4 * 3 - 1) characters for 4x3 components.
+	a.Len(hash, 28)
+
+	hash, err = lib.BlurHash(gradient(480, 640, false))
+	a.NoError(err)
+	a.Len(hash, 28)
+}
+
+func TestDominantColors(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	img := image.NewNRGBA(image.Rect(0, 0, 100, 100))
+	draw.Draw(img, img.Bounds(), &image.Uniform{color.NRGBA{255, 0, 0, 255}}, image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(0, 0, 100, 30), &image.Uniform{color.NRGBA{0, 0, 255, 255}}, image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(0, 0, 10, 10), image.Transparent, image.Point{}, draw.Src)
+
+	a.Equal([]string{"#ff0000", "#0000ff"}, lib.DominantColors(img, 5))
+	a.Equal([]string{"#ff0000"}, lib.DominantColors(img, 1))
+}
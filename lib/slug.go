@@ -0,0 +1,155 @@
+package lib
+
+import (
+	"bufio"
+	"crypto/rand"
+	"math/big"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+// DefaultSlugAlphabet matches what the identifier() SQL function used.
+const DefaultSlugAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// Bounds for slugs people pick themselves.
+const (
+	MinCustomSlug = 3
+	MaxCustomSlug = 64
+)
+
+// ReservedSlugs can't be claimed because they collide with routes.
+var ReservedSlugs = []string{"api", "assets", "ws", "d", "oembed"}
+
+// Errors returned by ValidSlug.
+var (
+	ErrSlugLength   = errors.New("Slug must be between 3 and 64 characters")
+	ErrSlugChars    = errors.New("Slug may only contain letters, numbers, - and _")
+	ErrSlugReserved = errors.New("Slug is reserved")
+)
+
+var customSlug = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// SlugGenerator makes random slugs. When Words is set slugs are made of
+// WordCount words joined with dashes, otherwise Length characters are picked
+// from Alphabet.
+type SlugGenerator struct {
+	Length   int
+	Alphabet string
+
+	Words     []string
+	WordCount int
+}
+
+// Slugs is used for every new file.
+var Slugs = SlugGenerator{Length: 7, Alphabet: DefaultSlugAlphabet}
+
+// Generate returns a new random slug.
+func (g SlugGenerator) Generate() (string, error) {
+	if len(g.Words) > 0 {
+		count := g.WordCount
+		if count < 1 {
+			count = 3
+		}
+
+		words := make([]string, count)
+		for i := range words {
+			n, err := randomIndex(len(g.Words))
+			if err != nil {
+				return "", err
+			}
+			words[i] = g.Words[n]
+		}
+
+		return strings.Join(words, "-"), nil
+	}
+
+	alphabet := []rune(g.Alphabet)
+	if len(alphabet) == 0 {
+		alphabet = []rune(DefaultSlugAlphabet)
+	}
+
+	length := g.Length
+	if length < 1 {
+		length = 7
+	}
+
+	slug := make([]rune, length)
+	for i := range slug {
+		n, err := randomIndex(len(alphabet))
+		if err != nil {
+			return "", err
+		}
+		slug[i] = alphabet[n]
+	}
+
+	return string(slug), nil
+}
+
+func randomIndex(max int) (int, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(max)))
+	if err != nil {
+		return 0, err
+	}
+
+	return int(n.Int64()), nil
+}
+
+// LoadWords reads a wordlist with one word per line. Blank lines, comments
+// and anything that isn't a valid slug on its own are skipped.
+func LoadWords(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var words []string
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		w := strings.ToLower(strings.TrimSpace(s.Text()))
+		if len(w) == 0 || strings.HasPrefix(w, "#") || !customSlug.MatchString(w) {
+			continue
+		}
+		words = append(words, w)
+	}
+
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(words) == 0 {
+		return nil, errors.New("Wordlist is empty: " + path)
+	}
+
+	return words, nil
+}
+
+// ValidSlug checks a slug someone asked for.
+func ValidSlug(slug string) error {
+	if len(slug) < MinCustomSlug || len(slug) > MaxCustomSlug {
+		return ErrSlugLength
+	}
+
+	if !customSlug.MatchString(slug) {
+		return ErrSlugChars
+	}
+
+	for _, r := range ReservedSlugs {
+		if strings.EqualFold(slug, r) {
+			return ErrSlugReserved
+		}
+	}
+
+	return nil
+}
+
+// UniqueViolation reports whether err was caused by the named unique
+// constraint.
+func UniqueViolation(err error, constraint string) bool {
+	pqErr, ok := errors.Cause(err).(*pq.Error)
+	return ok && pqErr.Code == "23505" && pqErr.Constraint == constraint
+}
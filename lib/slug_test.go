@@ -0,0 +1,69 @@
+package lib_test
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zqzca/back/lib"
+)
+
+func TestSlugGeneratorAlphabet(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	g := lib.SlugGenerator{Length: 12, Alphabet: "ab"}
+	slug, err := g.Generate()
+	a.Nil(err)
+	a.Len(slug, 12)
+	a.Empty(strings.Trim(slug, "ab"))
+
+	slug, err = lib.SlugGenerator{}.Generate()
+	a.Nil(err)
+	a.Len(slug, 7)
+}
+
+func TestSlugGeneratorWords(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	g := lib.SlugGenerator{Words: []string{"red", "fox"}, WordCount: 4}
+	slug, err := g.Generate()
+	a.Nil(err)
+
+	words := strings.Split(slug, "-")
+	a.Len(words, 4)
+	for _, w := range words {
+		a.Contains([]string{"red", "fox"}, w)
+	}
+}
+
+func TestLoadWords(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	f, err := ioutil.TempFile("", "words")
+	a.Nil(err)
+	defer os.Remove(f.Name())
+
+	f.WriteString("# animals\nOtter\n\nbad word\nbadger\n")
+	f.Close()
+
+	words, err := lib.LoadWords(f.Name())
+	a.Nil(err)
+	a.Equal([]string{"otter", "badger"}, words)
+}
+
+func TestValidSlug(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	a.Nil(lib.ValidSlug("my-cat_2"))
+	a.Equal(lib.ErrSlugLength, lib.ValidSlug("ab"))
+	a.Equal(lib.ErrSlugLength, lib.ValidSlug(strings.Repeat("a", 65)))
+	a.Equal(lib.ErrSlugChars, lib.ValidSlug("../etc"))
+	a.Equal(lib.ErrSlugReserved, lib.ValidSlug("API"))
+	a.Equal(lib.ErrSlugReserved, lib.ValidSlug("assets"))
+}
@@ -0,0 +1,275 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNotStrippable is returned for formats StripMetadata doesn't know.
+var ErrNotStrippable = errors.New("Can't strip metadata from this type")
+
+// StripMetadata copies an image without EXIF, GPS, XMP, ICC profiles or
+// comments. The image data itself is copied as is.
+func StripMetadata(r io.ReadSeeker, w io.Writer, contentType string) error {
+	if _, err := r.Seek(0, os.SEEK_SET); err != nil {
+		return err
+	}
+
+	switch MediaType(contentType) {
+	case "image/jpeg":
+		return stripJPEG(r, w)
+	case "image/png":
+		return stripPNG(r, w)
+	case "image/webp":
+		return stripWebP(r, w)
+	}
+
+	return ErrNotStrippable
+}
+
+// Strippable reports whether StripMetadata handles contentType.
+func Strippable(contentType string) bool {
+	switch MediaType(contentType) {
+	case "image/jpeg", "image/png", "image/webp":
+		return true
+	}
+
+	return false
+}
+
+// JPEG markers.
+const (
+	jpegSOI  = 0xD8
+	jpegSOS  = 0xDA
+	jpegAPP0 = 0xE0
+	jpegAPPE = 0xEE
+	jpegAPPF = 0xEF
+	jpegCOM  = 0xFE
+)
+
+// Everything but JFIF (APP0) and Adobe (APP14) application segments goes,
+// those two change how the image is decoded.
+func dropJPEGSegment(marker byte) bool {
+	return (marker > jpegAPP0 && marker <= jpegAPPF && marker != jpegAPPE) || marker == jpegCOM
+}
+
+func stripJPEG(r io.Reader, w io.Writer) error {
+	var soi [2]byte
+	if _, err := io.ReadFull(r, soi[:]); err != nil || soi[0] != 0xFF || soi[1] != jpegSOI {
+		return errors.New("Not a JPEG")
+	}
+
+	if _, err := w.Write(soi[:]); err != nil {
+		return err
+	}
+
+	var b [1]byte
+	for {
+		// Markers may be padded with any number of 0xFF bytes.
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return errors.Wrap(err, "Truncated JPEG")
+		}
+		if b[0] != 0xFF {
+			return errors.New("Invalid JPEG marker")
+		}
+
+		marker := byte(0xFF)
+		for marker == 0xFF {
+			if _, err := io.ReadFull(r, b[:]); err != nil {
+				return errors.Wrap(err, "Truncated JPEG")
+			}
+			marker = b[0]
+		}
+
+		// Standalone markers have no length.
+		if marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7) {
+			if _, err := w.Write([]byte{0xFF, marker}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		var size [2]byte
+		if _, err := io.ReadFull(r, size[:]); err != nil {
+			return errors.Wrap(err, "Truncated JPEG")
+		}
+
+		length := int64(binary.BigEndian.Uint16(size[:]))
+		if length < 2 {
+			return errors.New("Invalid JPEG segment length")
+		}
+
+		if dropJPEGSegment(marker) {
+			if _, err := io.CopyN(ioutil.Discard, r, length-2); err != nil {
+				return errors.Wrap(err, "Truncated JPEG")
+			}
+			continue
+		}
+
+		if _, err := w.Write([]byte{0xFF, marker, size[0], size[1]}); err != nil {
+			return err
+		}
+		if _, err := io.CopyN(w, r, length-2); err != nil {
+			return errors.Wrap(err, "Truncated JPEG")
+		}
+
+		// The rest is entropy coded image data, copy it all.
+		if marker == jpegSOS {
+			_, err := io.Copy(w, r)
+			return err
+		}
+	}
+}
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// Chunks needed to display a PNG or APNG the same way.
+var pngKeep = map[string]bool{
+	"IHDR": true, "PLTE": true, "IDAT": true, "IEND": true,
+	"tRNS": true, "gAMA": true, "cHRM": true, "sRGB": true,
+	"sBIT": true, "bKGD": true, "pHYs": true,
+	"acTL": true, "fcTL": true, "fdAT": true,
+}
+
+func stripPNG(r io.Reader, w io.Writer) error {
+	sig := make([]byte, len(pngSignature))
+	if _, err := io.ReadFull(r, sig); err != nil || !bytes.Equal(sig, pngSignature) {
+		return errors.New("Not a PNG")
+	}
+
+	if _, err := w.Write(sig); err != nil {
+		return err
+	}
+
+	var header [8]byte
+	for {
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return errors.Wrap(err, "Truncated PNG")
+		}
+
+		length := int64(binary.BigEndian.Uint32(header[:4]))
+		kind := string(header[4:8])
+
+		// Data and CRC.
+		rest := length + 4
+		if !pngKeep[kind] {
+			if _, err := io.CopyN(ioutil.Discard, r, rest); err != nil {
+				return errors.Wrap(err, "Truncated PNG")
+			}
+			continue
+		}
+
+		if _, err := w.Write(header[:]); err != nil {
+			return err
+		}
+		if _, err := io.CopyN(w, r, rest); err != nil {
+			return errors.Wrap(err, "Truncated PNG")
+		}
+
+		if kind == "IEND" {
+			return nil
+		}
+	}
+}
+
+// VP8X flags announcing the chunks we drop.
+const (
+	webpFlagICC  = 0x20
+	webpFlagEXIF = 0x08
+	webpFlagXMP  = 0x04
+)
+
+// VP8X is flags and the canvas size, always 10 bytes.
+const webpVP8XSize = 10
+
+var webpDrop = map[string]bool{"ICCP": true, "EXIF": true, "XMP ": true}
+
+type webpChunk struct {
+	header [8]byte
+	offset int64
+	padded int64
+}
+
+// The RIFF header holds the total size so the chunks we keep are found
+// first and copied in a second pass.
+func stripWebP(r io.ReadSeeker, w io.Writer) error {
+	var header [12]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil ||
+		string(header[:4]) != "RIFF" || string(header[8:]) != "WEBP" {
+		return errors.New("Not a WebP")
+	}
+
+	end := int64(binary.LittleEndian.Uint32(header[4:8])) + 8
+	offset := int64(12)
+	total := uint32(4)
+
+	var chunks []webpChunk
+	for offset+8 <= end {
+		var c webpChunk
+		if _, err := io.ReadFull(r, c.header[:]); err != nil {
+			return errors.Wrap(err, "Truncated WebP")
+		}
+
+		// Chunks are padded to an even size.
+		size := int64(binary.LittleEndian.Uint32(c.header[4:]))
+		c.offset = offset + 8
+		c.padded = size + size&1
+
+		// Sizes come from the file, nothing is trusted to fit.
+		if c.offset+c.padded > end {
+			return errors.New("WebP chunk runs past the end")
+		}
+		if string(c.header[:4]) == "VP8X" && size != webpVP8XSize {
+			return errors.New("Invalid WebP extended header")
+		}
+
+		if !webpDrop[string(c.header[:4])] {
+			chunks = append(chunks, c)
+			total += 8 + uint32(c.padded)
+		}
+
+		offset = c.offset + c.padded
+		if _, err := r.Seek(offset, os.SEEK_SET); err != nil {
+			return err
+		}
+	}
+
+	binary.LittleEndian.PutUint32(header[4:8], total)
+	if _, err := w.Write(header[:]); err != nil {
+		return err
+	}
+
+	for _, c := range chunks {
+		if _, err := r.Seek(c.offset, os.SEEK_SET); err != nil {
+			return err
+		}
+
+		if _, err := w.Write(c.header[:]); err != nil {
+			return err
+		}
+
+		if string(c.header[:4]) != "VP8X" {
+			if _, err := io.CopyN(w, r, c.padded); err != nil {
+				return errors.Wrap(err, "Truncated WebP")
+			}
+			continue
+		}
+
+		// The extended header says which optional chunks follow.
+		var flags [webpVP8XSize]byte
+		if _, err := io.ReadFull(r, flags[:]); err != nil {
+			return errors.Wrap(err, "Truncated WebP")
+		}
+		flags[0] &^= webpFlagICC | webpFlagEXIF | webpFlagXMP
+		if _, err := w.Write(flags[:]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,165 @@
+package lib_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zqzca/back/lib"
+)
+
+func testImage() image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	img.Set(1, 1, color.RGBA{255, 0, 0, 255})
+	return img
+}
+
+func jpegSegment(marker byte, data string) []byte {
+	b := []byte{0xFF, marker, 0, 0}
+	binary.BigEndian.PutUint16(b[2:], uint16(len(data)+2))
+	return append(b, data...)
+}
+
+func TestStripJPEG(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	var enc bytes.Buffer
+	a.Nil(jpeg.Encode(&enc, testImage(), nil))
+	raw := enc.Bytes()
+
+	// SOI, then EXIF, XMP, ICC and a comment, then the rest.
+	var in bytes.Buffer
+	in.Write(raw[:2])
+	in.Write(jpegSegment(0xE1, "Exif\x00\x00GPS secret"))
+	in.Write(jpegSegment(0xE1, "http://ns.adobe.com/xap/1.0/\x00secret"))
+	in.Write(jpegSegment(0xE2, "ICC_PROFILE\x00secret"))
+	in.Write(jpegSegment(0xFE, "secret comment"))
+	in.Write(raw[2:])
+
+	var out bytes.Buffer
+	a.Nil(lib.StripMetadata(bytes.NewReader(in.Bytes()), &out, "image/jpeg"))
+	a.NotContains(out.String(), "secret")
+	a.Equal(raw, out.Bytes())
+
+	_, err := jpeg.Decode(bytes.NewReader(out.Bytes()))
+	a.Nil(err)
+}
+
+func pngChunk(kind, data string) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, uint32(len(data)))
+	b = append(b, kind+data...)
+	crc := make([]byte, 4)
+	binary.BigEndian.PutUint32(crc, crc32.ChecksumIEEE([]byte(kind+data)))
+	return append(b, crc...)
+}
+
+func TestStripPNG(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	var enc bytes.Buffer
+	a.Nil(png.Encode(&enc, testImage()))
+	raw := enc.Bytes()
+
+	// Signature and IHDR are 33 bytes, metadata goes right after.
+	var in bytes.Buffer
+	in.Write(raw[:33])
+	in.Write(pngChunk("tEXt", "Comment\x00secret"))
+	in.Write(pngChunk("eXIf", "secret"))
+	in.Write(raw[33:])
+
+	var out bytes.Buffer
+	a.Nil(lib.StripMetadata(bytes.NewReader(in.Bytes()), &out, "image/png"))
+	a.NotContains(out.String(), "secret")
+	a.Equal(raw, out.Bytes())
+
+	_, err := png.Decode(bytes.NewReader(out.Bytes()))
+	a.Nil(err)
+}
+
+func webpChunk(kind string, data []byte) []byte {
+	b := append([]byte(kind), 0, 0, 0, 0)
+	binary.LittleEndian.PutUint32(b[4:], uint32(len(data)))
+	b = append(b, data...)
+	if len(data)%2 == 1 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+func riff(chunks ...[]byte) []byte {
+	body := []byte("WEBP")
+	for _, c := range chunks {
+		body = append(body, c...)
+	}
+
+	b := append([]byte("RIFF"), 0, 0, 0, 0)
+	binary.LittleEndian.PutUint32(b[4:], uint32(len(body)))
+	return append(b, body...)
+}
+
+func TestStripWebP(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	vp8x := []byte{0x20 | 0x08 | 0x04 | 0x10, 0, 0, 0, 7, 0, 0, 7, 0, 0}
+	pixels := []byte("fake image data")
+
+	in := riff(
+		webpChunk("VP8X", vp8x),
+		webpChunk("ICCP", []byte("secret")),
+		webpChunk("VP8L", pixels),
+		webpChunk("EXIF", []byte("secret")),
+		webpChunk("XMP ", []byte("secret!")),
+	)
+
+	var out bytes.Buffer
+	a.Nil(lib.StripMetadata(bytes.NewReader(in), &out, "image/webp"))
+	a.NotContains(out.String(), "secret")
+
+	// Only the alpha flag is left.
+	want := riff(
+		webpChunk("VP8X", append([]byte{0x10}, vp8x[1:]...)),
+		webpChunk("VP8L", pixels),
+	)
+	a.Equal(want, out.Bytes())
+}
+
+func TestStripWebPBadChunks(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	pixels := []byte("fake image data")
+
+	// A VP8X claiming 4GB, one too short and a chunk past the RIFF size.
+	huge := webpChunk("VP8X", make([]byte, 10))
+	binary.LittleEndian.PutUint32(huge[4:], 0xfffffffe)
+	short := webpChunk("VP8X", []byte{0, 0, 0, 0})
+	long := webpChunk("VP8L", pixels)
+	binary.LittleEndian.PutUint32(long[4:], 100)
+
+	for _, c := range [][]byte{huge, short, long} {
+		in := riff(c, webpChunk("VP8L", pixels))
+
+		var out bytes.Buffer
+		a.NotNil(lib.StripMetadata(bytes.NewReader(in), &out, "image/webp"))
+	}
+}
+
+func TestStripUnknown(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	a.False(lib.Strippable("image/gif"))
+	a.True(lib.Strippable("image/jpeg; charset=binary"))
+	a.Equal(lib.ErrNotStrippable, lib.StripMetadata(bytes.NewReader(nil), &bytes.Buffer{}, "image/gif"))
+	a.NotNil(lib.StripMetadata(bytes.NewReader([]byte("nope")), &bytes.Buffer{}, "image/png"))
+}
@@ -0,0 +1,57 @@
+package lib
+
+import "github.com/zqzca/back/models"
+
+// PickThumbnail chooses the rendition closest to what was asked for. The
+// smallest one at least size wide wins, otherwise the biggest there is. Kind
+// and format are only preferences, any thumbnail is better than none.
+// Animated thumbnails are only picked when asked for.
+func PickThumbnail(thumbs models.ThumbnailSlice, kind string, size int, format string) *models.Thumbnail {
+	candidates := filter(thumbs, func(t *models.Thumbnail) bool { return t.Kind == kind })
+	if len(candidates) == 0 {
+		candidates = filter(thumbs, func(t *models.Thumbnail) bool { return t.Kind != ThumbnailAnimated })
+	}
+	if len(candidates) == 0 {
+		candidates = thumbs
+	}
+
+	width := -1
+	for _, t := range candidates {
+		switch {
+		case width == -1:
+			width = t.Width
+		case width < size:
+			if t.Width > width {
+				width = t.Width
+			}
+		case t.Width >= size && t.Width < width:
+			width = t.Width
+		}
+	}
+
+	candidates = filter(candidates, func(t *models.Thumbnail) bool { return t.Width == width })
+	for _, f := range []string{format, ThumbnailJPEG} {
+		for _, t := range candidates {
+			if t.Format == f {
+				return t
+			}
+		}
+	}
+
+	if len(candidates) > 0 {
+		return candidates[0]
+	}
+
+	return nil
+}
+
+func filter(thumbs models.ThumbnailSlice, keep func(*models.Thumbnail) bool) models.ThumbnailSlice {
+	var out models.ThumbnailSlice
+	for _, t := range thumbs {
+		if keep(t) {
+			out = append(out, t)
+		}
+	}
+
+	return out
+}
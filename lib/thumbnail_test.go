@@ -0,0 +1,52 @@
+package lib_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zqzca/back/lib"
+	"github.com/zqzca/back/models"
+)
+
+func rendition(id, kind string, width int, format string) *models.Thumbnail {
+	return &models.Thumbnail{ID: id, Kind: kind, Width: width, Format: format}
+}
+
+func TestPickThumbnail(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	thumbs := models.ThumbnailSlice{
+		rendition("sq200", lib.ThumbnailSquare, 200, lib.ThumbnailJPEG),
+		rendition("sq200w", lib.ThumbnailSquare, 200, lib.ThumbnailWebP),
+		rendition("sq400", lib.ThumbnailSquare, 400, lib.ThumbnailJPEG),
+		rendition("fit400", lib.ThumbnailFit, 400, lib.ThumbnailJPEG),
+		rendition("fit800", lib.ThumbnailFit, 800, lib.ThumbnailJPEG),
+		rendition("fit800w", lib.ThumbnailFit, 800, lib.ThumbnailWebP),
+	}
+
+	a.Equal("sq200", lib.PickThumbnail(thumbs, lib.ThumbnailSquare, 200, lib.ThumbnailJPEG).ID)
+	a.Equal("sq200w", lib.PickThumbnail(thumbs, lib.ThumbnailSquare, 150, lib.ThumbnailWebP).ID)
+	a.Equal("sq400", lib.PickThumbnail(thumbs, lib.ThumbnailSquare, 300, lib.ThumbnailJPEG).ID)
+	// Nothing big enough, so the biggest one.
+	a.Equal("sq400", lib.PickThumbnail(thumbs, lib.ThumbnailSquare, 1000, lib.ThumbnailJPEG).ID)
+	// No WebP at 400, falls back to JPEG.
+	a.Equal("sq400", lib.PickThumbnail(thumbs, lib.ThumbnailSquare, 400, lib.ThumbnailWebP).ID)
+	a.Equal("fit800w", lib.PickThumbnail(thumbs, lib.ThumbnailFit, 500, lib.ThumbnailWebP).ID)
+	a.Equal(400, lib.PickThumbnail(thumbs, "huge", 300, lib.ThumbnailJPEG).Width)
+	a.Nil(lib.PickThumbnail(nil, lib.ThumbnailFit, 300, lib.ThumbnailJPEG))
+}
+
+func TestPickThumbnailAnimated(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	thumbs := models.ThumbnailSlice{
+		rendition("anim", lib.ThumbnailAnimated, 200, lib.ThumbnailGIF),
+		rendition("sq200", lib.ThumbnailSquare, 200, lib.ThumbnailJPEG),
+	}
+
+	a.Equal("anim", lib.PickThumbnail(thumbs, lib.ThumbnailAnimated, 200, lib.ThumbnailWebP).ID)
+	a.Equal("sq200", lib.PickThumbnail(thumbs, "huge", 200, lib.ThumbnailJPEG).ID)
+	a.Equal("sq200", lib.PickThumbnail(thumbs[1:], lib.ThumbnailAnimated, 200, lib.ThumbnailJPEG).ID)
+}
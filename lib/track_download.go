@@ -5,10 +5,13 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"net/url"
+	"strings"
 
 	null "gopkg.in/nullbio/null.v5"
 
 	"github.com/zqzca/back/db"
+	"github.com/zqzca/back/geoip"
 	"github.com/zqzca/back/models"
 )
 
@@ -24,28 +27,59 @@ func extractIP(remoteAddr string) (string, error) {
 		return "", errors.New("Failed to parse IP")
 	}
 
-	// forward := req.Header.Get("X-Forwarded-For")
+	return ip, nil
+}
 
-	// fmt.Fprintf(w, "<p>IP: %s</p>", ip)
-	// fmt.Fprintf(w, "<p>Port: %s</p>", port)
-	// fmt.Fprintf(w, "<p>Forwarded for: %s</p>", forward)
+// GeoIP enriches downloads with a country and ASN when set.
+var GeoIP *geoip.DB
 
-	return ip, nil
+// Transfer describes what was sent for a download.
+type Transfer struct {
+	CacheHit  bool
+	Bytes     int64
+	Completed bool
 }
 
 // TrackDownload stores a record for the download.
-func TrackDownload(db db.Executor, fileID string, r *http.Request, hit bool) {
-	ip, err := extractIP(r.RemoteAddr)
+func TrackDownload(db db.Executor, fileID string, r *http.Request, t Transfer) {
+	ip, err := ClientIP(r)
 
 	if err != nil {
 		fmt.Println("Failed to figure out IP", err.Error())
 		return
 	}
 
+	ua := ParseUserAgent(r.UserAgent())
+
 	d := models.Download{
-		FileID:   null.StringFrom(fileID),
-		Ip:       null.StringFrom(ip),
-		CacheHit: hit,
+		FileID:    null.StringFrom(fileID),
+		Ip:        null.StringFrom(ip),
+		CacheHit:  t.CacheHit,
+		UaFamily:  null.StringFrom(ua.Family),
+		Bot:       ua.Bot,
+		BytesSent: t.Bytes,
+		Completed: t.Completed,
+	}
+
+	geo := GeoIP.Lookup(net.ParseIP(ip))
+	if len(geo.Country) > 0 {
+		d.Country = null.StringFrom(geo.Country)
+	}
+
+	if geo.ASN > 0 {
+		d.Asn = null.Int64From(int64(geo.ASN))
+	}
+
+	if ref := r.Referer(); len(ref) > 0 {
+		d.Referrer = null.StringFrom(ref)
+
+		if u, err := url.Parse(ref); err == nil && len(u.Host) > 0 {
+			d.ReferrerHost = null.StringFrom(strings.ToLower(u.Hostname()))
+		}
+	}
+
+	if raw := r.UserAgent(); len(raw) > 0 {
+		d.UserAgent = null.StringFrom(raw)
 	}
 
 	if err := d.Insert(db); err != nil {
@@ -0,0 +1,95 @@
+package lib
+
+import (
+	"net/http"
+	"strings"
+)
+
+// UserAgent is a coarse classification of a User-Agent header.
+type UserAgent struct {
+	Family string
+	Bot    bool
+}
+
+type uaRule struct {
+	token  string
+	family string
+	bot    bool
+}
+
+// Checked in order against the lowercased header, so more specific tokens
+// need to come before the browsers they imitate (Edge and Opera both claim to
+// be Chrome, Chrome claims to be Safari).
+var uaRules = []uaRule{
+	{"googlebot", "Googlebot", true},
+	{"bingbot", "Bingbot", true},
+	{"yandexbot", "YandexBot", true},
+	{"baiduspider", "Baiduspider", true},
+	{"duckduckbot", "DuckDuckBot", true},
+	{"slackbot", "Slackbot", true},
+	{"twitterbot", "Twitterbot", true},
+	{"facebookexternalhit", "Facebook", true},
+	{"discordbot", "Discordbot", true},
+	{"telegrambot", "TelegramBot", true},
+	{"whatsapp", "WhatsApp", true},
+	{"skypeuripreview", "Skype", true},
+	{"embedly", "Embedly", true},
+	{"curl/", "curl", false},
+	{"wget/", "Wget", false},
+	{"python-requests", "python-requests", false},
+	{"go-http-client", "Go", false},
+	{"edg/", "Edge", false},
+	{"edge/", "Edge", false},
+	{"opr/", "Opera", false},
+	{"opera", "Opera", false},
+	{"firefox/", "Firefox", false},
+	{"chrome/", "Chrome", false},
+	{"crios/", "Chrome", false},
+	{"safari/", "Safari", false},
+	{"msie ", "Internet Explorer", false},
+	{"trident/", "Internet Explorer", false},
+}
+
+// Anything that slipped through the rules above but looks automated.
+var botTokens = []string{"bot", "crawler", "spider", "preview", "fetcher"}
+
+// ParseUserAgent maps a User-Agent header to a browser or client family and
+// flags crawlers and link unfurlers as bots.
+func ParseUserAgent(ua string) UserAgent {
+	lower := strings.ToLower(ua)
+
+	if len(lower) == 0 {
+		return UserAgent{Family: "Unknown"}
+	}
+
+	for _, r := range uaRules {
+		if strings.Contains(lower, r.token) {
+			return UserAgent{Family: r.family, Bot: r.bot}
+		}
+	}
+
+	for _, t := range botTokens {
+		if strings.Contains(lower, t) {
+			return UserAgent{Family: "Other Bot", Bot: true}
+		}
+	}
+
+	return UserAgent{Family: "Other"}
+}
+
+// WantsPreview reports whether a request for a file should get an HTML page
+// instead of the raw bytes. Browsers navigating to a link ask for text/html
+// while the same browser loading an <img> does not, and link unfurlers
+// always get the page so they can read its meta tags. Anything else, curl
+// included, gets the file. ?raw=1 always gets the file.
+func WantsPreview(r *http.Request) bool {
+	if r.URL.Query().Get("raw") == "1" {
+		return false
+	}
+
+	if ParseUserAgent(r.UserAgent()).Bot {
+		return true
+	}
+
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
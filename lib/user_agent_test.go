@@ -0,0 +1,65 @@
+package lib_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zqzca/back/lib"
+)
+
+func TestParseUserAgent(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	cases := []struct {
+		ua     string
+		family string
+		bot    bool
+	}{
+		{"", "Unknown", false},
+		{"curl/7.50.3", "curl", false},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/54.0.2840.71 Safari/537.36", "Chrome", false},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/51.0.2704.79 Safari/537.36 Edge/14.14393", "Edge", false},
+		{"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_12_1) AppleWebKit/602.2.14 (KHTML, like Gecko) Version/10.0.1 Safari/602.2.14", "Safari", false},
+		{"Mozilla/5.0 (X11; Linux x86_64; rv:49.0) Gecko/20100101 Firefox/49.0", "Firefox", false},
+		{"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", "Googlebot", true},
+		{"Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)", "Slackbot", true},
+		{"facebookexternalhit/1.1", "Facebook", true},
+		{"SomeCrawler/1.0", "Other Bot", true},
+		{"Lynx/2.8.9", "Other", false},
+	}
+
+	for _, c := range cases {
+		ua := lib.ParseUserAgent(c.ua)
+		a.Equal(c.family, ua.Family, c.ua)
+		a.Equal(c.bot, ua.Bot, c.ua)
+	}
+}
+
+func TestWantsPreview(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	chrome := "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/54.0.2840.71 Safari/537.36"
+	cases := []struct {
+		url     string
+		ua      string
+		accept  string
+		preview bool
+	}{
+		{"/d/abc", chrome, "text/html,application/xhtml+xml,*/*;q=0.8", true},
+		{"/d/abc?raw=1", chrome, "text/html,application/xhtml+xml,*/*;q=0.8", false},
+		{"/d/abc", chrome, "image/webp,image/*,*/*;q=0.8", false},
+		{"/d/abc", "curl/7.50.3", "*/*", false},
+		{"/d/abc", "Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)", "*/*", true},
+		{"/d/abc?raw=1", "Twitterbot/1.0", "*/*", false},
+	}
+
+	for _, c := range cases {
+		r, _ := http.NewRequest("GET", c.url, nil)
+		r.Header.Set("User-Agent", c.ua)
+		r.Header.Set("Accept", c.accept)
+		a.Equal(c.preview, lib.WantsPreview(r), c.url+" "+c.ua)
+	}
+}
@@ -1,11 +1,16 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"net/url"
 	"os"
 
 	"github.com/spf13/cobra"
 	"github.com/zqzca/back/app"
+	"github.com/zqzca/back/lib"
+	"github.com/zqzca/back/processors"
+	"github.com/zqzca/back/viewer"
 )
 
 var cdn string
@@ -13,6 +18,33 @@ var secure bool
 var livereload bool
 var bindhttp string
 var bindscp string
+var trustedProxies []string
+var forwardedHeader string
+var geoipPath string
+var appHost string
+var userContentHost string
+var viewerLimit int64
+var workers int
+var privacy bool
+var discardOriginals bool
+var clamdAddr string
+var clamdMaxSize int64
+var clamdOversized string
+var manifestMinSize int64
+var imagePresets []string
+var imageSecret string
+var thumbnails []string
+var thumbnailFormats []string
+var slugLength int
+var slugAlphabet string
+var slugWords string
+var slugWordCount int
+var reprocessAll bool
+var reprocessType string
+var reprocessSince string
+var reprocessRate float64
+var compactAll bool
+var compactRate float64
 
 func main() {
 	var rootCmd = &cobra.Command{
@@ -33,13 +65,102 @@ func main() {
 				CDNURL:       cdn,
 				HTTPBindAddr: bindhttp,
 				SCPBindAddr:  bindscp,
+
+				TrustedProxies:  trustedProxies,
+				ForwardedHeader: forwardedHeader,
+				GeoIPPath:       geoipPath,
+
+				AppHost:         appHost,
+				UserContentHost: userContentHost,
+
+				ViewerLimit: viewerLimit,
+				Workers:     workers,
+
+				Thumbnails:       thumbnails,
+				ThumbnailFormats: thumbnailFormats,
+
+				ImagePresets: imagePresets,
+				ImageSecret:  imageSecret,
+
+				Privacy:          privacy,
+				DiscardOriginals: discardOriginals,
+
+				Clamd:           clamdAddr,
+				ClamdMaxSize:    clamdMaxSize,
+				ClamdOversized:  clamdOversized,
+				ManifestMinSize: manifestMinSize,
+
+				SlugLength:    slugLength,
+				SlugAlphabet:  slugAlphabet,
+				SlugWords:     slugWords,
+				SlugWordCount: slugWordCount,
 			}
 
 			app.Run(cfg)
 		},
 	}
 
+	var signImageCmd = &cobra.Command{
+		Use:   "sign-image <slug> <options>",
+		Short: "Prints a signed /i/ path",
+		Long:  "Prints a signed /i/ path for resize options that aren't a preset, eg. sign-image abc123 'w=300&h=300&fit=fill'",
+		Args:  cobra.ExactArgs(2),
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(imageSecret) == 0 {
+				return errors.New("--image-secret is required")
+			}
+
+			q, err := url.ParseQuery(args[1])
+			if err != nil {
+				return err
+			}
+
+			o, err := lib.ParseImageOptions(q)
+			if err != nil {
+				return err
+			}
+
+			q.Set("s", lib.SignImage([]byte(imageSecret), args[0], o))
+			fmt.Println("/i/" + args[0] + "?" + q.Encode())
+			return nil
+		},
+	}
+
+	var reprocessCmd = &cobra.Command{
+		Use:   "reprocess [slug...]",
+		Short: "Runs finished files through the processors again",
+		Long:  "Queues finished files for the running server to process again, eg. reprocess --type 'image/*' --since 720h",
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return app.Reprocess(app.ReprocessOptions{
+				All:   reprocessAll,
+				Slugs: args,
+				Type:  reprocessType,
+				Since: reprocessSince,
+				Rate:  reprocessRate,
+			})
+		},
+	}
+
+	var compactCmd = &cobra.Command{
+		Use:   "compact [slug...]",
+		Short: "Writes files stored as chunks out into single blobs",
+		Long:  "Queues files stored as a manifest of chunks for the running server to compact into single blobs",
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return app.Compact(app.CompactOptions{
+				All:   compactAll,
+				Slugs: args,
+				Rate:  compactRate,
+			})
+		},
+	}
+
 	rootCmd.AddCommand(serveCmd)
+	rootCmd.AddCommand(signImageCmd)
+	rootCmd.AddCommand(reprocessCmd)
+	rootCmd.AddCommand(compactCmd)
 
 	serveFlags := serveCmd.Flags()
 	serveFlags.BoolVar(&secure, "secure", false, "Serve HTTP2 instead of HTTP")
@@ -47,6 +168,39 @@ func main() {
 	serveFlags.StringVar(&cdn, "cdn", "/assets", "URL for assets")
 	serveFlags.StringVar(&bindhttp, "http", ":3001", "HTTP Bind address")
 	serveFlags.StringVar(&bindscp, "scp", ":2020", "SCP Bind address")
+	serveFlags.StringVar(&appHost, "host", "", "Host serving the app")
+	serveFlags.StringVar(&userContentHost, "usercontent-host", "", "Separate host serving uploaded files")
+	serveFlags.StringVar(&geoipPath, "geoip", "", "Path to a MaxMind .mmdb file for download locations")
+	serveFlags.Int64Var(&viewerLimit, "viewer-limit", viewer.DefaultLimit, "Bytes of a text file shown inline")
+	serveFlags.IntVar(&slugLength, "slug-length", 7, "Length of generated slugs")
+	serveFlags.StringVar(&slugAlphabet, "slug-alphabet", lib.DefaultSlugAlphabet, "Characters generated slugs are made of")
+	serveFlags.StringVar(&slugWords, "slug-words", "", "Wordlist file for readable slugs, one word per line")
+	serveFlags.IntVar(&slugWordCount, "slug-word-count", 3, "Words in a readable slug")
+	serveFlags.IntVar(&workers, "workers", 2, "Files processed at the same time")
+	serveFlags.StringSliceVar(&thumbnails, "thumbnail", nil, "Thumbnail rendition as kind:width, square or fit (default square:200,square:400,fit:400,fit:800,fit:1600)")
+	serveFlags.StringSliceVar(&thumbnailFormats, "thumbnail-format", nil, "Thumbnail format, jpeg or webp (default jpeg). WebP thumbnails are lossless")
+	serveFlags.StringArrayVar(&imagePresets, "image-preset", []string{"w=400", "w=800", "w=1600"}, "Resize options anyone may request from /i/:slug, as a query string")
+	serveFlags.StringVar(&imageSecret, "image-secret", "", "Key for signing other /i/:slug options")
+	serveFlags.BoolVar(&privacy, "privacy", false, "Strip EXIF, GPS and other metadata from images unless the upload opts out")
+	serveFlags.BoolVar(&discardOriginals, "discard-originals", false, "Delete originals of stripped images instead of keeping them private")
+	serveFlags.Int64Var(&manifestMinSize, "manifest-min-size", 256<<20, "Bytes from which uploads no processor reads are kept as their chunks, 0 to always build one file")
+	serveFlags.StringVar(&clamdAddr, "clamd", "", "clamd address uploads are scanned with, host:port or a unix socket path")
+	serveFlags.Int64Var(&clamdMaxSize, "clamd-max-size", 25<<20, "Biggest file sent to clamd, match its StreamMaxLength")
+	serveFlags.StringVar(&clamdOversized, "clamd-oversized", processors.OversizedReject, "What happens to files over --clamd-max-size, reject or unscanned")
+	serveFlags.StringSliceVar(&trustedProxies, "trusted-proxy", nil, "CIDR of a proxy allowed to set the forwarded header")
+	serveFlags.StringVar(&forwardedHeader, "forwarded-header", lib.HeaderXForwardedFor, "Header trusted proxies write the client address to, X-Forwarded-For or Forwarded")
+
+	reprocessFlags := reprocessCmd.Flags()
+	reprocessFlags.BoolVar(&reprocessAll, "all", false, "Reprocess every finished file")
+	reprocessFlags.StringVar(&reprocessType, "type", "", "Only files of this type, * matches anything, eg. image/*")
+	reprocessFlags.StringVar(&reprocessSince, "since", "", "Only files uploaded since a date or within a duration, eg. 2006-01-02 or 72h")
+	reprocessFlags.Float64Var(&reprocessRate, "rate", 10, "Files queued a second, 0 for no limit")
+
+	compactFlags := compactCmd.Flags()
+	compactFlags.BoolVar(&compactAll, "all", false, "Compact every file stored as chunks")
+	compactFlags.Float64Var(&compactRate, "rate", 10, "Files queued a second, 0 for no limit")
+
+	signImageCmd.Flags().StringVar(&imageSecret, "image-secret", "", "Key used by the server to check signatures")
 
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
@@ -0,0 +1,39 @@
+package media
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// STREAMINFO is always the first metadata block.
+func probeFLAC(r io.ReadSeeker) (*Info, error) {
+	if _, err := r.Seek(4, os.SEEK_SET); err != nil {
+		return nil, err
+	}
+
+	var block [4 + 34]byte
+	if _, err := io.ReadFull(r, block[:]); err != nil {
+		return nil, errors.Wrap(err, "Failed to read STREAMINFO")
+	}
+
+	if block[0]&0x7f != 0 {
+		return nil, errors.New("FLAC doesn't start with STREAMINFO")
+	}
+
+	// 20 bits of sample rate, 3 of channels, 5 of bits per sample and 36 of
+	// total samples.
+	info := block[4+10:]
+	packed := binary.BigEndian.Uint64(info[:8])
+	rate := packed >> 44
+	samples := packed & (1<<36 - 1)
+
+	out := &Info{Format: FLAC, AudioCodec: "flac"}
+	if rate > 0 {
+		out.Duration = float64(samples) / float64(rate)
+	}
+
+	return out, nil
+}
@@ -0,0 +1,356 @@
+package media
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var ebmlMagic = []byte{0x1a, 0x45, 0xdf, 0xa3}
+
+// EBML element IDs, markers included.
+const (
+	ebmlHeader     = 0x1a45dfa3
+	ebmlDocType    = 0x4282
+	mkvSegment     = 0x18538067
+	mkvInfo        = 0x1549a966
+	mkvTimecode    = 0x2ad7b1
+	mkvDuration    = 0x4489
+	mkvTitle       = 0x7ba9
+	mkvTracks      = 0x1654ae6b
+	mkvTrackEntry  = 0xae
+	mkvTrackType   = 0x83
+	mkvCodecID     = 0x86
+	mkvVideo       = 0xe0
+	mkvPixelWidth  = 0xb0
+	mkvPixelHeight = 0xba
+	mkvTags        = 0x1254c367
+	mkvTag         = 0x7373
+	mkvSimpleTag   = 0x67c8
+	mkvTagName     = 0x45a3
+	mkvTagString   = 0x4487
+)
+
+// Elements whose children are read.
+var mkvMasters = map[uint32]bool{
+	ebmlHeader:    true,
+	mkvSegment:    true,
+	mkvInfo:       true,
+	mkvTracks:     true,
+	mkvTrackEntry: true,
+	mkvVideo:      true,
+	mkvTags:       true,
+	mkvTag:        true,
+	mkvSimpleTag:  true,
+}
+
+var mkvCodecs = map[string]string{
+	"V_MPEG4/ISO/AVC":  "h264",
+	"V_MPEGH/ISO/HEVC": "hevc",
+	"V_MPEG4/ISO/ASP":  "mpeg4",
+	"A_MPEG/L3":        "mp3",
+	"A_AC3":            "ac3",
+	"A_EAC3":           "eac3",
+}
+
+// A file is given up on after this many elements.
+const maxElements = 100000
+
+// Elements with an unknown size run to the end of their parent.
+const unknownSize = -1
+
+type mkvTrack struct {
+	kind          uint64
+	codec         string
+	width, height int
+}
+
+type mkvProbe struct {
+	r        io.ReadSeeker
+	info     *Info
+	scale    uint64
+	duration float64
+	track    *mkvTrack
+	tagName  string
+	tagValue string
+	elements int
+}
+
+func probeMatroska(r io.ReadSeeker, size int64) (*Info, error) {
+	p := &mkvProbe{r: r, info: &Info{Format: Matroska}, scale: 1000000}
+
+	if _, err := r.Seek(0, os.SEEK_SET); err != nil {
+		return nil, err
+	}
+
+	if err := p.walk(size); err != nil && len(p.info.VideoCodec)+len(p.info.AudioCodec) == 0 {
+		return nil, err
+	}
+
+	p.info.Duration = p.duration * float64(p.scale) / 1e9
+	return p.info, nil
+}
+
+func (p *mkvProbe) walk(end int64) error {
+	for {
+		start, err := p.r.Seek(0, os.SEEK_CUR)
+		if err != nil {
+			return err
+		}
+		if start >= end {
+			return nil
+		}
+
+		p.elements++
+		if p.elements > maxElements {
+			return errors.New("Too many elements")
+		}
+
+		id, err := readElementID(p.r)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		size, err := readElementSize(p.r)
+		if err != nil {
+			return err
+		}
+
+		offset, err := p.r.Seek(0, os.SEEK_CUR)
+		if err != nil {
+			return err
+		}
+
+		elementEnd := offset + size
+		if size == unknownSize || elementEnd > end {
+			elementEnd = end
+		}
+
+		if mkvMasters[id] {
+			p.open(id)
+			err = p.walk(elementEnd)
+			p.close(id)
+			if err != nil {
+				return err
+			}
+		} else if size == unknownSize {
+			// Clusters of live streams can't be skipped.
+			return nil
+		} else if err = p.value(id, size); err != nil {
+			return err
+		}
+
+		if _, err = p.r.Seek(elementEnd, os.SEEK_SET); err != nil {
+			return err
+		}
+	}
+}
+
+func (p *mkvProbe) open(id uint32) {
+	switch id {
+	case mkvTrackEntry:
+		p.track = &mkvTrack{}
+	case mkvSimpleTag:
+		p.tagName, p.tagValue = "", ""
+	}
+}
+
+func (p *mkvProbe) close(id uint32) {
+	switch id {
+	case mkvTrackEntry:
+		p.endTrack()
+	case mkvSimpleTag:
+		p.endTag()
+	}
+}
+
+// Only small values are read, the rest are skipped.
+func (p *mkvProbe) value(id uint32, size int64) error {
+	switch id {
+	case ebmlDocType, mkvTimecode, mkvDuration, mkvTitle, mkvTrackType,
+		mkvCodecID, mkvPixelWidth, mkvPixelHeight, mkvTagName, mkvTagString:
+	default:
+		return nil
+	}
+
+	if size > 1024 {
+		return nil
+	}
+
+	b := make([]byte, size)
+	if _, err := io.ReadFull(p.r, b); err != nil {
+		return errors.Wrap(err, "Failed to read element")
+	}
+
+	switch id {
+	case ebmlDocType:
+		if string(b) == "webm" {
+			p.info.Format = WebM
+		}
+	case mkvTimecode:
+		p.scale = readUint(b)
+	case mkvDuration:
+		p.duration = readFloat(b)
+	case mkvTitle:
+		p.info.Title = strings.TrimSpace(string(b))
+	case mkvTagName:
+		p.tagName = strings.ToUpper(string(b))
+	case mkvTagString:
+		p.tagValue = strings.TrimSpace(string(b))
+	}
+
+	if p.track == nil {
+		return nil
+	}
+
+	switch id {
+	case mkvTrackType:
+		p.track.kind = readUint(b)
+	case mkvCodecID:
+		p.track.codec = strings.TrimRight(string(b), "\x00")
+	case mkvPixelWidth:
+		p.track.width = int(readUint(b))
+	case mkvPixelHeight:
+		p.track.height = int(readUint(b))
+	}
+
+	return nil
+}
+
+// The first video and audio tracks describe the file.
+func (p *mkvProbe) endTrack() {
+	t := p.track
+	p.track = nil
+
+	switch t.kind {
+	case 1:
+		if len(p.info.VideoCodec) == 0 {
+			p.info.VideoCodec = mkvCodec(t.codec)
+			p.info.Width, p.info.Height = t.width, t.height
+		}
+	case 2:
+		if len(p.info.AudioCodec) == 0 {
+			p.info.AudioCodec = mkvCodec(t.codec)
+		}
+	}
+}
+
+func (p *mkvProbe) endTag() {
+	switch p.tagName {
+	case "TITLE":
+		if len(p.info.Title) == 0 {
+			p.info.Title = p.tagValue
+		}
+	case "ARTIST":
+		p.info.Artist = p.tagValue
+	case "ALBUM":
+		p.info.Album = p.tagValue
+	}
+}
+
+// V_VP9 is vp9, A_OPUS is opus.
+func mkvCodec(id string) string {
+	if c, ok := mkvCodecs[id]; ok {
+		return c
+	}
+
+	if len(id) > 2 && id[1] == '_' {
+		id = id[2:]
+	}
+
+	return strings.ToLower(id)
+}
+
+// IDs keep their length marker, they are compared as written in the spec.
+func readElementID(r io.Reader) (uint32, error) {
+	var first [1]byte
+	if _, err := io.ReadFull(r, first[:]); err != nil {
+		return 0, err
+	}
+
+	length := vintLength(first[0])
+	if length == 0 || length > 4 {
+		return 0, errors.New("Invalid element ID")
+	}
+
+	id := uint32(first[0])
+	rest := make([]byte, length-1)
+	if _, err := io.ReadFull(r, rest); err != nil {
+		return 0, err
+	}
+
+	for _, b := range rest {
+		id = id<<8 | uint32(b)
+	}
+
+	return id, nil
+}
+
+func readElementSize(r io.Reader) (int64, error) {
+	var first [1]byte
+	if _, err := io.ReadFull(r, first[:]); err != nil {
+		return 0, err
+	}
+
+	length := vintLength(first[0])
+	if length == 0 {
+		return 0, errors.New("Invalid element size")
+	}
+
+	value := uint64(first[0]) & (0xff >> uint(length))
+	allOnes := value == 0xff>>uint(length)
+
+	rest := make([]byte, length-1)
+	if _, err := io.ReadFull(r, rest); err != nil {
+		return 0, err
+	}
+
+	for _, b := range rest {
+		value = value<<8 | uint64(b)
+		allOnes = allOnes && b == 0xff
+	}
+
+	if allOnes {
+		return unknownSize, nil
+	}
+
+	return int64(value), nil
+}
+
+// The number of leading zeros tells how many more bytes follow.
+func vintLength(b byte) int {
+	for i := 0; i < 8; i++ {
+		if b&(0x80>>uint(i)) != 0 {
+			return i + 1
+		}
+	}
+
+	return 0
+}
+
+func readUint(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+
+	return v
+}
+
+func readFloat(b []byte) float64 {
+	switch len(b) {
+	case 4:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b)))
+	case 8:
+		return math.Float64frombits(binary.BigEndian.Uint64(b))
+	}
+
+	return 0
+}
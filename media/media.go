@@ -0,0 +1,133 @@
+package media
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/dhowden/tag"
+	"github.com/pkg/errors"
+)
+
+// Containers that can be probed.
+const (
+	MP4      = "mp4"
+	Matroska = "matroska"
+	WebM     = "webm"
+	MP3      = "mp3"
+	FLAC     = "flac"
+	Ogg      = "ogg"
+)
+
+// ErrUnknown is returned for files that aren't a container we know.
+var ErrUnknown = errors.New("Not a media file")
+
+// Info is what we know about an audio or video file. Duration is in seconds
+// and Bitrate in bits per second.
+type Info struct {
+	Format     string  `json:"format"`
+	Duration   float64 `json:"duration,omitempty"`
+	Width      int     `json:"width,omitempty"`
+	Height     int     `json:"height,omitempty"`
+	VideoCodec string  `json:"video_codec,omitempty"`
+	AudioCodec string  `json:"audio_codec,omitempty"`
+	Bitrate    int     `json:"bitrate,omitempty"`
+	Title      string  `json:"title,omitempty"`
+	Artist     string  `json:"artist,omitempty"`
+	Album      string  `json:"album,omitempty"`
+}
+
+// Picture is cover art embedded in a file.
+type Picture struct {
+	MIMEType string
+	Data     []byte
+}
+
+// Probe reads the headers of a file of size bytes. Only the parts that
+// describe the streams are read, never the streams themselves.
+func Probe(r io.ReadSeeker, size int64) (*Info, error) {
+	head, err := peek(r, 12)
+	if err != nil {
+		return nil, err
+	}
+
+	var info *Info
+	switch {
+	case isMP4(head):
+		info, err = probeMP4(r, size)
+	case bytes.HasPrefix(head, ebmlMagic):
+		info, err = probeMatroska(r, size)
+	case bytes.HasPrefix(head, []byte("fLaC")):
+		info, err = probeFLAC(r)
+	case bytes.HasPrefix(head, []byte("OggS")):
+		info, err = probeOgg(r, size)
+	case bytes.HasPrefix(head, []byte("ID3")), frameSync(head):
+		info, err = probeMP3(r, size)
+	default:
+		return nil, ErrUnknown
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if info.Bitrate == 0 && info.Duration > 0 {
+		info.Bitrate = int(float64(size) * 8 / info.Duration)
+	}
+
+	// Matroska tags are read along with everything else.
+	if info.Format != Matroska && info.Format != WebM {
+		if m, err := readTags(r); err == nil {
+			info.Title = strings.TrimSpace(m.Title())
+			info.Artist = strings.TrimSpace(m.Artist())
+			info.Album = strings.TrimSpace(m.Album())
+		}
+	}
+
+	return info, nil
+}
+
+// Cover returns the cover art of a file, or nil when it has none.
+func Cover(r io.ReadSeeker) *Picture {
+	m, err := readTags(r)
+	if err != nil {
+		return nil
+	}
+
+	p := m.Picture()
+	if p == nil || len(p.Data) == 0 {
+		return nil
+	}
+
+	return &Picture{MIMEType: p.MIMEType, Data: p.Data}
+}
+
+// Tag parsers panic on some broken files.
+func readTags(r io.ReadSeeker) (m tag.Metadata, err error) {
+	defer func() {
+		if recover() != nil {
+			m, err = nil, errors.New("Failed to read tags")
+		}
+	}()
+
+	if _, err = r.Seek(0, os.SEEK_SET); err != nil {
+		return nil, err
+	}
+
+	return tag.ReadFrom(r)
+}
+
+func peek(r io.ReadSeeker, n int) ([]byte, error) {
+	if _, err := r.Seek(0, os.SEEK_SET); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, n)
+	read, err := io.ReadFull(r, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+
+	return buf[:read], nil
+}
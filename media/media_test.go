@@ -0,0 +1,232 @@
+package media_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zqzca/back/media"
+)
+
+func box(name string, body ...[]byte) []byte {
+	data := bytes.Join(body, nil)
+	out := make([]byte, 8, 8+len(data))
+	binary.BigEndian.PutUint32(out, uint32(8+len(data)))
+	copy(out[4:], name)
+	return append(out, data...)
+}
+
+func u32(v uint32) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, v)
+	return b
+}
+
+func mp4Track(handler, codec string, width, height uint32) []byte {
+	tkhd := make([]byte, 84)
+	binary.BigEndian.PutUint32(tkhd[76:], width<<16)
+	binary.BigEndian.PutUint32(tkhd[80:], height<<16)
+
+	hdlr := append(make([]byte, 8), handler...)
+	hdlr = append(hdlr, make([]byte, 12)...)
+
+	stsd := append(make([]byte, 4), u32(1)...)
+	stsd = append(stsd, box(codec, make([]byte, 8))...)
+
+	return box("trak",
+		box("tkhd", tkhd),
+		box("mdia", box("hdlr", hdlr), box("minf", box("stbl", box("stsd", stsd)))),
+	)
+}
+
+func TestProbeMP4(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	mvhd := make([]byte, 100)
+	binary.BigEndian.PutUint32(mvhd[12:], 1000)
+	binary.BigEndian.PutUint32(mvhd[16:], 5000)
+
+	file := bytes.Join([][]byte{
+		box("ftyp", []byte("isom"), u32(512), []byte("isomavc1")),
+		box("moov",
+			box("mvhd", mvhd),
+			mp4Track("vide", "avc1", 1280, 720),
+			mp4Track("soun", "mp4a", 0, 0),
+		),
+		box("mdat", make([]byte, 1000)),
+	}, nil)
+
+	info, err := media.Probe(bytes.NewReader(file), int64(len(file)))
+	a.NoError(err)
+	a.Equal(media.MP4, info.Format)
+	a.Equal(5.0, info.Duration)
+	a.Equal(1280, info.Width)
+	a.Equal(720, info.Height)
+	a.Equal("h264", info.VideoCodec)
+	a.Equal("aac", info.AudioCodec)
+	a.True(info.Bitrate > 0)
+}
+
+// Sizes are always written as 8 byte vints.
+func ebml(id []byte, body ...[]byte) []byte {
+	data := bytes.Join(body, nil)
+	size := make([]byte, 8)
+	binary.BigEndian.PutUint64(size, uint64(len(data)))
+	size[0] = 0x01
+
+	out := append(append([]byte{}, id...), size...)
+	return append(out, data...)
+}
+
+func TestProbeMatroska(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	duration := make([]byte, 8)
+	binary.BigEndian.PutUint64(duration, math.Float64bits(2500))
+
+	file := bytes.Join([][]byte{
+		ebml([]byte{0x1a, 0x45, 0xdf, 0xa3}, ebml([]byte{0x42, 0x82}, []byte("webm"))),
+		ebml([]byte{0x18, 0x53, 0x80, 0x67},
+			ebml([]byte{0x15, 0x49, 0xa9, 0x66},
+				ebml([]byte{0x2a, 0xd7, 0xb1}, []byte{0x0f, 0x42, 0x40}),
+				ebml([]byte{0x44, 0x89}, duration),
+			),
+			ebml([]byte{0x16, 0x54, 0xae, 0x6b},
+				ebml([]byte{0xae},
+					ebml([]byte{0x83}, []byte{1}),
+					ebml([]byte{0x86}, []byte("V_VP9")),
+					ebml([]byte{0xe0},
+						ebml([]byte{0xb0}, []byte{0x02, 0x80}),
+						ebml([]byte{0xba}, []byte{0x01, 0x68}),
+					),
+				),
+				ebml([]byte{0xae},
+					ebml([]byte{0x83}, []byte{2}),
+					ebml([]byte{0x86}, []byte("A_OPUS")),
+				),
+			),
+			ebml([]byte{0x1f, 0x43, 0xb6, 0x75}, make([]byte, 4096)),
+			ebml([]byte{0x12, 0x54, 0xc3, 0x67},
+				ebml([]byte{0x73, 0x73},
+					ebml([]byte{0x67, 0xc8},
+						ebml([]byte{0x45, 0xa3}, []byte("ARTIST")),
+						ebml([]byte{0x44, 0x87}, []byte("Someone")),
+					),
+				),
+			),
+		),
+	}, nil)
+
+	info, err := media.Probe(bytes.NewReader(file), int64(len(file)))
+	a.NoError(err)
+	a.Equal(media.WebM, info.Format)
+	a.Equal(2.5, info.Duration)
+	a.Equal(640, info.Width)
+	a.Equal(360, info.Height)
+	a.Equal("vp9", info.VideoCodec)
+	a.Equal("opus", info.AudioCodec)
+	a.Equal("Someone", info.Artist)
+}
+
+func id3(frames ...[]byte) []byte {
+	data := bytes.Join(frames, nil)
+	size := len(data)
+
+	out := []byte{'I', 'D', '3', 3, 0, 0,
+		byte(size >> 21 & 0x7f), byte(size >> 14 & 0x7f), byte(size >> 7 & 0x7f), byte(size & 0x7f)}
+	return append(out, data...)
+}
+
+func id3Frame(id string, data []byte) []byte {
+	out := append([]byte(id), u32(uint32(len(data)))...)
+	out = append(out, 0, 0)
+	return append(out, data...)
+}
+
+func TestProbeMP3(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	tag := id3(
+		id3Frame("TIT2", append([]byte{0}, "A Song"...)),
+		id3Frame("TPE1", append([]byte{0}, "Someone"...)),
+		id3Frame("APIC", append([]byte("\x00image/png\x00\x03\x00"), "not really a png"...)),
+	)
+
+	// 128kbit/s MPEG 1 Layer III at 44.1kHz, 417 bytes a frame.
+	frame := make([]byte, 417)
+	copy(frame, []byte{0xff, 0xfb, 0x90, 0x00})
+
+	file := append([]byte{}, tag...)
+	for i := 0; i < 100; i++ {
+		file = append(file, frame...)
+	}
+
+	info, err := media.Probe(bytes.NewReader(file), int64(len(file)))
+	a.NoError(err)
+	a.Equal(media.MP3, info.Format)
+	a.Equal("mp3", info.AudioCodec)
+	a.Equal(128000, info.Bitrate)
+	a.InDelta(100*417*8/128000.0, info.Duration, 0.001)
+	a.Equal("A Song", info.Title)
+	a.Equal("Someone", info.Artist)
+
+	cover := media.Cover(bytes.NewReader(file))
+	if a.NotNil(cover) {
+		a.Equal("image/png", cover.MIMEType)
+		a.Equal("not really a png", string(cover.Data))
+	}
+}
+
+func TestProbeFLAC(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	streamInfo := make([]byte, 34)
+	// 44100Hz, 2 channels, 16 bits and 441000 samples.
+	binary.BigEndian.PutUint64(streamInfo[10:], 44100<<44|1<<41|15<<36|441000)
+
+	file := append([]byte("fLaC\x80\x00\x00\x22"), streamInfo...)
+
+	info, err := media.Probe(bytes.NewReader(file), int64(len(file)))
+	a.NoError(err)
+	a.Equal(media.FLAC, info.Format)
+	a.Equal("flac", info.AudioCodec)
+	a.Equal(10.0, info.Duration)
+}
+
+func oggPage(granule uint64, packet []byte) []byte {
+	page := make([]byte, 27)
+	copy(page, "OggS")
+	binary.LittleEndian.PutUint64(page[6:], granule)
+	binary.LittleEndian.PutUint32(page[14:], 1234)
+	page[26] = 1
+	page = append(page, byte(len(packet)))
+	return append(page, packet...)
+}
+
+func TestProbeOpus(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	head := []byte("OpusHead\x01\x02\x38\x01\x80\xbb\x00\x00\x00\x00\x00")
+	file := append(oggPage(0, head), oggPage(3*48000+312, make([]byte, 200))...)
+
+	info, err := media.Probe(bytes.NewReader(file), int64(len(file)))
+	a.NoError(err)
+	a.Equal(media.Ogg, info.Format)
+	a.Equal("opus", info.AudioCodec)
+	a.Equal(3.0, info.Duration)
+}
+
+func TestProbeUnknown(t *testing.T) {
+	t.Parallel()
+
+	file := []byte("just some text")
+	_, err := media.Probe(bytes.NewReader(file), int64(len(file)))
+	assert.Equal(t, media.ErrUnknown, err)
+}
@@ -0,0 +1,167 @@
+package media
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// Layer III bitrates in kbit/s by index, for MPEG 1 and MPEG 2 and 2.5.
+var (
+	mp3Bitrates1 = []int{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320}
+	mp3Bitrates2 = []int{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160}
+)
+
+// Sample rates by version bits and index.
+var mp3SampleRates = map[byte][]int{
+	3: {44100, 48000, 32000}, // MPEG 1
+	2: {22050, 24000, 16000}, // MPEG 2
+	0: {11025, 12000, 8000},  // MPEG 2.5
+}
+
+// How far past the ID3 tag the first frame is looked for.
+const mp3SyncWindow = 64 * 1024
+
+type mp3Frame struct {
+	version    byte
+	bitrate    int
+	sampleRate int
+	mono       bool
+}
+
+func (f mp3Frame) samples() int {
+	if f.version == 3 {
+		return 1152
+	}
+
+	return 576
+}
+
+// Xing and Info headers follow the side information.
+func (f mp3Frame) sideInfo() int {
+	switch {
+	case f.version == 3 && f.mono:
+		return 17
+	case f.version == 3:
+		return 32
+	case f.mono:
+		return 9
+	}
+
+	return 17
+}
+
+func frameSync(b []byte) bool {
+	_, ok := parseFrame(b)
+	return ok
+}
+
+// Only Layer III frames are understood.
+func parseFrame(b []byte) (mp3Frame, bool) {
+	if len(b) < 4 || b[0] != 0xff || b[1]&0xe0 != 0xe0 {
+		return mp3Frame{}, false
+	}
+
+	version := (b[1] >> 3) & 3
+	layer := (b[1] >> 1) & 3
+	bitrateIndex := int(b[2] >> 4)
+	rateIndex := int((b[2] >> 2) & 3)
+
+	if version == 1 || layer != 1 || bitrateIndex == 0 || bitrateIndex == 15 || rateIndex == 3 {
+		return mp3Frame{}, false
+	}
+
+	f := mp3Frame{
+		version:    version,
+		sampleRate: mp3SampleRates[version][rateIndex],
+		mono:       b[3]>>6 == 3,
+	}
+
+	if version == 3 {
+		f.bitrate = mp3Bitrates1[bitrateIndex] * 1000
+	} else {
+		f.bitrate = mp3Bitrates2[bitrateIndex] * 1000
+	}
+
+	return f, true
+}
+
+func probeMP3(r io.ReadSeeker, size int64) (*Info, error) {
+	start, err := id3Size(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = r.Seek(start, os.SEEK_SET); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, mp3SyncWindow)
+	n, err := io.ReadFull(r, buf)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, errors.Wrap(err, "Failed to read MP3")
+	}
+	buf = buf[:n]
+
+	for i := 0; i+4 <= len(buf); i++ {
+		f, ok := parseFrame(buf[i:])
+		if !ok {
+			continue
+		}
+
+		info := &Info{Format: MP3, AudioCodec: "mp3"}
+
+		// VBR files count their frames, CBR ones are just long enough.
+		if frames := vbrFrames(buf[i:], f); frames > 0 {
+			info.Duration = float64(frames*f.samples()) / float64(f.sampleRate)
+		} else {
+			info.Bitrate = f.bitrate
+			info.Duration = float64(size-start-int64(i)) * 8 / float64(f.bitrate)
+		}
+
+		return info, nil
+	}
+
+	return nil, ErrUnknown
+}
+
+func vbrFrames(frame []byte, f mp3Frame) int {
+	xing := 4 + f.sideInfo()
+	if len(frame) >= xing+12 {
+		tag := string(frame[xing : xing+4])
+		flags := binary.BigEndian.Uint32(frame[xing+4:])
+		if (tag == "Xing" || tag == "Info") && flags&1 != 0 {
+			return int(binary.BigEndian.Uint32(frame[xing+8:]))
+		}
+	}
+
+	// VBRI always sits 32 bytes after the header.
+	if len(frame) >= 36+18 && string(frame[36:40]) == "VBRI" {
+		return int(binary.BigEndian.Uint32(frame[36+14:]))
+	}
+
+	return 0
+}
+
+// id3Size is how many bytes an ID3v2 tag at the start takes up.
+func id3Size(r io.ReadSeeker) (int64, error) {
+	head, err := peek(r, 10)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(head) < 10 || string(head[:3]) != "ID3" {
+		return 0, nil
+	}
+
+	// Sizes are 4 bytes of 7 bits each.
+	size := int64(head[6])<<21 | int64(head[7])<<14 | int64(head[8])<<7 | int64(head[9])
+	size += 10
+	if head[5]&0x10 != 0 {
+		size += 10 // footer
+	}
+
+	return size, nil
+}
@@ -0,0 +1,224 @@
+package media
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Boxes that only contain other boxes.
+var mp4Containers = map[string]bool{
+	"moov": true,
+	"trak": true,
+	"mdia": true,
+	"minf": true,
+	"stbl": true,
+}
+
+// Old QuickTime files start without an ftyp box.
+var quickTimeBoxes = map[string]bool{
+	"moov": true,
+	"mdat": true,
+	"wide": true,
+	"free": true,
+	"skip": true,
+}
+
+var mp4Codecs = map[string]string{
+	"avc1": "h264",
+	"avc3": "h264",
+	"hvc1": "hevc",
+	"hev1": "hevc",
+	"av01": "av1",
+	"vp08": "vp8",
+	"vp09": "vp9",
+	"mp4v": "mpeg4",
+	"mp4a": "aac",
+	"Opus": "opus",
+	"fLaC": "flac",
+	"alac": "alac",
+	"ac-3": "ac3",
+	"ec-3": "eac3",
+	".mp3": "mp3",
+}
+
+// Leaf boxes read into memory are never bigger than this.
+const maxMP4Box = 4096
+
+func isMP4(head []byte) bool {
+	return len(head) >= 8 && (string(head[4:8]) == "ftyp" || quickTimeBoxes[string(head[4:8])])
+}
+
+type mp4Track struct {
+	handler       string
+	codec         string
+	width, height int
+}
+
+type mp4Probe struct {
+	r         io.ReadSeeker
+	info      *Info
+	timescale uint32
+	duration  uint64
+	track     *mp4Track
+}
+
+func probeMP4(r io.ReadSeeker, size int64) (*Info, error) {
+	p := &mp4Probe{r: r, info: &Info{Format: MP4}}
+
+	if _, err := r.Seek(0, os.SEEK_SET); err != nil {
+		return nil, err
+	}
+
+	if err := p.boxes(size); err != nil {
+		return nil, err
+	}
+
+	if p.timescale > 0 {
+		p.info.Duration = float64(p.duration) / float64(p.timescale)
+	}
+
+	return p.info, nil
+}
+
+// boxes walks the boxes from the current offset up to end.
+func (p *mp4Probe) boxes(end int64) error {
+	for {
+		start, err := p.r.Seek(0, os.SEEK_CUR)
+		if err != nil {
+			return err
+		}
+		if start+8 > end {
+			return nil
+		}
+
+		var hdr [8]byte
+		if _, err = io.ReadFull(p.r, hdr[:]); err != nil {
+			return errors.Wrap(err, "Failed to read box")
+		}
+
+		size := int64(binary.BigEndian.Uint32(hdr[:4]))
+		name := string(hdr[4:])
+		headerSize := int64(8)
+
+		switch size {
+		case 0:
+			size = end - start
+		case 1:
+			var large [8]byte
+			if _, err = io.ReadFull(p.r, large[:]); err != nil {
+				return err
+			}
+			size = int64(binary.BigEndian.Uint64(large[:]))
+			headerSize = 16
+		}
+
+		if size < headerSize || start+size > end {
+			return errors.New("Invalid box size")
+		}
+
+		if err = p.box(name, start+size, size-headerSize); err != nil {
+			return err
+		}
+
+		if _, err = p.r.Seek(start+size, os.SEEK_SET); err != nil {
+			return err
+		}
+	}
+}
+
+func (p *mp4Probe) box(name string, end, size int64) error {
+	if mp4Containers[name] {
+		if name == "trak" {
+			p.track = &mp4Track{}
+			defer p.endTrack()
+		}
+
+		return p.boxes(end)
+	}
+
+	switch name {
+	case "mvhd", "tkhd", "hdlr", "stsd":
+	default:
+		return nil
+	}
+
+	if size > maxMP4Box {
+		size = maxMP4Box
+	}
+
+	body := make([]byte, size)
+	if _, err := io.ReadFull(p.r, body); err != nil {
+		return errors.Wrap(err, "Failed to read "+name)
+	}
+
+	switch name {
+	case "mvhd":
+		p.mvhd(body)
+	case "tkhd":
+		p.tkhd(body)
+	case "hdlr":
+		if p.track != nil && len(body) >= 12 {
+			p.track.handler = string(body[8:12])
+		}
+	case "stsd":
+		if p.track != nil && len(body) >= 16 {
+			p.track.codec = string(body[12:16])
+		}
+	}
+
+	return nil
+}
+
+func (p *mp4Probe) mvhd(b []byte) {
+	if len(b) >= 32 && b[0] == 1 {
+		p.timescale = binary.BigEndian.Uint32(b[20:24])
+		p.duration = binary.BigEndian.Uint64(b[24:32])
+	} else if len(b) >= 20 {
+		p.timescale = binary.BigEndian.Uint32(b[12:16])
+		p.duration = uint64(binary.BigEndian.Uint32(b[16:20]))
+	}
+}
+
+func (p *mp4Probe) tkhd(b []byte) {
+	if p.track == nil {
+		return
+	}
+
+	// Width and height are 16.16 fixed point at the end of the box.
+	offset := 76
+	if len(b) > 0 && b[0] == 1 {
+		offset = 88
+	}
+
+	if len(b) >= offset+8 {
+		p.track.width = int(binary.BigEndian.Uint32(b[offset:]) >> 16)
+		p.track.height = int(binary.BigEndian.Uint32(b[offset+4:]) >> 16)
+	}
+}
+
+// The first video and audio tracks describe the file.
+func (p *mp4Probe) endTrack() {
+	t := p.track
+	p.track = nil
+
+	codec := mp4Codecs[t.codec]
+	if len(codec) == 0 {
+		codec = strings.TrimSpace(t.codec)
+	}
+
+	switch t.handler {
+	case "vide":
+		if len(p.info.VideoCodec) == 0 {
+			p.info.VideoCodec = codec
+			p.info.Width, p.info.Height = t.width, t.height
+		}
+	case "soun":
+		if len(p.info.AudioCodec) == 0 {
+			p.info.AudioCodec = codec
+		}
+	}
+}
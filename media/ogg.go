@@ -0,0 +1,90 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// The last page, which has the final granule position, is looked for this
+// far from the end.
+const oggTailWindow = 64 * 1024
+
+// Opus granule positions always count 48kHz samples.
+const opusRate = 48000
+
+func probeOgg(r io.ReadSeeker, size int64) (*Info, error) {
+	page, err := peek(r, 27+255+64)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(page) < 27 {
+		return nil, errors.New("Ogg page too short")
+	}
+
+	serial := binary.LittleEndian.Uint32(page[14:18])
+	segments := int(page[26])
+	if len(page) < 27+segments {
+		return nil, errors.New("Ogg page too short")
+	}
+	packet := page[27+segments:]
+
+	info := &Info{Format: Ogg}
+	var rate, preSkip uint64
+
+	switch {
+	case bytes.HasPrefix(packet, []byte("\x01vorbis")) && len(packet) >= 24:
+		info.AudioCodec = "vorbis"
+		rate = uint64(binary.LittleEndian.Uint32(packet[12:16]))
+		if nominal := int32(binary.LittleEndian.Uint32(packet[20:24])); nominal > 0 {
+			info.Bitrate = int(nominal)
+		}
+	case bytes.HasPrefix(packet, []byte("OpusHead")) && len(packet) >= 12:
+		info.AudioCodec = "opus"
+		rate = opusRate
+		preSkip = uint64(binary.LittleEndian.Uint16(packet[10:12]))
+	case bytes.HasPrefix(packet, []byte("\x7fFLAC")):
+		info.AudioCodec = "flac"
+	case bytes.HasPrefix(packet, []byte("\x80theora")):
+		info.VideoCodec = "theora"
+	}
+
+	if rate > 0 {
+		if granule, ok := lastGranule(r, size, serial); ok && granule > preSkip {
+			info.Duration = float64(granule-preSkip) / float64(rate)
+		}
+	}
+
+	return info, nil
+}
+
+func lastGranule(r io.ReadSeeker, size int64, serial uint32) (uint64, bool) {
+	start := size - oggTailWindow
+	if start < 0 {
+		start = 0
+	}
+
+	if _, err := r.Seek(start, os.SEEK_SET); err != nil {
+		return 0, false
+	}
+
+	tail, err := ioutil.ReadAll(io.LimitReader(r, oggTailWindow))
+	if err != nil {
+		return 0, false
+	}
+
+	for i := bytes.LastIndex(tail, []byte("OggS")); i >= 0; i = bytes.LastIndex(tail[:i], []byte("OggS")) {
+		if len(tail) < i+18 || binary.LittleEndian.Uint32(tail[i+14:]) != serial {
+			continue
+		}
+
+		return binary.LittleEndian.Uint64(tail[i+6:]), true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,57 @@
+package media
+
+import (
+	"database/sql"
+
+	"github.com/pkg/errors"
+	"github.com/zqzca/back/db"
+)
+
+const loadInfoSQL = `
+	SELECT
+	format, duration, width, height, video_codec, audio_codec, bitrate,
+	title, artist, album
+	FROM media_info
+	WHERE file_id = $1
+`
+
+const saveInfoSQL = `
+	INSERT INTO media_info (
+		file_id, format, duration, width, height, video_codec, audio_codec,
+		bitrate, title, artist, album
+	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	ON CONFLICT (file_id) DO UPDATE SET
+	format = EXCLUDED.format, duration = EXCLUDED.duration,
+	width = EXCLUDED.width, height = EXCLUDED.height,
+	video_codec = EXCLUDED.video_codec, audio_codec = EXCLUDED.audio_codec,
+	bitrate = EXCLUDED.bitrate, title = EXCLUDED.title,
+	artist = EXCLUDED.artist, album = EXCLUDED.album
+`
+
+// LoadInfo returns nil without an error when a file has none.
+func LoadInfo(ex db.Executor, fileID string) (*Info, error) {
+	var i Info
+
+	err := ex.QueryRow(loadInfoSQL, fileID).Scan(
+		&i.Format, &i.Duration, &i.Width, &i.Height, &i.VideoCodec,
+		&i.AudioCodec, &i.Bitrate, &i.Title, &i.Artist, &i.Album,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to load media info")
+	}
+
+	return &i, nil
+}
+
+// SaveInfo stores what was probed from a file, replacing what it had.
+func SaveInfo(ex db.Executor, fileID string, i *Info) error {
+	_, err := ex.Exec(saveInfoSQL,
+		fileID, i.Format, i.Duration, i.Width, i.Height, i.VideoCodec,
+		i.AudioCodec, i.Bitrate, i.Title, i.Artist, i.Album,
+	)
+
+	return errors.Wrap(err, "Failed to save media info")
+}
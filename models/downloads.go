@@ -19,11 +19,20 @@ import (
 
 // Download is an object representing the database table.
 type Download struct {
-	ID        int         `boil:"id" json:"id" toml:"id" yaml:"id"`
-	Ip        null.String `boil:"ip" json:"ip,omitempty" toml:"ip" yaml:"ip,omitempty"`
-	CacheHit  bool        `boil:"cache_hit" json:"cache_hit" toml:"cache_hit" yaml:"cache_hit"`
-	FileID    null.String `boil:"file_id" json:"file_id,omitempty" toml:"file_id" yaml:"file_id,omitempty"`
-	CreatedAt time.Time   `boil:"created_at" json:"created_at" toml:"created_at" yaml:"created_at"`
+	ID           int         `boil:"id" json:"id" toml:"id" yaml:"id"`
+	Ip           null.String `boil:"ip" json:"ip,omitempty" toml:"ip" yaml:"ip,omitempty"`
+	CacheHit     bool        `boil:"cache_hit" json:"cache_hit" toml:"cache_hit" yaml:"cache_hit"`
+	FileID       null.String `boil:"file_id" json:"file_id,omitempty" toml:"file_id" yaml:"file_id,omitempty"`
+	CreatedAt    time.Time   `boil:"created_at" json:"created_at" toml:"created_at" yaml:"created_at"`
+	Referrer     null.String `boil:"referrer" json:"referrer,omitempty" toml:"referrer" yaml:"referrer,omitempty"`
+	UserAgent    null.String `boil:"user_agent" json:"user_agent,omitempty" toml:"user_agent" yaml:"user_agent,omitempty"`
+	ReferrerHost null.String `boil:"referrer_host" json:"referrer_host,omitempty" toml:"referrer_host" yaml:"referrer_host,omitempty"`
+	UaFamily     null.String `boil:"ua_family" json:"ua_family,omitempty" toml:"ua_family" yaml:"ua_family,omitempty"`
+	Bot          bool        `boil:"bot" json:"bot" toml:"bot" yaml:"bot"`
+	BytesSent    int64       `boil:"bytes_sent" json:"bytes_sent" toml:"bytes_sent" yaml:"bytes_sent"`
+	Completed    bool        `boil:"completed" json:"completed" toml:"completed" yaml:"completed"`
+	Country      null.String `boil:"country" json:"country,omitempty" toml:"country" yaml:"country,omitempty"`
+	Asn          null.Int64  `boil:"asn" json:"asn,omitempty" toml:"asn" yaml:"asn,omitempty"`
 
 	R *downloadR `boil:"-" json:"-" toml:"-" yaml:"-"`
 	L downloadL  `boil:"-" json:"-" toml:"-" yaml:"-"`
@@ -38,9 +47,9 @@ type downloadR struct {
 type downloadL struct{}
 
 var (
-	downloadColumns               = []string{"id", "ip", "cache_hit", "file_id", "created_at"}
-	downloadColumnsWithoutDefault = []string{"ip", "cache_hit", "file_id", "created_at"}
-	downloadColumnsWithDefault    = []string{"id"}
+	downloadColumns               = []string{"id", "ip", "cache_hit", "file_id", "created_at", "referrer", "user_agent", "referrer_host", "ua_family", "bot", "bytes_sent", "completed", "country", "asn"}
+	downloadColumnsWithoutDefault = []string{"ip", "cache_hit", "file_id", "created_at", "referrer", "user_agent", "referrer_host", "ua_family", "country", "asn"}
+	downloadColumnsWithDefault    = []string{"id", "bot", "bytes_sent", "completed"}
 	downloadPrimaryKeyColumns     = []string{"id"}
 )
 
@@ -688,7 +688,7 @@ func testDownloadsSelect(t *testing.T) {
 }
 
 var (
-	downloadDBTypes = map[string]string{"CacheHit": "boolean", "CreatedAt": "timestamp without time zone", "FileID": "uuid", "ID": "integer", "Ip": "inet"}
+	downloadDBTypes = map[string]string{"Asn": "bigint", "Bot": "boolean", "BytesSent": "bigint", "CacheHit": "boolean", "Completed": "boolean", "Country": "character", "CreatedAt": "timestamp without time zone", "FileID": "uuid", "ID": "integer", "Ip": "inet", "Referrer": "text", "ReferrerHost": "text", "UaFamily": "text", "UserAgent": "text"}
 	_               = bytes.MinRead
 )
 
@@ -14,20 +14,31 @@ import (
 	"github.com/vattle/sqlboiler/queries"
 	"github.com/vattle/sqlboiler/queries/qm"
 	"github.com/vattle/sqlboiler/strmangle"
+	"github.com/vattle/sqlboiler/types"
+	"gopkg.in/nullbio/null.v5"
 )
 
 // File is an object representing the database table.
 type File struct {
-	ID        string    `boil:"id" json:"id" toml:"id" yaml:"id"`
-	Size      int       `boil:"size" json:"size" toml:"size" yaml:"size"`
-	NumChunks int       `boil:"num_chunks" json:"num_chunks" toml:"num_chunks" yaml:"num_chunks"`
-	State     int       `boil:"state" json:"state" toml:"state" yaml:"state"`
-	Name      string    `boil:"name" json:"name" toml:"name" yaml:"name"`
-	Hash      string    `boil:"hash" json:"hash" toml:"hash" yaml:"hash"`
-	Type      string    `boil:"type" json:"type" toml:"type" yaml:"type"`
-	CreatedAt time.Time `boil:"created_at" json:"created_at" toml:"created_at" yaml:"created_at"`
-	UpdatedAt time.Time `boil:"updated_at" json:"updated_at" toml:"updated_at" yaml:"updated_at"`
-	Slug      string    `boil:"slug" json:"slug" toml:"slug" yaml:"slug"`
+	ID            string            `boil:"id" json:"id" toml:"id" yaml:"id"`
+	Size          int               `boil:"size" json:"size" toml:"size" yaml:"size"`
+	NumChunks     int               `boil:"num_chunks" json:"num_chunks" toml:"num_chunks" yaml:"num_chunks"`
+	State         int               `boil:"state" json:"state" toml:"state" yaml:"state"`
+	Name          string            `boil:"name" json:"name" toml:"name" yaml:"name"`
+	Hash          string            `boil:"hash" json:"hash" toml:"hash" yaml:"hash"`
+	Type          string            `boil:"type" json:"type" toml:"type" yaml:"type"`
+	CreatedAt     time.Time         `boil:"created_at" json:"created_at" toml:"created_at" yaml:"created_at"`
+	UpdatedAt     time.Time         `boil:"updated_at" json:"updated_at" toml:"updated_at" yaml:"updated_at"`
+	Slug          string            `boil:"slug" json:"slug" toml:"slug" yaml:"slug"`
+	DetectedType  null.String       `boil:"detected_type" json:"detected_type,omitempty" toml:"detected_type" yaml:"detected_type,omitempty"`
+	Width         null.Int          `boil:"width" json:"width,omitempty" toml:"width" yaml:"width,omitempty"`
+	Height        null.Int          `boil:"height" json:"height,omitempty" toml:"height" yaml:"height,omitempty"`
+	UserID        null.String       `boil:"user_id" json:"user_id,omitempty" toml:"user_id" yaml:"user_id,omitempty"`
+	Privacy       null.Bool         `boil:"privacy" json:"privacy,omitempty" toml:"privacy" yaml:"privacy,omitempty"`
+	SanitizedHash null.String       `boil:"sanitized_hash" json:"sanitized_hash,omitempty" toml:"sanitized_hash" yaml:"sanitized_hash,omitempty"`
+	Phash         null.Int64        `boil:"phash" json:"phash,omitempty" toml:"phash" yaml:"phash,omitempty"`
+	Blurhash      null.String       `boil:"blurhash" json:"blurhash,omitempty" toml:"blurhash" yaml:"blurhash,omitempty"`
+	Colors        types.StringArray `boil:"colors" json:"colors,omitempty" toml:"colors" yaml:"colors,omitempty"`
 
 	R *fileR `boil:"-" json:"-" toml:"-" yaml:"-"`
 	L fileL  `boil:"-" json:"-" toml:"-" yaml:"-"`
@@ -44,8 +55,8 @@ type fileR struct {
 type fileL struct{}
 
 var (
-	fileColumns               = []string{"id", "size", "num_chunks", "state", "name", "hash", "type", "created_at", "updated_at", "slug"}
-	fileColumnsWithoutDefault = []string{"size", "num_chunks", "state", "name", "hash", "type", "created_at", "updated_at"}
+	fileColumns               = []string{"id", "size", "num_chunks", "state", "name", "hash", "type", "created_at", "updated_at", "slug", "detected_type", "width", "height", "user_id", "privacy", "sanitized_hash", "phash", "blurhash", "colors"}
+	fileColumnsWithoutDefault = []string{"size", "num_chunks", "state", "name", "hash", "type", "created_at", "updated_at", "detected_type", "width", "height", "user_id", "privacy", "sanitized_hash", "phash", "blurhash", "colors"}
 	fileColumnsWithDefault    = []string{"id", "slug"}
 	filePrimaryKeyColumns     = []string{"id"}
 )
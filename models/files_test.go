@@ -1143,7 +1143,7 @@ func testFilesSelect(t *testing.T) {
 }
 
 var (
-	fileDBTypes = map[string]string{"CreatedAt": "timestamp without time zone", "Hash": "text", "ID": "uuid", "Name": "text", "NumChunks": "integer", "Size": "integer", "Slug": "text", "State": "integer", "Type": "text", "UpdatedAt": "timestamp without time zone"}
+	fileDBTypes = map[string]string{"Blurhash": "text", "Colors": "ARRAYtext", "CreatedAt": "timestamp without time zone", "DetectedType": "text", "Hash": "text", "Height": "integer", "ID": "uuid", "Name": "text", "NumChunks": "integer", "Phash": "bigint", "Privacy": "boolean", "SanitizedHash": "text", "Size": "integer", "Slug": "text", "State": "integer", "Type": "text", "UpdatedAt": "timestamp without time zone", "UserID": "uuid", "Width": "integer"}
 	_           = bytes.MinRead
 )
 
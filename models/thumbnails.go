@@ -24,6 +24,10 @@ type Thumbnail struct {
 	Hash      string    `boil:"hash" json:"hash" toml:"hash" yaml:"hash"`
 	CreatedAt time.Time `boil:"created_at" json:"created_at" toml:"created_at" yaml:"created_at"`
 	UpdatedAt time.Time `boil:"updated_at" json:"updated_at" toml:"updated_at" yaml:"updated_at"`
+	Width     int       `boil:"width" json:"width" toml:"width" yaml:"width"`
+	Height    int       `boil:"height" json:"height" toml:"height" yaml:"height"`
+	Format    string    `boil:"format" json:"format" toml:"format" yaml:"format"`
+	Kind      string    `boil:"kind" json:"kind" toml:"kind" yaml:"kind"`
 
 	R *thumbnailR `boil:"-" json:"-" toml:"-" yaml:"-"`
 	L thumbnailL  `boil:"-" json:"-" toml:"-" yaml:"-"`
@@ -38,9 +42,9 @@ type thumbnailR struct {
 type thumbnailL struct{}
 
 var (
-	thumbnailColumns               = []string{"id", "file_id", "size", "hash", "created_at", "updated_at"}
+	thumbnailColumns               = []string{"id", "file_id", "size", "hash", "created_at", "updated_at", "width", "height", "format", "kind"}
 	thumbnailColumnsWithoutDefault = []string{"file_id", "size", "hash", "created_at", "updated_at"}
-	thumbnailColumnsWithDefault    = []string{"id"}
+	thumbnailColumnsWithDefault    = []string{"id", "width", "height", "format", "kind"}
 	thumbnailPrimaryKeyColumns     = []string{"id"}
 )
 
@@ -635,7 +635,7 @@ func testThumbnailsSelect(t *testing.T) {
 }
 
 var (
-	thumbnailDBTypes = map[string]string{"CreatedAt": "timestamp without time zone", "FileID": "uuid", "Hash": "text", "ID": "uuid", "Size": "integer", "UpdatedAt": "timestamp without time zone"}
+	thumbnailDBTypes = map[string]string{"CreatedAt": "timestamp without time zone", "FileID": "uuid", "Format": "text", "Hash": "text", "Height": "integer", "ID": "uuid", "Kind": "text", "Size": "integer", "UpdatedAt": "timestamp without time zone", "Width": "integer"}
 	_                = bytes.MinRead
 )
 
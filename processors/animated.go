@@ -0,0 +1,353 @@
+package processors
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"github.com/disintegration/imaging"
+	"github.com/pkg/errors"
+	"github.com/zqzca/back/dependencies"
+	"github.com/zqzca/back/lib"
+	"github.com/zqzca/back/models"
+)
+
+// Limits for animated thumbnails. GIFs with more frames are cut short, ones
+// that come out bigger only get a static thumbnail.
+var (
+	AnimatedSize      = 200
+	MaxAnimatedFrames = 150
+	MaxAnimatedBytes  = 2 * 1024 * 1024
+)
+
+// Don't decode every frame of something enormous. Frames are a byte a pixel
+// once decoded, frames past maxAnimatedDecodeBytes are never decoded.
+const (
+	maxAnimatedPixels      = 4096 * 4096
+	maxAnimatedDecodeBytes = 64 << 20
+)
+
+// animatedThumbnail makes a square GIF from every frame of an animated GIF.
+// It returns nil for GIFs with a single frame or that end up too big.
+func animatedThumbnail(deps dependencies.Dependencies, r io.ReadSeeker) (*models.Thumbnail, error) {
+	if _, err := r.Seek(0, os.SEEK_SET); err != nil {
+		return nil, err
+	}
+
+	cfg, err := gif.DecodeConfig(r)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Width*cfg.Height > maxAnimatedPixels {
+		return nil, nil
+	}
+
+	if _, err = r.Seek(0, os.SEEK_SET); err != nil {
+		return nil, err
+	}
+
+	g, err := decodeGIF(r, MaxAnimatedFrames, maxAnimatedDecodeBytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to decode GIF")
+	}
+
+	if g == nil || len(g.Image) < 2 {
+		return nil, nil
+	}
+
+	out := resizeGIF(g, AnimatedSize, MaxAnimatedFrames)
+
+	var buf bytes.Buffer
+	if err = gif.EncodeAll(&buf, out); err != nil {
+		return nil, errors.Wrap(err, "Failed to encode GIF")
+	}
+
+	if buf.Len() > MaxAnimatedBytes {
+		deps.Info("Animated thumbnail too big", "bytes", buf.Len())
+		return nil, nil
+	}
+
+	hash, size, err := storeBlob(deps, "thumbnail", func(w io.Writer) error {
+		_, err := buf.WriteTo(w)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.Thumbnail{
+		Hash:   hash,
+		Size:   size,
+		Width:  AnimatedSize,
+		Height: AnimatedSize,
+		Format: lib.ThumbnailGIF,
+		Kind:   lib.ThumbnailAnimated,
+	}, nil
+}
+
+// resizeGIF plays the animation onto a canvas, honouring each frame's
+// disposal, and crops every resulting frame to a size x size square. Output
+// frames are complete pictures, so each one clears the one before it.
+func resizeGIF(g *gif.GIF, size, maxFrames int) *gif.GIF {
+	bounds := image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	if bounds.Empty() {
+		bounds = g.Image[0].Bounds()
+	}
+
+	canvas := image.NewRGBA(bounds)
+	out := &gif.GIF{LoopCount: g.LoopCount}
+
+	for i, frame := range g.Image {
+		if i >= maxFrames {
+			break
+		}
+
+		var previous *image.RGBA
+		if disposal(g, i) == gif.DisposalPrevious {
+			previous = image.NewRGBA(bounds)
+			copy(previous.Pix, canvas.Pix)
+		}
+
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+
+		thumb := imaging.Fill(canvas, size, size, imaging.Center, imaging.Lanczos)
+		paletted := image.NewPaletted(thumb.Bounds(), thumbPalette(thumb))
+		draw.FloydSteinberg.Draw(paletted, thumb.Bounds(), thumb, image.Point{})
+
+		out.Image = append(out.Image, paletted)
+		out.Delay = append(out.Delay, delay(g, i))
+		out.Disposal = append(out.Disposal, gif.DisposalBackground)
+
+		switch disposal(g, i) {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+
+	return out
+}
+
+// thumbPalette picks the most common colours of a frame, since the canvas
+// mixes earlier frames that each had their own palette. Colours are bucketed
+// by their top 5 bits and averaged. Transparency gets an entry of its own
+// when the frame has any.
+func thumbPalette(img *image.NRGBA) color.Palette {
+	type bucket struct {
+		r, g, b, n int
+	}
+
+	buckets := map[int]*bucket{}
+	transparent := false
+
+	for i := 0; i < len(img.Pix); i += 4 {
+		px := img.Pix[i : i+4]
+		if px[3] < 128 {
+			transparent = true
+			continue
+		}
+
+		key := int(px[0]>>3)<<10 | int(px[1]>>3)<<5 | int(px[2]>>3)
+		b, ok := buckets[key]
+		if !ok {
+			b = &bucket{}
+			buckets[key] = b
+		}
+
+		b.r += int(px[0])
+		b.g += int(px[1])
+		b.b += int(px[2])
+		b.n++
+	}
+
+	sorted := make([]*bucket, 0, len(buckets))
+	for _, b := range buckets {
+		sorted = append(sorted, b)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].n > sorted[j].n })
+
+	max := 256
+	if transparent {
+		max--
+	}
+	if len(sorted) > max {
+		sorted = sorted[:max]
+	}
+
+	var p color.Palette
+	for _, b := range sorted {
+		p = append(p, color.RGBA{uint8(b.r / b.n), uint8(b.g / b.n), uint8(b.b / b.n), 0xff})
+	}
+
+	if transparent || len(p) == 0 {
+		p = append(p, color.Transparent)
+	}
+
+	return p
+}
+
+// decodeGIF decodes at most maxFrames frames, and no more than add up to
+// maxBytes pixels, by cutting the GIF short before decoding it. It returns
+// nil when not even the first frame fits.
+func decodeGIF(r io.ReadSeeker, maxFrames, maxBytes int) (*gif.GIF, error) {
+	cut, frames, err := gifCut(r, maxFrames, maxBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	if frames == 0 {
+		return nil, nil
+	}
+
+	if _, err = r.Seek(0, os.SEEK_SET); err != nil {
+		return nil, err
+	}
+
+	trailer := bytes.NewReader([]byte{gifTrailer})
+	return gif.DecodeAll(io.MultiReader(io.LimitReader(r, cut), trailer))
+}
+
+// GIF block introducers.
+const (
+	gifExtension = 0x21
+	gifImage     = 0x2c
+	gifTrailer   = 0x3b
+)
+
+// gifCut walks the blocks of a GIF without decoding any image data. It
+// returns the offset of the first frame that doesn't fit, or of the end, and
+// how many frames come before it.
+func gifCut(r io.Reader, maxFrames, maxBytes int) (int64, int, error) {
+	br := &countingReader{r: bufio.NewReader(r)}
+
+	// Header and logical screen descriptor.
+	header := make([]byte, 13)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return 0, 0, err
+	}
+	if header[10]&0x80 != 0 {
+		if err := skip(br, colorTableSize(header[10])); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	frames, total := 0, 0
+	for {
+		cut := br.n
+
+		intro := make([]byte, 1)
+		if _, err := io.ReadFull(br, intro); err != nil {
+			return 0, 0, err
+		}
+
+		switch intro[0] {
+		case gifExtension:
+			// The label, then sub-blocks.
+			if err := skip(br, 1); err != nil {
+				return 0, 0, err
+			}
+			if err := skipSubBlocks(br); err != nil {
+				return 0, 0, err
+			}
+
+		case gifImage:
+			desc := make([]byte, 9)
+			if _, err := io.ReadFull(br, desc); err != nil {
+				return 0, 0, err
+			}
+
+			pixels := int(binary.LittleEndian.Uint16(desc[4:])) * int(binary.LittleEndian.Uint16(desc[6:]))
+			if frames == maxFrames || total+pixels > maxBytes {
+				return cut, frames, nil
+			}
+
+			if desc[8]&0x80 != 0 {
+				if err := skip(br, colorTableSize(desc[8])); err != nil {
+					return 0, 0, err
+				}
+			}
+
+			// LZW minimum code size, then the image data.
+			if err := skip(br, 1); err != nil {
+				return 0, 0, err
+			}
+			if err := skipSubBlocks(br); err != nil {
+				return 0, 0, err
+			}
+
+			frames++
+			total += pixels
+
+		case gifTrailer:
+			return cut, frames, nil
+
+		default:
+			return 0, 0, errors.Errorf("Unknown GIF block %#x", intro[0])
+		}
+	}
+}
+
+// Colour tables hold 2^(n+1) RGB entries, n is the low 3 bits of flags.
+func colorTableSize(flags byte) int {
+	return 3 << (uint(flags&7) + 1)
+}
+
+func skip(r io.Reader, n int) error {
+	_, err := io.CopyN(ioutil.Discard, r, int64(n))
+	return err
+}
+
+// Sub-blocks are a length byte followed by that many bytes, ending with an
+// empty one.
+func skipSubBlocks(r io.Reader) error {
+	size := make([]byte, 1)
+	for {
+		if _, err := io.ReadFull(r, size); err != nil {
+			return err
+		}
+
+		if size[0] == 0 {
+			return nil
+		}
+
+		if err := skip(r, int(size[0])); err != nil {
+			return err
+		}
+	}
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func disposal(g *gif.GIF, i int) byte {
+	if i < len(g.Disposal) {
+		return g.Disposal[i]
+	}
+
+	return gif.DisposalNone
+}
+
+func delay(g *gif.GIF, i int) int {
+	if i < len(g.Delay) {
+		return g.Delay[i]
+	}
+
+	return 10
+}
@@ -0,0 +1,132 @@
+package processors
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	red   = color.RGBA{0xff, 0, 0, 0xff}
+	green = color.RGBA{0, 0xff, 0, 0xff}
+	blue  = color.RGBA{0, 0, 0xff, 0xff}
+)
+
+// frame is a single colour rectangle with a palette of only that colour.
+func frame(r image.Rectangle, c color.Color) *image.Paletted {
+	return image.NewPaletted(r, color.Palette{c})
+}
+
+func animation(disposals []byte, frames ...*image.Paletted) *gif.GIF {
+	return &gif.GIF{
+		Image:    frames,
+		Delay:    make([]int, len(frames)),
+		Disposal: disposals,
+		Config:   image.Config{Width: 10, Height: 10},
+	}
+}
+
+func rgba(c color.Color) color.RGBA {
+	r, g, b, a := c.RGBA()
+	return color.RGBA{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), uint8(a >> 8)}
+}
+
+func TestResizeGIFDisposal(t *testing.T) {
+	t.Parallel()
+
+	full := image.Rect(0, 0, 10, 10)
+	corner := image.Rect(0, 0, 2, 2)
+
+	tests := []struct {
+		name      string
+		disposals []byte
+		frames    []*image.Paletted
+		want      color.RGBA
+	}{
+		{"none", []byte{gif.DisposalNone, gif.DisposalNone},
+			[]*image.Paletted{frame(full, red), frame(corner, blue)}, red},
+		{"background", []byte{gif.DisposalBackground, gif.DisposalNone},
+			[]*image.Paletted{frame(full, red), frame(corner, blue)}, color.RGBA{}},
+		{"previous", []byte{gif.DisposalNone, gif.DisposalPrevious, gif.DisposalNone},
+			[]*image.Paletted{frame(full, red), frame(full, green), frame(corner, blue)}, red},
+	}
+
+	for _, test := range tests {
+		out := resizeGIF(animation(test.disposals, test.frames...), 10, 10)
+		last := out.Image[len(out.Image)-1]
+
+		// Away from the corner the last frame shows what was left.
+		assert.Equal(t, test.want, rgba(last.At(5, 5)), test.name)
+		assert.Equal(t, blue, rgba(last.At(0, 0)), test.name)
+	}
+}
+
+// The canvas mixes colours from frames with their own palettes, none of
+// which has all of them.
+func TestResizeGIFLocalPalettes(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	g := animation([]byte{gif.DisposalNone, gif.DisposalNone},
+		frame(image.Rect(0, 0, 10, 10), red), frame(image.Rect(0, 0, 10, 5), green))
+
+	out := resizeGIF(g, 10, 10)
+	a.Equal(green, rgba(out.Image[1].At(5, 2)))
+	a.Equal(red, rgba(out.Image[1].At(5, 7)))
+}
+
+func TestResizeGIFMaxFrames(t *testing.T) {
+	t.Parallel()
+
+	var frames []*image.Paletted
+	for i := 0; i < 5; i++ {
+		frames = append(frames, frame(image.Rect(0, 0, 10, 10), red))
+	}
+
+	out := resizeGIF(animation(nil, frames...), 4, 3)
+	assert.Len(t, out.Image, 3)
+}
+
+func TestDecodeGIFCaps(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	g := &gif.GIF{}
+	for i := 0; i < 5; i++ {
+		// A local palette each, to be skipped over when scanning.
+		g.Image = append(g.Image, frame(image.Rect(0, 0, 10, 10), color.RGBA{uint8(i * 40), 0, 0, 0xff}))
+		g.Delay = append(g.Delay, i+1)
+	}
+
+	var buf bytes.Buffer
+	a.NoError(gif.EncodeAll(&buf, g))
+	data := buf.Bytes()
+
+	all, err := decodeGIF(bytes.NewReader(data), 10, 1000)
+	a.NoError(err)
+	if a.NotNil(all) {
+		a.Len(all.Image, 5)
+		a.Equal([]int{1, 2, 3, 4, 5}, all.Delay)
+	}
+
+	capped, err := decodeGIF(bytes.NewReader(data), 3, 1000)
+	a.NoError(err)
+	if a.NotNil(capped) {
+		a.Len(capped.Image, 3)
+	}
+
+	// Frames are 100 pixels each.
+	budget, err := decodeGIF(bytes.NewReader(data), 10, 250)
+	a.NoError(err)
+	if a.NotNil(budget) {
+		a.Len(budget.Image, 2)
+	}
+
+	none, err := decodeGIF(bytes.NewReader(data), 10, 50)
+	a.NoError(err)
+	a.Nil(none)
+}
@@ -0,0 +1,105 @@
+package processors
+
+import (
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/vattle/sqlboiler/queries/qm"
+	"github.com/zqzca/back/clamd"
+	"github.com/zqzca/back/lib"
+	"github.com/zqzca/back/models"
+)
+
+// Scanner checks every file for malware when set. Files it can't scan fail
+// rather than being served unchecked.
+var Scanner *clamd.Client
+
+// What happens to files bigger than MaxScanSize.
+const (
+	// OversizedReject refuses uploads declared bigger, and quarantines
+	// anything that turns out bigger once built.
+	OversizedReject = "reject"
+	// OversizedUnscanned serves them with a verdict saying they weren't
+	// scanned.
+	OversizedUnscanned = "unscanned"
+)
+
+// MaxScanSize is the biggest file sent to Scanner, clamd refuses anything
+// over its StreamMaxLength. ScanOversized is OversizedReject or
+// OversizedUnscanned.
+var (
+	MaxScanSize   int64 = 25 << 20
+	ScanOversized       = OversizedReject
+)
+
+func scanning(_, _ string) bool {
+	return Scanner != nil
+}
+
+// ScanRejects reports whether an upload of size bytes is refused for being
+// too big to scan.
+func ScanRejects(size int64) bool {
+	return Scanner != nil && ScanOversized == OversizedReject && size > MaxScanSize
+}
+
+// processScan quarantines infected files. A file that scans clean again, eg.
+// after signatures were fixed, is let back out. Files too big to scan are
+// dealt with as ScanOversized says.
+func processScan(ctx *Context) error {
+	size, err := ctx.Blob.Seek(0, os.SEEK_END)
+	if err != nil {
+		return err
+	}
+
+	if _, err = ctx.Blob.Seek(0, os.SEEK_SET); err != nil {
+		return err
+	}
+
+	var v *clamd.Verdict
+	if size > MaxScanSize {
+		err = clamd.ErrSizeLimit
+	} else {
+		v, err = Scanner.Scan(ctx.Blob)
+	}
+
+	// clamd's limit might be lower than MaxScanSize.
+	if errors.Cause(err) == clamd.ErrSizeLimit {
+		v, err = &clamd.Verdict{Unscanned: true, ScannedAt: time.Now().UTC()}, nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = clamd.SaveVerdict(ctx.Tx, ctx.File.ID, v); err != nil {
+		return err
+	}
+
+	f := ctx.File
+	switch {
+	case v.Infected:
+		ctx.Deps.Warn("Quarantined infected file", "id", f.ID, "signature", v.Signature)
+	case v.Unscanned && ScanOversized == OversizedReject:
+		ctx.Deps.Warn("Quarantined file too big to scan", "id", f.ID, "size", size)
+	case v.Unscanned:
+		// A quarantined file stays that way.
+		ctx.Deps.Info("File too big to scan", "id", f.ID, "size", size)
+		return nil
+	default:
+		if f.State != lib.FileQuarantined {
+			return nil
+		}
+
+		f.State = lib.FileFinished
+		return ctx.Update("state")
+	}
+
+	f.State = lib.FileQuarantined
+	if err = ctx.Update("state"); err != nil {
+		return err
+	}
+
+	// Reprocessed files might have thumbnails from before.
+	err = models.Thumbnails(ctx.Tx, qm.Where("file_id=$1", f.ID)).DeleteAll()
+	return errors.Wrap(err, "Failed to delete thumbnails")
+}
@@ -0,0 +1,101 @@
+package processors
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zqzca/back/clamd"
+	"github.com/zqzca/back/clamd/clamdtest"
+	"github.com/zqzca/back/lib"
+	"github.com/zqzca/back/models"
+)
+
+func restoreScanner(scanner *clamd.Client, maxSize int64, oversized string) {
+	Scanner, MaxScanSize, ScanOversized = scanner, maxSize, oversized
+}
+
+// verdict finds the scan result saved through tx, as infected and unscanned.
+func verdict(tx *fakeTx) (bool, bool, bool) {
+	for i, query := range tx.log {
+		if strings.HasPrefix(query, "INSERT INTO scan_results") {
+			args := tx.args[i]
+			return args[1].(bool), args[2].(bool), true
+		}
+	}
+
+	return false, false, false
+}
+
+func TestProcessScan(t *testing.T) {
+	scanner, done := clamdtest.Listen(t, 1000)
+	defer done()
+
+	defer restoreScanner(Scanner, MaxScanSize, ScanOversized)
+	Scanner = scanner
+
+	clean := []byte("harmless")
+	big := bytes.Repeat([]byte("harmless "), 200)
+
+	tests := []struct {
+		name      string
+		data      []byte
+		maxSize   int64
+		oversized string
+		state     int
+		infected  bool
+		unscanned bool
+		want      int
+	}{
+		{"clean", clean, 1 << 20, OversizedReject, lib.FileProcessing, false, false, lib.FileProcessing},
+		{"infected", []byte(clamdtest.Eicar), 1 << 20, OversizedReject, lib.FileProcessing, true, false, lib.FileQuarantined},
+		{"clean again", clean, 1 << 20, OversizedReject, lib.FileQuarantined, false, false, lib.FileFinished},
+
+		// Over MaxScanSize, never sent to clamd.
+		{"too big, rejected", clean, 4, OversizedReject, lib.FileProcessing, false, true, lib.FileQuarantined},
+		{"too big, unscanned", clean, 4, OversizedUnscanned, lib.FileProcessing, false, true, lib.FileProcessing},
+
+		// Over clamd's own limit.
+		{"clamd refused, rejected", big, 1 << 20, OversizedReject, lib.FileProcessing, false, true, lib.FileQuarantined},
+		{"clamd refused, unscanned", big, 1 << 20, OversizedUnscanned, lib.FileProcessing, false, true, lib.FileProcessing},
+		{"quarantined, unscanned", big, 1 << 20, OversizedUnscanned, lib.FileQuarantined, false, true, lib.FileQuarantined},
+	}
+
+	for _, test := range tests {
+		a := assert.New(t)
+		MaxScanSize, ScanOversized = test.maxSize, test.oversized
+
+		tx := &fakeTx{}
+		ctx := &Context{
+			Deps: quietDeps(),
+			Tx:   tx,
+			File: &models.File{ID: "file", State: test.state},
+			Blob: bytes.NewReader(test.data),
+		}
+
+		a.NoError(processScan(ctx), test.name)
+		a.Equal(test.want, ctx.File.State, test.name)
+
+		infected, unscanned, ok := verdict(tx)
+		a.True(ok, test.name)
+		a.Equal(test.infected, infected, test.name)
+		a.Equal(test.unscanned, unscanned, test.name)
+	}
+}
+
+func TestScanRejects(t *testing.T) {
+	a := assert.New(t)
+
+	defer restoreScanner(Scanner, MaxScanSize, ScanOversized)
+
+	Scanner, MaxScanSize, ScanOversized = nil, 100, OversizedReject
+	a.False(ScanRejects(101), "not scanning")
+
+	Scanner = clamd.New("localhost:3310")
+	a.False(ScanRejects(100))
+	a.True(ScanRejects(101))
+
+	ScanOversized = OversizedUnscanned
+	a.False(ScanRejects(101))
+}
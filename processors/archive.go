@@ -0,0 +1,31 @@
+package processors
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/zqzca/back/archive"
+	"github.com/zqzca/back/db"
+	"github.com/zqzca/back/dependencies"
+	"github.com/zqzca/back/models"
+)
+
+// InspectArchive stores what is inside a zip or tarball. Other files are
+// left alone.
+func InspectArchive(deps dependencies.Dependencies, ex db.Executor, f models.File, data archive.File) error {
+	size, err := data.Seek(0, os.SEEK_END)
+	if err != nil {
+		return err
+	}
+
+	m, err := archive.List(data, size)
+	if err == archive.ErrNotArchive {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "Failed to list archive")
+	}
+
+	deps.Debug("Archive listed", "id", f.ID, "kind", m.Kind, "entries", len(m.Entries))
+	return archive.SaveManifest(ex, f.ID, m)
+}
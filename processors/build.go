@@ -1,92 +1,204 @@
 package processors
 
 import (
-	"bytes"
+	"crypto/sha1"
 	"fmt"
 	"io"
 	"os"
-	"path/filepath"
+	"time"
 
+	"github.com/pkg/errors"
+	"github.com/spf13/afero"
 	"github.com/vattle/sqlboiler/queries/qm"
+	"github.com/zqzca/back/db"
 	"github.com/zqzca/back/dependencies"
+	"github.com/zqzca/back/jobs"
+	"github.com/zqzca/back/lib"
 	"github.com/zqzca/back/models"
 )
 
-func chunksExist(hashes []string) (bool, error) {
-	for _, hash := range hashes {
-		path := filepath.Join("files", "chunks", hash)
-
-		if _, err := os.Stat(path); os.IsNotExist(err) {
-			return false, err
-		}
-	}
-
-	return true, nil
-}
-
-// BuildFile builds a file from chunks.
-func BuildFile(deps dependencies.Dependencies, f *models.File) (io.ReadSeeker, error) {
+// Size of the buffer chunks are copied through. It is all the memory
+// building a file takes, however big the file is.
+const buildBufferSize = 64 << 10
+
+// ManifestMinSize is the size from which files no processor needs to read
+// are kept as their chunks instead of being written out again. Zero always
+// builds a blob.
+var ManifestMinSize int64
+
+// ChunkRemovalDelay is how long the chunks of a compacted file are kept for
+// readers that opened its manifest before it was compacted.
+var ChunkRemovalDelay = 24 * time.Hour
+
+// BuildFile puts the chunks of a file together and opens the result for
+// processors to read. Big files only the streaming processors handle are
+// stored as a manifest of their chunks, anything else is streamed into a
+// blob. Either way the content has to hash to what the client said it would.
+// The caller closes the returned file.
+func BuildFile(deps dependencies.Dependencies, ex db.Executor, f *models.File) (lib.StoredFile, error) {
 	chunks, err := models.Chunks(
 		deps.DB,
 		qm.Where("file_id=$1", f.ID),
 		qm.OrderBy("position asc"),
 	).All()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to find chunks")
+	}
 
 	var hashes []string
 	for _, c := range chunks {
 		hashes = append(hashes, c.Hash)
 	}
 
-	if ok, err := chunksExist(hashes); !ok {
-		deps.Error("Missing chunks!", "id", f.ID, "name", f.Name)
+	if ManifestMinSize > 0 && int64(f.Size) >= ManifestMinSize {
+		m, err := stitch(deps.Fs, hashes, f)
+		if err != nil {
+			deps.Error("Failed to stitch file", "id", f.ID, "name", f.Name, "err", err)
+			return nil, err
+		}
+
+		if m != nil {
+			if err = lib.SaveManifest(ex, f.ID, m); err != nil {
+				return nil, err
+			}
+
+			deps.Debug("Stored file as manifest", "id", f.ID, "chunks", len(hashes))
+			return lib.OpenManifest(deps.Fs, m), nil
+		}
+	}
+
+	path := lib.LocalPath(f.Hash)
+	if err = assemble(deps.Fs, path, hashes, f.Hash); err != nil {
+		deps.Error("Failed to build file", "id", f.ID, "name", f.Name, "err", err)
 		return nil, err
 	}
 
+	deps.Debug("Built file", "id", f.ID, "chunks", len(hashes))
+	return lib.OpenStored(ex, deps.Fs, f)
+}
+
+// stitch makes a manifest out of chunks, or returns nil when the file is
+// one processors need on disk.
+func stitch(fs afero.Fs, chunks []string, f *models.File) (*lib.Manifest, error) {
+	m := &lib.Manifest{Chunks: chunks}
+	for _, hash := range chunks {
+		info, err := fs.Stat(lib.ChunkPath(hash))
+		if err != nil {
+			return nil, errors.Wrapf(err, "Missing chunk %s", hash)
+		}
+
+		m.Sizes = append(m.Sizes, info.Size())
+	}
+
+	r := lib.OpenManifest(fs, m)
+	defer r.Close()
+
+	detected, err := lib.DetectType(r, f.Name)
 	if err != nil {
-		fmt.Println("Failed to find chunks for file:", f.ID)
+		return nil, errors.Wrap(err, "Failed to detect file type")
+	}
+
+	if processable(detected, f.Name) {
+		return nil, nil
+	}
+
+	if _, err = r.Seek(0, os.SEEK_SET); err != nil {
 		return nil, err
 	}
 
-	fs := deps.Fs
-	fullFilePath := filepath.Join("files", f.Hash)
-	fullFile, err := fs.Create(fullFilePath)
+	sum, err := lib.Hash(r)
 	if err != nil {
-		fmt.Println("Failed because", err)
 		return nil, err
 	}
-	defer fullFile.Close()
-	fullFileBuffer := &bytes.Buffer{}
 
-	mw := io.MultiWriter(fullFile, fullFileBuffer)
+	if sum != f.Hash {
+		return nil, errors.Errorf("Chunks hash to %s instead of %s", sum, f.Hash)
+	}
 
-	for _, c := range chunks {
-		fmt.Println("pos:", c.Position)
-		path := filepath.Join("files", "chunks", c.Hash)
-		chunkData, err := fs.Open(path)
+	return m, nil
+}
 
+// CompactFile writes a file stored as a manifest out into a single blob.
+// Chunks no other manifest needs are removed ChunkRemovalDelay later.
+func CompactFile(deps dependencies.Dependencies, f models.File) error {
+	m, err := lib.LoadManifest(deps.DB, f.ID)
+	if err != nil {
+		return err
+	}
+
+	if m == nil {
+		return nil
+	}
+
+	if err = assemble(deps.Fs, lib.LocalPath(f.Hash), m.Chunks, f.Hash); err != nil {
+		return errors.Wrap(err, "Failed to compact file")
+	}
+
+	// Readers prefer the blob, so it is in use before the manifest is gone.
+	if err = lib.DeleteManifest(deps.DB, f.ID); err != nil {
+		return err
+	}
+
+	// Downloads that started before might still be reading the chunks.
+	if err = jobs.EnqueueIn(deps.DB, jobs.CleanupChunks, f.ID, nil, ChunkRemovalDelay); err != nil {
+		return err
+	}
+
+	deps.Info("Compacted File", "name", f.Name, "id", f.ID, "chunks", len(m.Chunks))
+	return nil
+}
+
+// assemble writes chunks, in order, to a temporary file and moves it to dst
+// once it is known to hash to hash. Nothing is left behind on failure.
+func assemble(fs afero.Fs, dst string, chunks []string, hash string) error {
+	tmpPath := lib.TempFilePath("build")
+	tmp, err := fs.Create(tmpPath)
+	if err != nil {
+		return errors.Wrap(err, "Failed to create temp file")
+	}
+
+	h := sha1.New()
+	if err = copyChunks(fs, io.MultiWriter(tmp, h), chunks); err != nil {
+		tmp.Close()
+		fs.Remove(tmpPath)
+		return err
+	}
+
+	if err = tmp.Close(); err != nil {
+		fs.Remove(tmpPath)
+		return errors.Wrap(err, "Failed to write file")
+	}
+
+	if sum := fmt.Sprintf("%x", h.Sum(nil)); sum != hash {
+		fs.Remove(tmpPath)
+		return errors.Errorf("Chunks hash to %s instead of %s", sum, hash)
+	}
+
+	if err = fs.Rename(tmpPath, dst); err != nil {
+		fs.Remove(tmpPath)
+		return errors.Wrap(err, "Failed to move file into place")
+	}
+
+	return errors.Wrap(fs.Chmod(dst, 0644), "Failed to set permissions")
+}
+
+func copyChunks(fs afero.Fs, w io.Writer, chunks []string) error {
+	buf := make([]byte, buildBufferSize)
+
+	for _, hash := range chunks {
+		chunk, err := fs.Open(lib.ChunkPath(hash))
 		if err != nil {
-			fmt.Println("chunk Failed because", err)
-			return nil, err
+			return errors.Wrapf(err, "Missing chunk %s", hash)
 		}
-		_, err = io.Copy(mw, chunkData)
-		chunkData.Close()
 
+		// Only Read is exposed so the buffer is used rather than one the
+		// file might allocate for WriteTo.
+		_, err = io.CopyBuffer(w, struct{ io.Reader }{chunk}, buf)
+		chunk.Close()
 		if err != nil {
-			fmt.Println("Failed to copy chunk to full file")
+			return errors.Wrapf(err, "Failed to copy chunk %s", hash)
 		}
 	}
 
-	fmt.Println("Finished building file")
-
-	// for _, c := range chunks {
-	// 	path := filepath.Join("files", "chunks", c.Hash)
-	// 	if err != nil {
-	// 		fmt.Println("Failed to delete chunk entry:", c.ID)
-	// 	}
-	// 	fs.Remove(path)
-	// }
-
-	bs := bytes.NewReader(fullFileBuffer.Bytes())
-
-	return bs, nil
+	return nil
 }
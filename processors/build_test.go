@@ -0,0 +1,134 @@
+package processors
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/zqzca/back/lib"
+)
+
+// writeChunks stores n chunks of size bytes and returns their hashes and the
+// hash of them put together.
+func writeChunks(t testing.TB, fs afero.Fs, n, size int) ([]string, string) {
+	if err := fs.MkdirAll(filepath.Join("files", "chunks"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	whole := sha1.New()
+	var hashes []string
+
+	data := make([]byte, size)
+	for i := 0; i < n; i++ {
+		for j := range data {
+			data[j] = byte(i + j)
+		}
+
+		hash := fmt.Sprintf("%x", sha1.Sum(data))
+		if err := afero.WriteFile(fs, filepath.Join("files", "chunks", hash), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		whole.Write(data)
+		hashes = append(hashes, hash)
+	}
+
+	return hashes, fmt.Sprintf("%x", whole.Sum(nil))
+}
+
+func TestAssemble(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	fs := afero.NewMemMapFs()
+	chunks, hash := writeChunks(t, fs, 3, 100000)
+
+	a.NoError(assemble(fs, lib.LocalPath(hash), chunks, hash))
+
+	f, err := fs.Open(lib.LocalPath(hash))
+	if !a.NoError(err) {
+		return
+	}
+	defer f.Close()
+
+	// Processors seek around the blob.
+	_, err = f.Seek(100000, os.SEEK_SET)
+	a.NoError(err)
+	second := make([]byte, 10)
+	_, err = io.ReadFull(f, second)
+	a.NoError(err)
+	a.Equal([]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, second)
+
+	sum, err := lib.Hash(io.NewSectionReader(f, 0, 300000))
+	a.NoError(err)
+	a.Equal(hash, sum)
+}
+
+func TestAssembleWrongHash(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	fs := afero.NewMemMapFs()
+	chunks, _ := writeChunks(t, fs, 2, 1000)
+	wrong := fmt.Sprintf("%x", sha1.Sum([]byte("something else")))
+
+	a.Error(assemble(fs, lib.LocalPath(wrong), chunks, wrong))
+
+	// Neither the blob nor the temp file are left behind.
+	files, err := afero.ReadDir(fs, "files")
+	a.NoError(err)
+	for _, f := range files {
+		a.True(f.IsDir(), f.Name())
+	}
+}
+
+func TestAssembleMissingChunk(t *testing.T) {
+	t.Parallel()
+
+	fs := afero.NewMemMapFs()
+	chunks, hash := writeChunks(t, fs, 2, 1000)
+	fs.Remove(filepath.Join("files", "chunks", chunks[1]))
+
+	assert.Error(t, assemble(fs, lib.LocalPath(hash), chunks, hash))
+}
+
+// Building a file may allocate no more than this, whatever its size.
+const maxBuildAlloc = 1 << 20
+
+// BenchmarkAssemble builds a 64MB file on disk and fails if doing so
+// allocates anywhere near the size of the file.
+func BenchmarkAssemble(b *testing.B) {
+	dir, err := ioutil.TempDir("", "build")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fs := afero.NewBasePathFs(afero.NewOsFs(), dir)
+	chunks, hash := writeChunks(b, fs, 16, 4<<20)
+	dst := lib.LocalPath(hash)
+
+	b.SetBytes(16 * 4 << 20)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	var before, after runtime.MemStats
+	for i := 0; i < b.N; i++ {
+		runtime.ReadMemStats(&before)
+		if err := assemble(fs, dst, chunks, hash); err != nil {
+			b.Fatal(err)
+		}
+		runtime.ReadMemStats(&after)
+
+		if alloc := after.TotalAlloc - before.TotalAlloc; alloc > maxBuildAlloc {
+			b.Fatalf("Building a %d byte file allocated %d bytes", 16*4<<20, alloc)
+		}
+	}
+}
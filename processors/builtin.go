@@ -0,0 +1,124 @@
+package processors
+
+import (
+	"github.com/pkg/errors"
+	"github.com/zqzca/back/archive"
+	"github.com/zqzca/back/lib"
+	null "gopkg.in/nullbio/null.v5"
+)
+
+// Built in processors, in the order they run. Scanning goes first so nothing
+// else parses malware, privacy goes last so it sees everything the others
+// stored.
+func init() {
+	Register(processor{
+		name:      "antivirus",
+		required:  true,
+		streaming: true,
+		match:     scanning,
+		run:       processScan,
+	})
+
+	images := MatchTypes([]string{"image/"})
+
+	Register(Func("image", images, processImage))
+	Register(Func("exif", images, processExif))
+
+	// Archives are recognised by their magic, see lib.DetectType.
+	Register(processor{
+		name:      "archive",
+		streaming: true,
+		match: MatchTypes(
+			[]string{"application/zip", "application/x-tar", "application/gzip", "application/x-gzip", "application/zstd"},
+		),
+		run: processArchive,
+	})
+
+	Register(processor{
+		name:      "media",
+		streaming: true,
+		match: MatchTypes(
+			[]string{"audio/", "video/", "application/ogg"},
+			".mp3", ".m4a", ".mp4", ".m4v", ".mov", ".mkv", ".webm", ".flac", ".ogg", ".oga", ".ogv", ".opus",
+		),
+		run: processMedia,
+	})
+
+	Register(processor{
+		name:     "privacy",
+		required: true,
+		match:    func(contentType, _ string) bool { return lib.Strippable(contentType) },
+		run:      processPrivacy,
+	})
+}
+
+// Dimensions, perceptual hash and placeholder. Images Go can't decode, like
+// SVG, are left without.
+func processImage(ctx *Context) error {
+	f := ctx.File
+
+	if w, h, err := imageDimensions(ctx.Blob); err == nil {
+		f.Width = null.IntFrom(w)
+		f.Height = null.IntFrom(h)
+		if err = ctx.Update("width", "height"); err != nil {
+			return err
+		}
+	}
+
+	img, _, err := DecodeImage(ctx.Blob)
+	if err != nil {
+		return nil
+	}
+
+	f.Phash = null.Int64From(int64(lib.DHash(img)))
+	f.Colors = lib.DominantColors(img, lib.PaletteSize)
+	if hash, err := lib.BlurHash(img); err == nil {
+		f.Blurhash = null.StringFrom(hash)
+	}
+
+	return ctx.Update("phash", "blurhash", "colors")
+}
+
+// Images without EXIF data have nothing to store. Nothing about how a private
+// photo was taken is kept, when, where or with what, dimensions come from
+// processImage. Anything stored before the file was made private goes.
+func processExif(ctx *Context) error {
+	if private(*ctx.File) {
+		return lib.DeleteImageMetadata(ctx.Tx, ctx.File.ID)
+	}
+
+	m, err := ReadImageMetadata(ctx.Blob)
+	if err != nil {
+		return nil
+	}
+
+	return lib.SaveImageMetadata(ctx.Tx, ctx.File.ID, m)
+}
+
+// Zips are read from the end, the blob has to allow random access.
+func processArchive(ctx *Context) error {
+	data, ok := ctx.Blob.(archive.File)
+	if !ok {
+		return errors.New("Archives need random access")
+	}
+
+	return InspectArchive(ctx.Deps, ctx.Tx, *ctx.File, data)
+}
+
+func processMedia(ctx *Context) error {
+	return ProbeMedia(ctx.Deps, ctx.Tx, ctx.File, ctx.Blob)
+}
+
+func processPrivacy(ctx *Context) error {
+	if !private(*ctx.File) {
+		return nil
+	}
+
+	hash, _, err := SanitizeImage(ctx.Deps, ctx.Blob, ctx.Type)
+	if err != nil {
+		return err
+	}
+
+	ctx.File.SanitizedHash = null.StringFrom(hash)
+	return ctx.Update("sanitized_hash")
+}
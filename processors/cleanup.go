@@ -1,31 +1,43 @@
 package processors
 
 import (
-	"fmt"
-	"path/filepath"
-
 	"github.com/zqzca/back/dependencies"
+	"github.com/zqzca/back/lib"
 	"github.com/zqzca/back/models"
 )
 
-// Cleanup removes old chunks
+// Cleanup removes old chunks. Files stored as a manifest keep theirs.
 func Cleanup(deps dependencies.Dependencies, f *models.File) error {
 	chunks, err := f.Chunks(deps.DB).All()
-
 	if err != nil {
-		fmt.Println("Failed to lookup chunks for file", f.ID)
+		deps.Warn("Failed to lookup chunks for file", "id", f.ID, "err", err)
 		return nil
 	}
 
-	fs := deps.Fs
+	var hashes []string
 	for _, c := range chunks {
-		path := filepath.Join("files", "chunks", c.Hash)
-		fmt.Println("Removing chunk at", path)
-		if err != nil {
-			fmt.Println("Failed to delete chunk entry:", c.ID)
-		}
-		fs.Remove(path)
+		hashes = append(hashes, c.Hash)
 	}
 
+	removeChunks(deps, hashes)
 	return nil
 }
+
+// removeChunks deletes chunks from disk unless a manifest still needs them.
+func removeChunks(deps dependencies.Dependencies, hashes []string) {
+	for _, hash := range hashes {
+		referenced, err := lib.ChunkReferenced(deps.DB, hash)
+		if err != nil {
+			deps.Warn("Failed to check chunk", "hash", hash, "err", err)
+			continue
+		}
+
+		if referenced {
+			continue
+		}
+
+		if err = deps.Fs.Remove(lib.ChunkPath(hash)); err != nil {
+			deps.Debug("Failed to remove chunk", "hash", hash, "err", err)
+		}
+	}
+}
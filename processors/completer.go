@@ -1,14 +1,21 @@
 package processors
 
 import (
+	"io"
+	"os"
+	"strings"
+
 	"github.com/pkg/errors"
 	"github.com/vattle/sqlboiler/queries/qm"
 	"github.com/zqzca/back/dependencies"
 	"github.com/zqzca/back/lib"
 	"github.com/zqzca/back/models"
+	null "gopkg.in/nullbio/null.v5"
 )
 
-// CompleteFile builds the file from chunks and then generates thumbnails
+// CompleteFile builds the file from chunks, works out what it is and runs
+// the registered processors that handle it. Thumbnails are made separately
+// by ThumbnailFile.
 func CompleteFile(deps dependencies.Dependencies, f models.File) error {
 	deps.Info("Processing File", "name", f.Name, "id", f.ID)
 
@@ -18,20 +25,24 @@ func CompleteFile(deps dependencies.Dependencies, f models.File) error {
 		return err
 	}
 
-	if err = f.Reload(tx); err != nil {
+	// The row stays locked until we commit so a second run for the same
+	// file waits here and then sees it done.
+	locked, err := models.Files(tx, qm.Where("id=$1", f.ID), qm.For("UPDATE")).One()
+	if err != nil {
 		tx.Rollback()
 		return errors.Wrap(err, "Failed to reload the file")
 	}
+	f = *locked
 
-	if f.State == lib.FileProcessing {
+	if f.State == lib.FileFinished || lib.Quarantined(&f) {
 		tx.Rollback()
-		return errors.Wrap(err, "This file is already being processed")
+		deps.Info("File already processed", "id", f.ID)
+		return nil
 	}
 
-	// Delete all thumbnails
-	err = models.Thumbnails(tx, qm.Where("file_id=?", f.ID)).DeleteAll()
-	if err != nil {
-		deps.Info("No previous thumnails")
+	if f.State == lib.FileProcessing {
+		tx.Rollback()
+		return errors.New("This file is already being processed")
 	}
 
 	f.State = lib.FileProcessing
@@ -40,38 +51,38 @@ func CompleteFile(deps dependencies.Dependencies, f models.File) error {
 		return errors.Wrap(err, "Failed to update state")
 	}
 
-	reader, err := BuildFile(deps, &f)
+	reader, err := BuildFile(deps, tx, &f)
 	if err != nil {
 		tx.Rollback()
 		return errors.Wrap(err, "Failed to complete building file")
 	}
+	defer reader.Close()
 
-	thumbHash, thumbSize, err := CreateThumbnail(deps, reader)
+	// Never trust the type the client gave us.
+	detected, err := lib.DetectType(reader, f.Name)
 	if err != nil {
 		tx.Rollback()
-		return errors.Wrap(err, "Failed to create thumbnail")
+		return errors.Wrap(err, "Failed to detect file type")
 	}
 
-	if len(thumbHash) == 0 {
+	f.DetectedType = null.StringFrom(detected)
+	if err = f.Update(tx, "detected_type"); err != nil {
 		tx.Rollback()
-		return errors.Wrap(err, "No thumbnail created")
+		return errors.Wrap(err, "Failed to update detected type")
 	}
 
-	t := models.Thumbnail{
-		Hash:   thumbHash,
-		Size:   thumbSize,
-		FileID: f.ID,
-	}
-
-	if err = t.Insert(tx); err != nil {
+	ctx := &Context{Deps: deps, Tx: tx, File: &f, Type: detected, Blob: reader}
+	if err = runProcessors(ctx); err != nil {
 		tx.Rollback()
-		return errors.Wrap(err, "Failed to insert Thumbnail")
+		return errors.Wrap(err, "Failed to process file")
 	}
 
-	f.State = lib.FileFinished
-	if err = f.Update(tx, "state"); err != nil {
-		tx.Rollback()
-		return errors.Wrap(err, "Failed to set state")
+	if !lib.Quarantined(&f) {
+		f.State = lib.FileFinished
+		if err = f.Update(tx, "state"); err != nil {
+			tx.Rollback()
+			return errors.Wrap(err, "Failed to set state")
+		}
 	}
 
 	if err = tx.Commit(); err != nil {
@@ -82,6 +93,157 @@ func CompleteFile(deps dependencies.Dependencies, f models.File) error {
 		return errors.Wrap(err, "Failed to cleanup file")
 	}
 
+	discardOriginal(deps, f)
+
 	deps.Info("Processed File", "name", f.Name, "id", f.ID)
 	return nil
 }
+
+// ReprocessFile runs the processors again on a finished or quarantined file,
+// reading what was stored for it since its chunks are gone. Manifests are
+// compacted first if a processor now needs the file on disk.
+func ReprocessFile(deps dependencies.Dependencies, f models.File) error {
+	deps.Info("Reprocessing File", "name", f.Name, "id", f.ID)
+
+	data, err := openOriginal(deps, f)
+	if err != nil {
+		return errors.Wrap(err, "Failed to open file")
+	}
+	defer func() { data.Close() }()
+
+	detected, err := lib.DetectType(data, f.Name)
+	if err != nil {
+		return errors.Wrap(err, "Failed to detect file type")
+	}
+
+	// A processor added since might need what was stored as a manifest.
+	if _, stitched := data.(*lib.ManifestReader); stitched && processable(detected, f.Name) {
+		data.Close()
+		if err = CompactFile(deps, f); err != nil {
+			return err
+		}
+
+		compacted, err := openOriginal(deps, f)
+		if err != nil {
+			return errors.Wrap(err, "Failed to open file")
+		}
+		data = compacted
+	}
+
+	tx, err := deps.DB.Begin()
+	if err != nil {
+		return errors.Wrap(err, "Failed to create transaction")
+	}
+
+	if err = f.Reload(tx); err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "Failed to reload the file")
+	}
+
+	if f.State != lib.FileFinished && !lib.Quarantined(&f) {
+		tx.Rollback()
+		return errors.New("Only finished files can be reprocessed")
+	}
+
+	f.DetectedType = null.StringFrom(detected)
+	if err = f.Update(tx, "detected_type"); err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "Failed to update detected type")
+	}
+
+	ctx := &Context{Deps: deps, Tx: tx, File: &f, Type: detected, Blob: data}
+	if err = runProcessors(ctx); err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "Failed to process file")
+	}
+
+	if err = tx.Commit(); err != nil {
+		return errors.Wrap(err, "Failed to commit transaction")
+	}
+
+	discardOriginal(deps, f)
+
+	deps.Info("Reprocessed File", "name", f.Name, "id", f.ID)
+	return nil
+}
+
+// openOriginal opens what was uploaded, or the sanitized copy when the
+// original was discarded.
+func openOriginal(deps dependencies.Dependencies, f models.File) (lib.StoredFile, error) {
+	original := f
+	original.SanitizedHash = null.String{}
+
+	data, err := lib.OpenStored(deps.DB, deps.Fs, &original)
+	if err != nil && f.SanitizedHash.Valid {
+		return lib.OpenStored(deps.DB, deps.Fs, &f)
+	}
+
+	return data, err
+}
+
+// Once a sanitized copy exists the original is only kept when asked to.
+func discardOriginal(deps dependencies.Dependencies, f models.File) {
+	if !f.SanitizedHash.Valid || KeepOriginals || f.SanitizedHash.String == f.Hash {
+		return
+	}
+
+	if err := deps.Fs.Remove(lib.LocalPath(f.Hash)); err != nil && !os.IsNotExist(err) {
+		deps.Warn("Failed to remove original", "id", f.ID, "err", err)
+	}
+}
+
+// ThumbnailFile replaces the thumbnails of a finished file. Audio and video
+// files use their cover art, anything else is left without one.
+func ThumbnailFile(deps dependencies.Dependencies, f models.File) error {
+	data, err := lib.OpenStored(deps.DB, deps.Fs, &f)
+	if err != nil {
+		return errors.Wrap(err, "Failed to open file")
+	}
+	defer data.Close()
+
+	thumbs, err := fileThumbnails(deps, f, data)
+	if err != nil {
+		return err
+	}
+
+	if len(thumbs) == 0 {
+		deps.Info("No thumbnail created", "name", f.Name, "id", f.ID)
+		return nil
+	}
+
+	tx, err := deps.DB.Begin()
+	if err != nil {
+		return errors.Wrap(err, "Failed to create transaction")
+	}
+
+	// Delete all thumbnails
+	if err = models.Thumbnails(tx, qm.Where("file_id=$1", f.ID)).DeleteAll(); err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "Failed to delete old thumbnails")
+	}
+
+	for _, t := range thumbs {
+		t.FileID = f.ID
+		if err = t.Insert(tx); err != nil {
+			tx.Rollback()
+			return errors.Wrap(err, "Failed to insert Thumbnail")
+		}
+	}
+
+	return errors.Wrap(tx.Commit(), "Failed to commit transaction")
+}
+
+// fileThumbnails makes the thumbnails of an image, or of the cover art of
+// anything else. Big media files are read from their manifest.
+func fileThumbnails(deps dependencies.Dependencies, f models.File, data io.ReadSeeker) ([]models.Thumbnail, error) {
+	source := data
+	if !strings.HasPrefix(f.DetectedType.String, "image/") {
+		if source = coverArt(data); source == nil {
+			deps.Info("No cover art", "name", f.Name, "id", f.ID)
+			return nil, nil
+		}
+	}
+
+	thumbs, err := CreateThumbnails(deps, source)
+	return thumbs, errors.Wrap(err, "Failed to create thumbnails")
+}
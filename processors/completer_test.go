@@ -0,0 +1,89 @@
+package processors
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/zqzca/back/lib"
+	"github.com/zqzca/back/models"
+	null "gopkg.in/nullbio/null.v5"
+)
+
+// mp3WithCover is an ID3 tag with a PNG cover followed by silence.
+func mp3WithCover(t *testing.T) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+	img.Set(10, 10, color.RGBA{0xff, 0, 0, 0xff})
+
+	var cover bytes.Buffer
+	if err := png.Encode(&cover, img); err != nil {
+		t.Fatal(err)
+	}
+
+	frame := func(id string, data []byte) []byte {
+		n := len(data)
+		out := append([]byte(id), byte(n>>24), byte(n>>16), byte(n>>8), byte(n), 0, 0)
+		return append(out, data...)
+	}
+
+	frames := frame("APIC", append([]byte("\x00image/png\x00\x03\x00"), cover.Bytes()...))
+	n := len(frames)
+	tag := []byte{'I', 'D', '3', 3, 0, 0, byte(n >> 21 & 0x7f), byte(n >> 14 & 0x7f), byte(n >> 7 & 0x7f), byte(n & 0x7f)}
+	tag = append(tag, frames...)
+
+	return append(tag, make([]byte, 4096)...)
+}
+
+// storeManifest writes data as chunks of size bytes.
+func storeManifest(t *testing.T, fs afero.Fs, data []byte, size int) *lib.Manifest {
+	m := &lib.Manifest{}
+	for len(data) > 0 {
+		n := size
+		if n > len(data) {
+			n = len(data)
+		}
+
+		hash := fmt.Sprintf("%x", sha1.Sum(data[:n]))
+		if err := afero.WriteFile(fs, lib.ChunkPath(hash), data[:n], 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		m.Chunks = append(m.Chunks, hash)
+		m.Sizes = append(m.Sizes, int64(n))
+		data = data[n:]
+	}
+
+	return m
+}
+
+func TestFileThumbnailsFromManifest(t *testing.T) {
+	a := assert.New(t)
+
+	deps := quietDeps()
+	deps.Fs = afero.NewMemMapFs()
+
+	m := storeManifest(t, deps.Fs, mp3WithCover(t), 1000)
+	a.True(len(m.Chunks) > 1)
+
+	f := models.File{ID: "file", Name: "song.mp3", DetectedType: null.StringFrom("audio/mpeg")}
+	data := lib.OpenManifest(deps.Fs, m)
+	defer data.Close()
+
+	thumbs, err := fileThumbnails(deps, f, data)
+	a.NoError(err)
+	a.Len(thumbs, len(Renditions)*len(ThumbnailFormats))
+
+	for _, thumb := range thumbs {
+		ok, _ := afero.Exists(deps.Fs, lib.LocalPath(thumb.Hash))
+		a.True(ok, thumb.Hash)
+	}
+}
@@ -0,0 +1,55 @@
+package processors
+
+import (
+	"image"
+	"io"
+
+	"github.com/disintegration/imaging"
+	"github.com/pkg/errors"
+	"github.com/zqzca/back/dependencies"
+	"github.com/zqzca/back/lib"
+)
+
+// DeriveImage stores a resized, cropped or converted copy of an image and
+// returns its hash and size.
+func DeriveImage(deps dependencies.Dependencies, r io.ReadSeeker, o lib.ImageOptions) (string, int, error) {
+	img, format, err := DecodeImage(r)
+	if format == "" {
+		return "", 0, errors.New("Not an image")
+	}
+	if err != nil {
+		return "", 0, errors.Wrap(err, "Failed to decode image")
+	}
+
+	img = resizeImage(img, o)
+
+	return storeBlob(deps, "derived", func(w io.Writer) error {
+		switch o.Format {
+		case "png":
+			return imaging.Encode(w, img, imaging.PNG)
+		default:
+			return imaging.Encode(w, img, imaging.JPEG, imaging.JPEGQuality(o.Quality))
+		}
+	})
+}
+
+func resizeImage(img image.Image, o lib.ImageOptions) image.Image {
+	if o.Fit == lib.FitFill {
+		return imaging.Fill(img, o.Width, o.Height, imaging.Center, imaging.Lanczos)
+	}
+
+	if o.Width == 0 && o.Height == 0 {
+		return img
+	}
+
+	// A missing side doesn't constrain anything. Fit never scales up.
+	w, h := o.Width, o.Height
+	if w == 0 {
+		w = img.Bounds().Dx()
+	}
+	if h == 0 {
+		h = img.Bounds().Dy()
+	}
+
+	return imaging.Fit(img, w, h, imaging.Lanczos)
+}
@@ -0,0 +1,57 @@
+package processors
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/zqzca/back/dependencies"
+	"github.com/zqzca/back/lib"
+)
+
+// bomb is a tiny PNG whose header claims it is width by height.
+func bomb(t *testing.T, width, height uint32) []byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatal(err)
+	}
+
+	// The IHDR chunk follows the 8 byte signature, its data after the
+	// length and type.
+	data := buf.Bytes()
+	binary.BigEndian.PutUint32(data[16:], width)
+	binary.BigEndian.PutUint32(data[20:], height)
+	binary.BigEndian.PutUint32(data[29:], crc32.ChecksumIEEE(data[12:29]))
+
+	return data
+}
+
+func TestDecodeImageTooBig(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	_, format, err := DecodeImage(bytes.NewReader(bomb(t, 100000, 100000)))
+	a.Equal("png", format)
+	a.Equal(ErrImageTooBig, err)
+
+	_, _, err = DeriveImage(dependencies.Test(), bytes.NewReader(bomb(t, 100000, 100000)), lib.ImageOptions{Width: 100})
+	a.Equal(ErrImageTooBig, errors.Cause(err))
+}
+
+func TestDecodeImage(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	var buf bytes.Buffer
+	a.NoError(png.Encode(&buf, image.NewGray(image.Rect(0, 0, 30, 20))))
+
+	img, format, err := DecodeImage(bytes.NewReader(buf.Bytes()))
+	a.NoError(err)
+	a.Equal("png", format)
+	a.Equal(30, img.Bounds().Dx())
+}
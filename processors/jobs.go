@@ -0,0 +1,190 @@
+package processors
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/zqzca/back/dependencies"
+	"github.com/zqzca/back/jobs"
+	"github.com/zqzca/back/lib"
+	"github.com/zqzca/back/models"
+	"github.com/zqzca/back/serializer"
+)
+
+// Job args understood by the file processing jobs. ws_id is the websocket
+// of the uploader, announce tells everyone else about a new file once it is
+// ready.
+const (
+	argWebsocket = "ws_id"
+	argAnnounce  = "announce"
+)
+
+// UploadArgs are the job args for a freshly uploaded file.
+func UploadArgs(wsID string) map[string]string {
+	return map[string]string{argWebsocket: wsID, argAnnounce: "true"}
+}
+
+// RegisterJobs lets q run file processing. deps.Jobs must be set so
+// completed files can queue their thumbnail.
+func RegisterJobs(deps dependencies.Dependencies, q *jobs.Queue) {
+	q.Register(jobs.CompleteFile, func(job *jobs.Job) error {
+		return completeJob(deps, job)
+	})
+
+	q.Register(jobs.ThumbnailFile, func(job *jobs.Job) error {
+		return thumbnailJob(deps, job)
+	})
+
+	q.Register(jobs.ReprocessFile, func(job *jobs.Job) error {
+		return reprocessJob(deps, job)
+	})
+
+	q.Register(jobs.CompactFile, func(job *jobs.Job) error {
+		f, err := models.FindFile(deps.DB, job.FileID)
+		if err != nil {
+			return errors.Wrap(err, "Failed to find file")
+		}
+
+		return CompactFile(deps, *f)
+	})
+
+	q.Register(jobs.CleanupChunks, func(job *jobs.Job) error {
+		f, err := models.FindFile(deps.DB, job.FileID)
+		if err != nil {
+			return errors.Wrap(err, "Failed to find file")
+		}
+
+		return Cleanup(deps, f)
+	})
+}
+
+func completeJob(deps dependencies.Dependencies, job *jobs.Job) error {
+	f, err := models.FindFile(deps.DB, job.FileID)
+	if err != nil {
+		return errors.Wrap(err, "Failed to find file")
+	}
+
+	// Chunks are gone once a file is finished, there is nothing to rebuild.
+	if f.State != lib.FileFinished && !lib.Quarantined(f) {
+		if err = CompleteFile(deps, *f); err != nil {
+			return err
+		}
+
+		if err = f.Reload(deps.DB); err != nil {
+			return errors.Wrap(err, "Failed to reload the file")
+		}
+	}
+
+	wsID := job.Args[argWebsocket]
+	if lib.Quarantined(f) {
+		if len(wsID) > 0 {
+			deps.WS.WriteClient(wsID, "file:quarantined", f)
+		}
+		return nil
+	}
+
+	if len(wsID) > 0 {
+		deps.Info("Sending WS msg", "ws", wsID)
+		deps.WS.WriteClient(wsID, "file:completed", f)
+		warnSimilar(deps, wsID, f)
+	}
+
+	if thumbnailed(f) {
+		return deps.Jobs.Enqueue(jobs.ThumbnailFile, f.ID, job.Args)
+	}
+
+	announce(deps, job, f)
+	return nil
+}
+
+// Reprocessed files get fresh thumbnails too, without telling anyone.
+func reprocessJob(deps dependencies.Dependencies, job *jobs.Job) error {
+	f, err := models.FindFile(deps.DB, job.FileID)
+	if err != nil {
+		return errors.Wrap(err, "Failed to find file")
+	}
+
+	if err = ReprocessFile(deps, *f); err != nil {
+		return err
+	}
+
+	if err = f.Reload(deps.DB); err != nil {
+		return errors.Wrap(err, "Failed to reload the file")
+	}
+
+	if !lib.Quarantined(f) && thumbnailed(f) {
+		return deps.Jobs.Enqueue(jobs.ThumbnailFile, f.ID, nil)
+	}
+
+	return nil
+}
+
+// Images get thumbnails, audio and video might have cover art.
+func thumbnailed(f *models.File) bool {
+	for _, prefix := range []string{"image/", "audio/", "video/", "application/ogg"} {
+		if strings.HasPrefix(f.DetectedType.String, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func thumbnailJob(deps dependencies.Dependencies, job *jobs.Job) error {
+	f, err := models.FindFile(deps.DB, job.FileID)
+	if err != nil {
+		return errors.Wrap(err, "Failed to find file")
+	}
+
+	if lib.Quarantined(f) {
+		return nil
+	}
+
+	start := time.Now()
+	err = ThumbnailFile(deps, *f)
+	if rerr := RecordRun(deps.DB, f.ID, "thumbnail", err, time.Since(start)); rerr != nil {
+		deps.Warn("Failed to record thumbnail run", "id", f.ID, "err", rerr)
+	}
+	if err != nil {
+		return err
+	}
+
+	announce(deps, job, f)
+	return nil
+}
+
+// warnSimilar tells the uploader about files that look like the one they
+// just uploaded.
+func warnSimilar(deps dependencies.Dependencies, wsID string, f *models.File) {
+	if !f.Phash.Valid {
+		return
+	}
+
+	similar, err := lib.FindSimilar(deps.DB, f.ID, f.Phash.Int64, lib.DefaultSimilarDistance, 5)
+	if err != nil {
+		deps.Warn("Failed to look for similar files", "id", f.ID, "err", err)
+		return
+	}
+
+	var items []serializer.DashboardItem
+	for _, s := range similar {
+		if other, err := models.FindFile(deps.DB, s.FileID); err == nil {
+			items = append(items, serializer.NewDashboardItemFromFile(deps.DB, other))
+		}
+	}
+
+	if len(items) > 0 {
+		deps.WS.WriteClient(wsID, "file:similar", items)
+	}
+}
+
+func announce(deps dependencies.Dependencies, job *jobs.Job, f *models.File) {
+	if job.Args[argAnnounce] != "true" {
+		return
+	}
+
+	de := serializer.NewDashboardItemFromFile(deps.DB, f)
+	deps.WS.Broadcast("file:added", de)
+	deps.Info("Finished File", "name", f.Name, "id", f.ID)
+}
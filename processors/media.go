@@ -0,0 +1,55 @@
+package processors
+
+import (
+	"bytes"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/zqzca/back/db"
+	"github.com/zqzca/back/dependencies"
+	"github.com/zqzca/back/media"
+	"github.com/zqzca/back/models"
+	null "gopkg.in/nullbio/null.v5"
+)
+
+// ProbeMedia stores the duration, codecs and tags of audio and video files.
+// Videos also get their dimensions set on the file. Other files are left
+// alone.
+func ProbeMedia(deps dependencies.Dependencies, ex db.Executor, f *models.File, r io.ReadSeeker) error {
+	size, err := r.Seek(0, os.SEEK_END)
+	if err != nil {
+		return err
+	}
+
+	info, err := media.Probe(r, size)
+	if err == media.ErrUnknown {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "Failed to probe media")
+	}
+
+	deps.Debug("Media probed", "id", f.ID, "format", info.Format, "duration", info.Duration)
+	if err = media.SaveInfo(ex, f.ID, info); err != nil {
+		return err
+	}
+
+	if info.Width > 0 && info.Height > 0 {
+		f.Width = null.IntFrom(info.Width)
+		f.Height = null.IntFrom(info.Height)
+		return f.Update(ex, "width", "height")
+	}
+
+	return nil
+}
+
+// coverArt returns the picture embedded in an audio or video file, or nil.
+func coverArt(r io.ReadSeeker) io.ReadSeeker {
+	p := media.Cover(r)
+	if p == nil {
+		return nil
+	}
+
+	return bytes.NewReader(p.Data)
+}
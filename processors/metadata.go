@@ -0,0 +1,105 @@
+package processors
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/rwcarlsen/goexif/exif"
+	"github.com/zqzca/back/lib"
+)
+
+// ReadImageMetadata reads camera, exposure, time and location details from the
+// EXIF data of an image. Images without EXIF data return an error.
+func ReadImageMetadata(r io.ReadSeeker) (*lib.ImageMetadata, error) {
+	if _, err := r.Seek(0, os.SEEK_SET); err != nil {
+		return nil, err
+	}
+
+	x, err := exif.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &lib.ImageMetadata{
+		CameraMake:   exifString(x, exif.Make),
+		CameraModel:  exifString(x, exif.Model),
+		Lens:         exifString(x, exif.LensModel),
+		ExposureTime: exposureTime(x),
+		FNumber:      exifFloat(x, exif.FNumber),
+		FocalLength:  exifFloat(x, exif.FocalLength),
+	}
+
+	if tag, err := x.Get(exif.ISOSpeedRatings); err == nil {
+		if iso, err := tag.Int(0); err == nil {
+			m.ISO = iso
+		}
+	}
+
+	if t, err := x.DateTime(); err == nil {
+		t = t.UTC()
+		m.TakenAt = &t
+	}
+
+	if lat, lng, err := x.LatLong(); err == nil && validCoordinate(lat, lng) {
+		m.Latitude, m.Longitude = &lat, &lng
+	}
+
+	return m, nil
+}
+
+func exifString(x *exif.Exif, name exif.FieldName) string {
+	tag, err := x.Get(name)
+	if err != nil {
+		return ""
+	}
+
+	s, err := tag.StringVal()
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(strings.Trim(s, "\x00"))
+}
+
+func exifFloat(x *exif.Exif, name exif.FieldName) float64 {
+	tag, err := x.Get(name)
+	if err != nil {
+		return 0
+	}
+
+	num, den, err := tag.Rat2(0)
+	if err != nil || den == 0 {
+		return 0
+	}
+
+	return float64(num) / float64(den)
+}
+
+// Written the way cameras show it, eg. 1/250 or 2.5.
+func exposureTime(x *exif.Exif) string {
+	tag, err := x.Get(exif.ExposureTime)
+	if err != nil {
+		return ""
+	}
+
+	num, den, err := tag.Rat2(0)
+	if err != nil || num <= 0 || den <= 0 {
+		return ""
+	}
+
+	if num < den {
+		return fmt.Sprintf("1/%d", int64(math.Round(float64(den)/float64(num))))
+	}
+
+	return fmt.Sprintf("%g", float64(num)/float64(den))
+}
+
+// Plenty of cameras write 0,0 when they have no fix.
+func validCoordinate(lat, lng float64) bool {
+	return !(lat == 0 && lng == 0) &&
+		!math.IsNaN(lat) && !math.IsNaN(lng) &&
+		math.Abs(lat) <= 90 && math.Abs(lng) <= 180
+}
@@ -0,0 +1,48 @@
+package processors
+
+import (
+	"io"
+
+	"github.com/disintegration/imaging"
+	"github.com/pkg/errors"
+	"github.com/zqzca/back/dependencies"
+	"github.com/zqzca/back/lib"
+	"github.com/zqzca/back/models"
+)
+
+// PrivacyDefault applies to uploads that didn't choose.
+var PrivacyDefault = false
+
+// KeepOriginals keeps the original of a sanitized image on disk, unserved.
+// Otherwise it is deleted once processing finishes.
+var KeepOriginals = true
+
+func private(f models.File) bool {
+	if f.Privacy.Valid {
+		return f.Privacy.Bool
+	}
+
+	return PrivacyDefault
+}
+
+// SanitizeImage stores a copy of an image without EXIF, GPS, XMP, ICC
+// profiles or comments. Rotated JPEGs are re-encoded with the rotation
+// applied, dropping their EXIF would otherwise turn them on their side.
+func SanitizeImage(deps dependencies.Dependencies, r io.ReadSeeker, contentType string) (string, int, error) {
+	if lib.MediaType(contentType) == "image/jpeg" {
+		if orientation, err := readOrientation(r); err == nil && orientation > 1 {
+			img, _, err := DecodeImage(r)
+			if err != nil {
+				return "", 0, errors.Wrap(err, "Failed to decode image")
+			}
+
+			return storeBlob(deps, "sanitized", func(w io.Writer) error {
+				return imaging.Encode(w, img, imaging.JPEG, imaging.JPEGQuality(95))
+			})
+		}
+	}
+
+	return storeBlob(deps, "sanitized", func(w io.Writer) error {
+		return lib.StripMetadata(r, w, contentType)
+	})
+}
@@ -0,0 +1,195 @@
+package processors
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/zqzca/back/db"
+	"github.com/zqzca/back/dependencies"
+	"github.com/zqzca/back/lib"
+	"github.com/zqzca/back/models"
+)
+
+// Processor works on a file once it has been built and its type is known.
+// Processors that match a file run in the order they were registered.
+type Processor interface {
+	// Name identifies the processor in the recorded runs.
+	Name() string
+	// Match reports whether the processor handles a file of contentType
+	// called name.
+	Match(contentType, name string) bool
+	// Process stores what it learns about ctx.File, or blobs made from it,
+	// within ctx.Tx.
+	Process(ctx *Context) error
+}
+
+// Required is implemented by processors whose failure fails the whole file.
+// Anything else that fails is recorded and skipped.
+type Required interface {
+	Required() bool
+}
+
+// Streaming is implemented by processors that can read a file stitched
+// together from its chunks. They don't need files stored as a manifest to be
+// built first.
+type Streaming interface {
+	Streaming() bool
+}
+
+// Context is what a processor gets to work with.
+type Context struct {
+	Deps dependencies.Dependencies
+	Tx   db.Executor
+	File *models.File
+	Type string
+	Blob io.ReadSeeker
+}
+
+// Update saves columns of the file.
+func (c *Context) Update(columns ...string) error {
+	return c.File.Update(c.Tx, columns...)
+}
+
+// StoreBlob stores whatever write produces and returns its hash and size.
+func (c *Context) StoreBlob(prefix string, write func(io.Writer) error) (string, int, error) {
+	return storeBlob(c.Deps, prefix, write)
+}
+
+var registry []Processor
+
+// Register adds a processor after the ones already registered.
+func Register(p Processor) {
+	for _, existing := range registry {
+		if existing.Name() == p.Name() {
+			panic("processor registered twice: " + p.Name())
+		}
+	}
+
+	registry = append(registry, p)
+}
+
+// Registered lists processors in the order they run.
+func Registered() []Processor {
+	return append([]Processor{}, registry...)
+}
+
+// Func makes a Processor out of a name, a matcher and a function.
+func Func(name string, match func(contentType, name string) bool, run func(*Context) error) Processor {
+	return processor{name: name, match: match, run: run}
+}
+
+type processor struct {
+	name      string
+	required  bool
+	streaming bool
+	match     func(contentType, name string) bool
+	run       func(*Context) error
+}
+
+func (p processor) Name() string                        { return p.name }
+func (p processor) Match(contentType, name string) bool { return p.match(contentType, name) }
+func (p processor) Process(ctx *Context) error          { return p.run(ctx) }
+func (p processor) Required() bool                      { return p.required }
+func (p processor) Streaming() bool                     { return p.streaming }
+
+// MatchTypes matches MIME types by prefix, eg. image/, and file names by
+// extension, eg. .zip.
+func MatchTypes(types []string, extensions ...string) func(contentType, name string) bool {
+	return func(contentType, name string) bool {
+		contentType = lib.MediaType(contentType)
+		for _, t := range types {
+			if strings.HasPrefix(contentType, t) {
+				return true
+			}
+		}
+
+		ext := strings.ToLower(filepath.Ext(name))
+		for _, e := range extensions {
+			if ext == e {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// processable reports whether a processor that needs the file on disk
+// handles it.
+func processable(contentType, name string) bool {
+	for _, p := range registry {
+		if s, ok := p.(Streaming); ok && s.Streaming() {
+			continue
+		}
+
+		if p.Match(contentType, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Each processor runs in a savepoint so a failure doesn't take the rest of
+// the transaction with it.
+const (
+	savepointSQL = `SAVEPOINT processor`
+	rollbackSQL  = `ROLLBACK TO SAVEPOINT processor`
+	releaseSQL   = `RELEASE SAVEPOINT processor`
+)
+
+// runProcessors runs every processor matching the file and records how each
+// one went. Nothing runs on a file once it is quarantined.
+func runProcessors(ctx *Context) error {
+	for _, p := range registry {
+		if !p.Match(ctx.Type, ctx.File.Name) {
+			continue
+		}
+
+		if _, err := ctx.Tx.Exec(savepointSQL); err != nil {
+			return err
+		}
+
+		start := time.Now()
+		err := process(p, ctx)
+		took := time.Since(start)
+
+		if err != nil {
+			if _, rerr := ctx.Tx.Exec(rollbackSQL); rerr != nil {
+				return rerr
+			}
+
+			if r, ok := p.(Required); ok && r.Required() {
+				return errors.Wrap(err, p.Name())
+			}
+
+			ctx.Deps.Warn("Processor failed", "processor", p.Name(), "id", ctx.File.ID, "err", err)
+		} else if _, err := ctx.Tx.Exec(releaseSQL); err != nil {
+			return err
+		}
+
+		if rerr := RecordRun(ctx.Tx, ctx.File.ID, p.Name(), err, took); rerr != nil {
+			return rerr
+		}
+
+		if lib.Quarantined(ctx.File) {
+			break
+		}
+	}
+
+	return nil
+}
+
+func process(p Processor, ctx *Context) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	return p.Process(ctx)
+}
@@ -0,0 +1,228 @@
+package processors
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/zqzca/back/dependencies"
+	"github.com/zqzca/back/lib"
+	"github.com/zqzca/back/models"
+)
+
+// fakeTx records the statements it is sent, processor runs as
+// "run <processor> <succeeded>" and anything else as the query on one line.
+type fakeTx struct {
+	log  []string
+	args [][]interface{}
+}
+
+func (f *fakeTx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	f.args = append(f.args, args)
+
+	switch query {
+	case savepointSQL:
+		f.log = append(f.log, "savepoint")
+	case rollbackSQL:
+		f.log = append(f.log, "rollback")
+	case releaseSQL:
+		f.log = append(f.log, "release")
+	case recordRunSQL:
+		f.log = append(f.log, fmt.Sprintf("run %s %v", args[1], args[2]))
+	default:
+		f.log = append(f.log, strings.Join(strings.Fields(query), " "))
+	}
+
+	return driver.RowsAffected(1), nil
+}
+
+func (f *fakeTx) QueryRow(query string, args ...interface{}) *sql.Row {
+	panic("unexpected query: " + query)
+}
+
+func (f *fakeTx) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return nil, errors.New("unexpected query: " + query)
+}
+
+// withRegistry swaps the registered processors for ps, until the returned
+// func puts them back.
+func withRegistry(ps ...Processor) func() {
+	saved := registry
+	registry = ps
+	return func() { registry = saved }
+}
+
+func quietDeps() dependencies.Dependencies {
+	logger := logrus.New()
+	logger.Out = ioutil.Discard
+	return dependencies.Dependencies{Logger: logger}
+}
+
+func TestRunProcessors(t *testing.T) {
+	var ran []string
+	step := func(name string, required bool, run func(*Context) error) Processor {
+		return processor{
+			name:     name,
+			required: required,
+			match:    MatchTypes([]string{"image/"}),
+			run: func(ctx *Context) error {
+				ran = append(ran, name)
+				return run(ctx)
+			},
+		}
+	}
+
+	ok := func(*Context) error { return nil }
+	fail := func(*Context) error { return errors.New("failed") }
+	boom := func(*Context) error { panic("boom") }
+	quarantine := func(ctx *Context) error {
+		ctx.File.State = lib.FileQuarantined
+		return nil
+	}
+
+	tests := []struct {
+		name       string
+		processors []Processor
+		err        string
+		ran        []string
+		log        []string
+	}{
+		{
+			name:       "in order",
+			processors: []Processor{step("a", false, ok), step("b", true, ok)},
+			ran:        []string{"a", "b"},
+			log:        []string{"savepoint", "release", "run a true", "savepoint", "release", "run b true"},
+		},
+		{
+			name: "unmatched",
+			processors: []Processor{
+				processor{name: "audio", match: MatchTypes([]string{"audio/"}), run: fail},
+				step("a", false, ok),
+			},
+			ran: []string{"a"},
+			log: []string{"savepoint", "release", "run a true"},
+		},
+		{
+			name:       "optional failure rolls back",
+			processors: []Processor{step("a", false, fail), step("b", false, ok)},
+			ran:        []string{"a", "b"},
+			log:        []string{"savepoint", "rollback", "run a false", "savepoint", "release", "run b true"},
+		},
+		{
+			name:       "required failure aborts",
+			processors: []Processor{step("a", true, fail), step("b", false, ok)},
+			err:        "a: failed",
+			ran:        []string{"a"},
+			log:        []string{"savepoint", "rollback"},
+		},
+		{
+			name:       "panic is recovered",
+			processors: []Processor{step("a", false, boom), step("b", false, ok)},
+			ran:        []string{"a", "b"},
+			log:        []string{"savepoint", "rollback", "run a false", "savepoint", "release", "run b true"},
+		},
+		{
+			name:       "required panic aborts",
+			processors: []Processor{step("a", true, boom), step("b", false, ok)},
+			err:        "a: panic: boom",
+			ran:        []string{"a"},
+			log:        []string{"savepoint", "rollback"},
+		},
+		{
+			name:       "quarantine stops",
+			processors: []Processor{step("a", true, quarantine), step("b", false, ok)},
+			ran:        []string{"a"},
+			log:        []string{"savepoint", "release", "run a true"},
+		},
+	}
+
+	defer withRegistry()()
+
+	for _, test := range tests {
+		a := assert.New(t)
+		registry = test.processors
+		ran = nil
+
+		tx := &fakeTx{}
+		ctx := &Context{
+			Deps: quietDeps(),
+			Tx:   tx,
+			File: &models.File{ID: "file", Name: "cat.png", State: lib.FileProcessing},
+			Type: "image/png",
+		}
+
+		err := runProcessors(ctx)
+		if len(test.err) > 0 {
+			a.EqualError(err, test.err, test.name)
+		} else {
+			a.NoError(err, test.name)
+		}
+
+		a.Equal(test.ran, ran, test.name)
+		a.Equal(test.log, tx.log, test.name)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	a := assert.New(t)
+	defer withRegistry()()
+
+	none := func(contentType, name string) bool { return false }
+	for _, name := range []string{"b", "a", "c"} {
+		Register(Func(name, none, nil))
+	}
+
+	var names []string
+	for _, p := range Registered() {
+		names = append(names, p.Name())
+	}
+	a.Equal([]string{"b", "a", "c"}, names)
+
+	a.PanicsWithValue("processor registered twice: a", func() {
+		Register(Func("a", none, nil))
+	})
+	a.Len(Registered(), 3)
+}
+
+func TestMatchTypes(t *testing.T) {
+	t.Parallel()
+
+	match := MatchTypes([]string{"image/", "application/zip"}, ".zip", ".tar")
+
+	tests := []struct {
+		contentType string
+		name        string
+		want        bool
+	}{
+		{"image/png", "cat.png", true},
+		{"IMAGE/JPEG; charset=binary", "cat", true},
+		{"application/zip", "", true},
+		{"application/octet-stream", "backup.ZIP", true},
+		{"application/octet-stream", "backup.tar", true},
+		{"application/octet-stream", "backup.tar.gz", false},
+		{"text/plain", "zip", false},
+		{"video/mp4", "clip.mp4", false},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.want, match(test.contentType, test.name), strings.Join([]string{test.contentType, test.name}, " "))
+	}
+}
+
+// Only images need to be built into a blob, archives and media are read
+// from their chunks.
+func TestProcessable(t *testing.T) {
+	a := assert.New(t)
+
+	a.True(processable("image/png", "cat.png"))
+	a.False(processable("application/zip", "backup.zip"))
+	a.False(processable("application/x-tar", "backup.tar"))
+	a.False(processable("video/mp4", "clip.mp4"))
+	a.False(processable("application/octet-stream", "disk.img"))
+}
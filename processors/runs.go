@@ -0,0 +1,70 @@
+package processors
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/zqzca/back/db"
+)
+
+// Run is how a processor last went for a file.
+type Run struct {
+	Processor string    `json:"processor"`
+	Succeeded bool      `json:"succeeded"`
+	Error     string    `json:"error,omitempty"`
+	Duration  int64     `json:"duration_ms"`
+	RanAt     time.Time `json:"ran_at"`
+}
+
+const recordRunSQL = `
+	INSERT INTO processor_runs (file_id, processor, succeeded, error, duration_ms)
+	VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT (file_id, processor) DO UPDATE SET
+	succeeded = EXCLUDED.succeeded, error = EXCLUDED.error,
+	duration_ms = EXCLUDED.duration_ms
+`
+
+const loadRunsSQL = `
+	SELECT processor, succeeded, error, duration_ms, updated_at
+	FROM processor_runs
+	WHERE file_id = $1
+	ORDER BY created_at ASC
+`
+
+// RecordRun stores the outcome of a processor, replacing the previous one.
+func RecordRun(ex db.Executor, fileID, processor string, err error, took time.Duration) error {
+	var msg sql.NullString
+	if err != nil {
+		msg = sql.NullString{String: err.Error(), Valid: true}
+	}
+
+	_, rerr := ex.Exec(recordRunSQL, fileID, processor, err == nil, msg, int64(took/time.Millisecond))
+	return errors.Wrap(rerr, "Failed to record processor run")
+}
+
+// LoadRuns lists how every processor went for a file.
+func LoadRuns(ex db.Executor, fileID string) ([]Run, error) {
+	rows, err := ex.Query(loadRunsSQL, fileID)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to load processor runs")
+	}
+	defer rows.Close()
+
+	var runs []Run
+	for rows.Next() {
+		var (
+			r   Run
+			msg sql.NullString
+		)
+
+		if err = rows.Scan(&r.Processor, &r.Succeeded, &msg, &r.Duration, &r.RanAt); err != nil {
+			return nil, err
+		}
+
+		r.Error = msg.String
+		runs = append(runs, r)
+	}
+
+	return runs, rows.Err()
+}
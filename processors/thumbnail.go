@@ -9,11 +9,16 @@ import (
 	_ "image/png"  // PNG Support
 	"io"
 	"os"
+	"strconv"
+	"strings"
 
+	"github.com/HugoSmits86/nativewebp"
 	"github.com/disintegration/imaging"
+	"github.com/pkg/errors"
 	"github.com/rwcarlsen/goexif/exif"
 	"github.com/zqzca/back/dependencies"
 	"github.com/zqzca/back/lib"
+	"github.com/zqzca/back/models"
 )
 
 func rotate(img image.Image, orientation int) image.Image {
@@ -98,81 +103,230 @@ func readOrientation(r io.ReadSeeker) (int, error) {
 	return orientation, nil
 }
 
-// CreateThumnail builds a JPG thumbnail and can rotate if an exif bit is set.
-func CreateThumbnail(deps dependencies.Dependencies, r io.ReadSeeker) (string, int, error) {
-	raw, format, err := image.Decode(r)
+// imageDimensions reads the width and height of an image without decoding
+// all of it. Dimensions are swapped for EXIF orientations that rotate by 90
+// degrees so they match what is displayed.
+func imageDimensions(r io.ReadSeeker) (int, int, error) {
+	if _, err := r.Seek(0, os.SEEK_SET); err != nil {
+		return 0, 0, err
+	}
 
-	if format == "" {
-		return "", 0, nil
+	cfg, format, err := image.DecodeConfig(r)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	w, h := cfg.Width, cfg.Height
+	if format == "jpeg" {
+		if orientation, err := readOrientation(r); err == nil && orientation >= 5 {
+			w, h = h, w
+		}
+	}
+
+	_, err = r.Seek(0, os.SEEK_SET)
+	return w, h, err
+}
+
+// Rendition is a thumbnail made for every image. Square renditions are
+// Width pixels on each side, fit renditions are at most Width wide.
+type Rendition struct {
+	Kind  string
+	Width int
+}
+
+// Renditions are made in each of the ThumbnailFormats.
+var Renditions = []Rendition{
+	{lib.ThumbnailSquare, 200},
+	{lib.ThumbnailSquare, 400},
+	{lib.ThumbnailFit, 400},
+	{lib.ThumbnailFit, 800},
+	{lib.ThumbnailFit, 1600},
+}
+
+// ThumbnailFormats every rendition is encoded in. WebP has to be asked for,
+// its encoder is lossless only so the thumbnails come out bigger than JPEG.
+var ThumbnailFormats = []string{lib.ThumbnailJPEG}
+
+// ParseRenditions reads renditions written as kind:width, eg. square:200.
+func ParseRenditions(specs []string) ([]Rendition, error) {
+	var out []Rendition
+
+	for _, spec := range specs {
+		parts := strings.SplitN(strings.TrimSpace(spec), ":", 2)
+		if len(parts) != 2 {
+			return nil, errors.New("Invalid rendition: " + spec)
+		}
+
+		if parts[0] != lib.ThumbnailSquare && parts[0] != lib.ThumbnailFit {
+			return nil, errors.New("Unknown rendition kind: " + parts[0])
+		}
+
+		width, err := strconv.Atoi(parts[1])
+		if err != nil || width < 1 {
+			return nil, errors.New("Invalid rendition width: " + spec)
+		}
+
+		out = append(out, Rendition{Kind: parts[0], Width: width})
+	}
+
+	if len(out) == 0 {
+		return nil, errors.New("No renditions")
+	}
+
+	return out, nil
+}
+
+// MaxImagePixels is the most pixels an image may have to be decoded, about
+// 200MB once decoded. Bigger images get no thumbnails or resized copies.
+var MaxImagePixels = 50 * 1000 * 1000
+
+// ErrImageTooBig is returned for images over MaxImagePixels.
+var ErrImageTooBig = errors.New("Image is too big to decode")
+
+// DecodeImage decodes an image and rotates it according to its EXIF
+// orientation. The format is empty when r isn't an image at all.
+func DecodeImage(r io.ReadSeeker) (image.Image, string, error) {
+	if _, err := r.Seek(0, os.SEEK_SET); err != nil {
+		return nil, "", err
+	}
+
+	// A few KB can claim dimensions that take GBs to decode into.
+	cfg, format, err := image.DecodeConfig(r)
+	if err != nil {
+		return nil, format, err
+	}
+	if cfg.Width*cfg.Height > MaxImagePixels {
+		return nil, format, ErrImageTooBig
+	}
+
+	if _, err = r.Seek(0, os.SEEK_SET); err != nil {
+		return nil, format, err
 	}
 
-	if format == "jpeg" || format == "jpg" {
-		deps.Debug("Received JPG")
-		orientation, err := readOrientation(r)
+	raw, format, err := image.Decode(r)
+	if err != nil {
+		return nil, format, err
+	}
 
-		if err == nil {
-			deps.Debug("Rotating JPG", "orientation", orientation)
+	if format == "jpeg" {
+		if orientation, err := readOrientation(r); err == nil {
 			raw = rotate(raw, orientation)
 		}
 	}
 
-	deps.Debug("Thumbnail format", "fmt", format)
+	return raw, format, nil
+}
+
+// CreateThumbnails builds every rendition of an image, rotated according to
+// its EXIF orientation. Nothing is returned for files that aren't images.
+func CreateThumbnails(deps dependencies.Dependencies, r io.ReadSeeker) ([]models.Thumbnail, error) {
+	raw, format, err := DecodeImage(r)
+
+	if format == "" {
+		return nil, nil
+	}
+
+	if err == ErrImageTooBig {
+		deps.Info("Image too big for thumbnails", "fmt", format)
+		return nil, nil
+	}
 
 	if err != nil {
 		deps.Error("Failed to decode image")
-		return "", 0, err
+		return nil, err
+	}
+
+	deps.Debug("Thumbnail format", "fmt", format)
+
+	var thumbs []models.Thumbnail
+	for _, rendition := range Renditions {
+		dst := raw
+		if rendition.Kind == lib.ThumbnailSquare {
+			dst = imaging.Fill(raw, rendition.Width, rendition.Width, imaging.Center, imaging.Lanczos)
+		} else if raw.Bounds().Dx() > rendition.Width {
+			// Images are never made bigger to fit.
+			dst = imaging.Resize(raw, rendition.Width, 0, imaging.Lanczos)
+		}
+
+		for _, f := range ThumbnailFormats {
+			hash, size, err := storeBlob(deps, "thumbnail", func(w io.Writer) error {
+				return encodeThumbnail(w, dst, f)
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			thumbs = append(thumbs, models.Thumbnail{
+				Hash:   hash,
+				Size:   size,
+				Width:  dst.Bounds().Dx(),
+				Height: dst.Bounds().Dy(),
+				Format: f,
+				Kind:   rendition.Kind,
+			})
+		}
 	}
 
+	// The renditions above are made from the first frame and double as the
+	// poster for the animated one.
+	if format == "gif" {
+		anim, err := animatedThumbnail(deps, r)
+		if err != nil {
+			deps.Warn("Failed to make animated thumbnail", "err", err)
+		} else if anim != nil {
+			thumbs = append(thumbs, *anim)
+		}
+	}
+
+	return thumbs, nil
+}
+
+func encodeThumbnail(w io.Writer, img image.Image, format string) error {
+	if format == lib.ThumbnailWebP {
+		return nativewebp.Encode(w, img, nil)
+	}
+
+	return imaging.Encode(w, img, imaging.JPEG)
+}
+
+// storeBlob writes whatever write produces to a temp file and moves it to
+// its content address.
+func storeBlob(deps dependencies.Dependencies, prefix string, write func(io.Writer) error) (string, int, error) {
 	fs := deps.Fs
-	tmpFilePath := lib.TempFilePath("thumbnail")
+	tmpFilePath := lib.TempFilePath(prefix)
 	tmpFile, err := fs.Create(tmpFilePath)
 	if err != nil {
 		deps.Error("Failed to create temp file", "path", tmpFilePath)
 		return "", 0, err
 	}
 
-	// Make sure we close.
-	closeTmpFile := func() {
-		if tmpFile != nil {
-			tmpFile.Close()
-			tmpFile = nil
-		}
-	}
-
-	defer closeTmpFile()
-
 	h := sha1.New()
 	var wc writeCounter
-	mw := io.MultiWriter(tmpFile, h, wc)
+	mw := io.MultiWriter(tmpFile, h, &wc)
 
-	// Generate Thumbnail image data
-	dst := imaging.Fill(raw, 200, 200, imaging.Center, imaging.Lanczos)
-	// Write it
-	err = imaging.Encode(mw, dst, imaging.JPEG)
+	err = write(mw)
+	tmpFile.Close()
 	if err != nil {
 		deps.Error("Failed to encode data")
+		fs.Remove(tmpFilePath)
 		return "", 0, err
 	}
 
 	hash := fmt.Sprintf("%x", h.Sum(nil))
-	deps.Debug("Thumbnail hash", "hash:", hash)
+	deps.Debug("Stored blob", "prefix", prefix, "hash", hash)
 	newPath := lib.LocalPath(hash)
 
 	// Move temp thumbnail to final destination.
-	err = os.Rename(tmpFilePath, newPath)
-	if err != nil {
+	if err = fs.Rename(tmpFilePath, newPath); err != nil {
 		deps.Error("Failed to rename file")
-
-		// Todo delete file
-		return hash, int(wc), err
+		fs.Remove(tmpFilePath)
+		return "", 0, err
 	}
 
 	// Set permissons
-	err = fs.Chmod(newPath, 0644)
-	if err != nil {
+	if err = fs.Chmod(newPath, 0644); err != nil {
 		deps.Error("Failed to set permissions", "path", newPath)
-		// Todo delete file
-		return hash, int(wc), err
+		return "", 0, err
 	}
 
 	return hash, int(wc), nil
@@ -180,8 +334,8 @@ func CreateThumbnail(deps dependencies.Dependencies, r io.ReadSeeker) (string, i
 
 type writeCounter int64
 
-func (w writeCounter) Write(b []byte) (int, error) {
-	w += writeCounter(len(b))
+func (w *writeCounter) Write(b []byte) (int, error) {
+	*w += writeCounter(len(b))
 
 	return len(b), nil
 }
@@ -21,6 +21,7 @@ import (
 	uuid "github.com/satori/go.uuid"
 	"github.com/zqzca/back/db"
 	"github.com/zqzca/back/dependencies"
+	"github.com/zqzca/back/lib"
 
 	"golang.org/x/crypto/ssh"
 )
@@ -81,14 +82,19 @@ func (s *Server) ListenAndServe() {
 			continue
 		}
 
+		// SCP is not proxied so the peer address is the client.
+		ip := lib.AddrIP(nConn.RemoteAddr())
+
 		// Before use, a handshake must be performed on the incoming
 		// net.Conn.
 		_, chans, reqs, err := ssh.NewServerConn(nConn, config)
 		if err != nil {
-			s.Error("Failed to create SCP connection", "err", err)
+			s.Error("Failed to create SCP connection", "err", err, "ip", ip)
 			continue
 		}
 
+		s.Info("SCP Connection", "ip", ip)
+
 		// Discard all global out-of-band Requests
 		go ssh.DiscardRequests(reqs)
 
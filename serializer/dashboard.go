@@ -0,0 +1,55 @@
+package serializer
+
+import (
+	"time"
+
+	"github.com/zqzca/back/db"
+	"github.com/zqzca/back/models"
+	null "gopkg.in/nullbio/null.v5"
+)
+
+// DashboardItem is a file as listed on the dashboard. ThumbnailID is a static
+// image that doubles as the poster for AnimatedThumbnailID, which is only set
+// for animated GIFs. BlurHash and Colors can be painted while the thumbnail
+// loads.
+type DashboardItem struct {
+	Name                string      `json:"name"`
+	ThumbnailID         null.String `json:"thumbnail_id"`
+	AnimatedThumbnailID null.String `json:"animated_thumbnail_id,omitempty"`
+	BlurHash            null.String `json:"blurhash,omitempty"`
+	Colors              []string    `json:"colors,omitempty"`
+	Slug                string      `json:"slug"`
+	CreatedAt           time.Time   `json:"created_at"`
+}
+
+const dashboardThumbnailSQL = `
+	SELECT
+	(
+		SELECT id FROM thumbnails
+		WHERE file_id = $1 AND format = 'jpeg' AND kind IN ('square', 'fit')
+		ORDER BY kind = 'square' DESC, width ASC
+		LIMIT 1
+	),
+	(
+		SELECT id FROM thumbnails
+		WHERE file_id = $1 AND kind = 'animated'
+		LIMIT 1
+	)
+`
+
+// NewDashboardItemFromFile serializes a single file the way the dashboard
+// lists it.
+func NewDashboardItemFromFile(ex db.Executor, f *models.File) DashboardItem {
+	item := DashboardItem{
+		Name:      f.Name,
+		BlurHash:  f.Blurhash,
+		Colors:    f.Colors,
+		Slug:      f.Slug,
+		CreatedAt: f.CreatedAt,
+	}
+
+	// Files without thumbnails are still listed.
+	ex.QueryRow(dashboardThumbnailSQL, f.ID).Scan(&item.ThumbnailID, &item.AnimatedThumbnailID)
+
+	return item
+}
@@ -4,6 +4,8 @@ import (
 	"time"
 
 	"github.com/zqzca/back/db"
+	"github.com/zqzca/back/lib"
+	"github.com/zqzca/back/media"
 	"github.com/zqzca/back/models"
 )
 
@@ -13,27 +15,81 @@ type File struct {
 	Name      string    `json:"name"`
 	Hash      string    `json:"hash"`
 	Type      string    `json:"type"`
+	Detected  string    `json:"detected_type,omitempty"`
+	Width     int       `json:"width,omitempty"`
+	Height    int       `json:"height,omitempty"`
+	BlurHash  string    `json:"blurhash,omitempty"`
+	Colors    []string  `json:"colors,omitempty"`
 	Downloads int       `json:"downloads"`
 	CreatedAt time.Time `json:"created_at"`
+
+	Metadata *lib.ImageMetadata `json:"metadata,omitempty"`
+	Media    *media.Info        `json:"media,omitempty"`
+}
+
+// SimilarFile is a file that looks like another one. Distance is how many
+// bits of their perceptual hashes differ.
+type SimilarFile struct {
+	File
+	Distance int `json:"distance"`
 }
 
 var FileDownloads func(db.Executor, *models.File) int
 
-// ForFile
+// FileMetadata returns the metadata of a file, or nil when it has none.
+var FileMetadata func(db.Executor, *models.File) *lib.ImageMetadata
+
+// FileMedia returns what was probed from an audio or video file, or nil.
+var FileMedia func(db.Executor, *models.File) *media.Info
+
+// ForFile serializes a file for anyone. Metadata the owner hid is left out.
 func ForFile(db db.Executor, f *models.File) File {
-	return File{
+	out := File{
 		Slug:      f.Slug,
 		Size:      f.Size,
 		Name:      f.Name,
 		Hash:      f.Hash,
 		Type:      f.Type,
+		Detected:  f.DetectedType.String,
+		Width:     f.Width.Int,
+		Height:    f.Height.Int,
+		BlurHash:  f.Blurhash.String,
+		Colors:    f.Colors,
 		Downloads: FileDownloads(db, f),
 		CreatedAt: f.CreatedAt,
 	}
+
+	if m := FileMetadata(db, f); m != nil {
+		redacted := m.Redacted()
+		out.Metadata = &redacted
+	}
+
+	out.Media = FileMedia(db, f)
+
+	return out
 }
 
 func init() {
 	FileDownloads = func(ex db.Executor, f *models.File) int {
 		return int(f.Downloads(ex).CountP())
 	}
+
+	FileMetadata = func(ex db.Executor, f *models.File) *lib.ImageMetadata {
+		m, err := lib.LoadImageMetadata(ex, f.ID)
+		if err != nil || m == nil {
+			return nil
+		}
+
+		m.Width, m.Height = f.Width.Int, f.Height.Int
+		return m
+	}
+
+	FileMedia = func(ex db.Executor, f *models.File) *media.Info {
+		i, err := media.LoadInfo(ex, f.ID)
+		if err != nil {
+			return nil
+		}
+
+		return i
+	}
 }
@@ -8,19 +8,21 @@ import (
 	"github.com/zqzca/back/lib"
 	"github.com/zqzca/back/models"
 	"github.com/zqzca/back/serializer"
+	null "gopkg.in/nullbio/null.v5"
 )
 
 func TestForFile(t *testing.T) {
 	now := time.Now()
 
 	f := &models.File{
-		Name:      "foo",
-		Hash:      "123",
-		Type:      "image",
-		CreatedAt: now,
-		Slug:      "abc",
-		Size:      100,
-		State:     lib.FileProcessing,
+		Name:         "foo",
+		Hash:         "123",
+		Type:         "image",
+		DetectedType: null.StringFrom("image/png"),
+		CreatedAt:    now,
+		Slug:         "abc",
+		Size:         100,
+		State:        lib.FileProcessing,
 	}
 
 	s := serializer.ForFile(nil, f)
@@ -29,6 +31,55 @@ func TestForFile(t *testing.T) {
 	assert.Equal(t, "foo", js["name"])
 	assert.Equal(t, "123", js["hash"])
 	assert.Equal(t, "image", js["type"])
+	assert.Equal(t, "image/png", js["detected_type"])
 	assert.Equal(t, now.Format(time.RFC3339Nano), js["created_at"])
 	assert.Equal(t, 100.0, js["size"])
 }
+
+func TestForFileHidesMetadata(t *testing.T) {
+	a := assert.New(t)
+
+	s := serializer.ForFile(nil, &models.File{Slug: "exif"})
+	js := renderJSON(s)
+
+	m := js["metadata"].(map[string]interface{})
+	a.Equal("Canon", m["camera_make"])
+	a.Nil(m["latitude"])
+	a.Nil(m["longitude"])
+	a.Nil(m["hidden"])
+
+	js = renderJSON(serializer.ForFile(nil, &models.File{Slug: "plain"}))
+	a.Nil(js["metadata"])
+}
+
+func TestForFilePlaceholder(t *testing.T) {
+	a := assert.New(t)
+
+	f := &models.File{
+		Slug:     "plain",
+		Blurhash: null.StringFrom("LEHV6nWB2yk8pyo0adR*.7kCMdnj"),
+		Colors:   []string{"#ff0000", "#0000ff"},
+	}
+
+	js := renderJSON(serializer.ForFile(nil, f))
+	a.Equal("LEHV6nWB2yk8pyo0adR*.7kCMdnj", js["blurhash"])
+	a.Equal([]interface{}{"#ff0000", "#0000ff"}, js["colors"])
+
+	js = renderJSON(serializer.ForFile(nil, &models.File{Slug: "plain"}))
+	a.Nil(js["blurhash"])
+	a.Nil(js["colors"])
+}
+
+func TestForFileMedia(t *testing.T) {
+	a := assert.New(t)
+
+	js := renderJSON(serializer.ForFile(nil, &models.File{Slug: "song"}))
+	m := js["media"].(map[string]interface{})
+	a.Equal("mp3", m["format"])
+	a.Equal(184.5, m["duration"])
+	a.Equal("Someone", m["artist"])
+	a.Nil(m["width"])
+
+	js = renderJSON(serializer.ForFile(nil, &models.File{Slug: "plain"}))
+	a.Nil(js["media"])
+}
@@ -0,0 +1,44 @@
+package serializer
+
+import "encoding/xml"
+
+// OEmbed is an oEmbed 1.0 response. See https://oembed.com/
+type OEmbed struct {
+	XMLName xml.Name `json:"-" xml:"oembed"`
+
+	Type         string `json:"type" xml:"type"`
+	Version      string `json:"version" xml:"version"`
+	Title        string `json:"title,omitempty" xml:"title,omitempty"`
+	ProviderName string `json:"provider_name" xml:"provider_name"`
+	ProviderURL  string `json:"provider_url" xml:"provider_url"`
+
+	// Photo only.
+	URL string `json:"url,omitempty" xml:"url,omitempty"`
+	// Video and rich only.
+	HTML string `json:"html,omitempty" xml:"html,omitempty"`
+	// Required for photo, video and rich.
+	Width  int `json:"width,omitempty" xml:"width,omitempty"`
+	Height int `json:"height,omitempty" xml:"height,omitempty"`
+
+	ThumbnailURL    string `json:"thumbnail_url,omitempty" xml:"thumbnail_url,omitempty"`
+	ThumbnailWidth  int    `json:"thumbnail_width,omitempty" xml:"thumbnail_width,omitempty"`
+	ThumbnailHeight int    `json:"thumbnail_height,omitempty" xml:"thumbnail_height,omitempty"`
+}
+
+// Fit scales the width and height down to fit within maxWidth and maxHeight,
+// keeping the aspect ratio. A zero max means no limit.
+func (o *OEmbed) Fit(maxWidth, maxHeight int) {
+	if o.Width == 0 || o.Height == 0 {
+		return
+	}
+
+	if maxWidth > 0 && o.Width > maxWidth {
+		o.Height = o.Height * maxWidth / o.Width
+		o.Width = maxWidth
+	}
+
+	if maxHeight > 0 && o.Height > maxHeight {
+		o.Width = o.Width * maxHeight / o.Height
+		o.Height = maxHeight
+	}
+}
@@ -0,0 +1,48 @@
+package serializer_test
+
+import (
+	"encoding/xml"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zqzca/back/serializer"
+)
+
+func TestOEmbedXML(t *testing.T) {
+	a := assert.New(t)
+
+	o := serializer.OEmbed{
+		Type:         "video",
+		Version:      "1.0",
+		ProviderName: "zqz.ca",
+		ProviderURL:  "https://zqz.ca",
+		HTML:         `<video src="x"></video>`,
+		Width:        640,
+		Height:       360,
+	}
+
+	b, err := xml.Marshal(o)
+	a.Nil(err)
+	a.Equal(`<oembed><type>video</type><version>1.0</version><provider_name>zqz.ca</provider_name><provider_url>https://zqz.ca</provider_url><html>&lt;video src=&#34;x&#34;&gt;&lt;/video&gt;</html><width>640</width><height>360</height></oembed>`, string(b))
+
+	js := renderJSON(o)
+	a.Equal("video", js["type"])
+	a.Equal(640.0, js["width"])
+	a.Nil(js["url"])
+}
+
+func TestOEmbedFit(t *testing.T) {
+	a := assert.New(t)
+
+	o := serializer.OEmbed{Width: 4000, Height: 3000}
+	o.Fit(800, 0)
+	a.Equal(800, o.Width)
+	a.Equal(600, o.Height)
+
+	o.Fit(0, 300)
+	a.Equal(400, o.Width)
+	a.Equal(300, o.Height)
+
+	o.Fit(1000, 1000)
+	a.Equal(400, o.Width)
+}
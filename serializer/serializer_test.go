@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 
 	"github.com/zqzca/back/db"
+	"github.com/zqzca/back/lib"
+	"github.com/zqzca/back/media"
 	"github.com/zqzca/back/models"
 	"github.com/zqzca/back/serializer"
 )
@@ -12,6 +14,28 @@ func init() {
 	serializer.FileDownloads = func(_ db.Executor, _ *models.File) int {
 		return 1
 	}
+
+	serializer.FileMetadata = func(_ db.Executor, f *models.File) *lib.ImageMetadata {
+		if f.Slug != "exif" {
+			return nil
+		}
+
+		lat, lng := 43.65, -79.38
+		return &lib.ImageMetadata{
+			CameraMake: "Canon",
+			Latitude:   &lat,
+			Longitude:  &lng,
+			Hidden:     []string{lib.MetadataLocation},
+		}
+	}
+
+	serializer.FileMedia = func(_ db.Executor, f *models.File) *media.Info {
+		if f.Slug != "song" {
+			return nil
+		}
+
+		return &media.Info{Format: media.MP3, AudioCodec: "mp3", Duration: 184.5, Artist: "Someone"}
+	}
 }
 
 func renderJSON(d interface{}) map[string]interface{} {
@@ -0,0 +1,80 @@
+package serializer
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Stats is a summary of downloads for a file or for the whole site.
+type Stats struct {
+	Interval       string        `json:"interval"`
+	Since          time.Time     `json:"since"`
+	Downloads      int           `json:"downloads"`
+	UniqueVisitors int           `json:"unique_visitors"`
+	CacheHitRatio  float64       `json:"cache_hit_ratio"`
+	Bots           int           `json:"bots"`
+	Completed      int           `json:"completed"`
+	Series         []StatsBucket `json:"series"`
+	Referrers      []StatsCount  `json:"top_referrers"`
+	UserAgents     []StatsCount  `json:"top_user_agents"`
+	Countries      []StatsCount  `json:"top_countries"`
+	ASNs           []StatsCount  `json:"top_asns"`
+}
+
+// StatsBucket is a single point in the download time series.
+type StatsBucket struct {
+	Time           time.Time `json:"time"`
+	Downloads      int       `json:"downloads"`
+	UniqueVisitors int       `json:"unique_visitors"`
+	CacheHits      int       `json:"cache_hits"`
+}
+
+// StatsCount is a value and how many downloads had it.
+type StatsCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// WriteSeriesCSV writes the time series as CSV with a header row.
+func (s Stats) WriteSeriesCSV(w io.Writer) error {
+	c := csv.NewWriter(w)
+	c.Write([]string{"time", "downloads", "unique_visitors", "cache_hits"})
+
+	for _, b := range s.Series {
+		c.Write([]string{
+			b.Time.UTC().Format(time.RFC3339),
+			strconv.Itoa(b.Downloads),
+			strconv.Itoa(b.UniqueVisitors),
+			strconv.Itoa(b.CacheHits),
+		})
+	}
+
+	c.Flush()
+	return c.Error()
+}
+
+// WriteCountsCSV writes a top-N list as CSV with a header row.
+func WriteCountsCSV(w io.Writer, name string, counts []StatsCount) error {
+	c := csv.NewWriter(w)
+	c.Write([]string{name, "count"})
+
+	for _, v := range counts {
+		c.Write([]string{csvCell(v.Value), strconv.Itoa(v.Count)})
+	}
+
+	c.Flush()
+	return c.Error()
+}
+
+// csvCell keeps spreadsheets from running values visitors control, like
+// referrers, as formulas.
+func csvCell(s string) string {
+	if len(s) > 0 && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+
+	return s
+}
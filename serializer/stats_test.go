@@ -0,0 +1,46 @@
+package serializer_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zqzca/back/serializer"
+)
+
+func TestStatsWriteSeriesCSV(t *testing.T) {
+	a := assert.New(t)
+	at := time.Date(2016, 10, 16, 11, 0, 0, 0, time.UTC)
+
+	s := serializer.Stats{
+		Series: []serializer.StatsBucket{
+			{Time: at, Downloads: 3, UniqueVisitors: 2, CacheHits: 1},
+		},
+	}
+
+	var b bytes.Buffer
+	a.Nil(s.WriteSeriesCSV(&b))
+	a.Equal("time,downloads,unique_visitors,cache_hits\n2016-10-16T11:00:00Z,3,2,1\n", b.String())
+}
+
+func TestWriteCountsCSV(t *testing.T) {
+	a := assert.New(t)
+
+	counts := []serializer.StatsCount{{Value: "https://a.com/, \"x\"", Count: 4}}
+
+	var b bytes.Buffer
+	a.Nil(serializer.WriteCountsCSV(&b, "referrer", counts))
+	a.Equal("referrer,count\n\"https://a.com/, \"\"x\"\"\",4\n", b.String())
+
+	counts = []serializer.StatsCount{
+		{Value: "=HYPERLINK(\"x\")", Count: 1},
+		{Value: "@SUM(1)", Count: 1},
+		{Value: "\tcmd", Count: 1},
+		{Value: "a-b", Count: 1},
+	}
+
+	b.Reset()
+	a.Nil(serializer.WriteCountsCSV(&b, "referrer", counts))
+	a.Equal("referrer,count\n\"'=HYPERLINK(\"\"x\"\")\",1\n'@SUM(1),1\n'\tcmd,1\na-b,1\n", b.String())
+}
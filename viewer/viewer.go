@@ -0,0 +1,176 @@
+package viewer
+
+import (
+	"bytes"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/alecthomas/chroma"
+	"github.com/alecthomas/chroma/formatters/html"
+	"github.com/alecthomas/chroma/lexers"
+	"github.com/alecthomas/chroma/styles"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/pkg/errors"
+	"github.com/russross/blackfriday/v2"
+	"github.com/zqzca/back/lib"
+)
+
+// DefaultLimit is how much of a file is rendered before it is truncated.
+const DefaultLimit = 256 * 1024
+
+// ErrBinary is returned for content that isn't valid UTF-8 text.
+var ErrBinary = errors.New("Not a text file")
+
+// Types outside of text/* that are still worth reading.
+var textTypes = map[string]bool{
+	"application/json":       true,
+	"application/javascript": true,
+	"application/x-sh":       true,
+	"application/x-yaml":     true,
+	"application/toml":       true,
+	"application/sql":        true,
+	"application/xml":        true,
+}
+
+const markdownExtensions = blackfriday.CommonExtensions | blackfriday.AutoHeadingIDs
+
+var markdownExts = map[string]bool{
+	".md":       true,
+	".markdown": true,
+	".mdown":    true,
+}
+
+// Document is a rendered text file.
+type Document struct {
+	HTML      template.HTML
+	CSS       template.CSS
+	Language  string
+	Markdown  bool
+	Truncated bool
+}
+
+var (
+	formatter = html.New(
+		html.WithClasses(true),
+		html.WithLineNumbers(true),
+		html.LinkableLineNumbers(true, "L"),
+	)
+	style  = styles.Get("github")
+	policy = markdownPolicy()
+	css    = styleSheet()
+)
+
+// Viewable reports whether a file looks like something we can show inline.
+func Viewable(name, contentType string) bool {
+	t := lib.MediaType(contentType)
+
+	switch {
+	case strings.HasPrefix(t, "text/"),
+		textTypes[t],
+		strings.HasSuffix(t, "+json"),
+		strings.HasSuffix(t, "+xml"):
+		return true
+	}
+
+	// Plenty of source files get sniffed as octet-stream when they are short
+	// or start with something odd.
+	return t == "application/octet-stream" && lexers.Match(filepath.Base(name)) != nil
+}
+
+// Render reads at most limit bytes from r and renders them as highlighted
+// source, or as sanitized HTML for Markdown.
+func Render(r io.Reader, name, contentType string, limit int64) (*Document, error) {
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+
+	buf, err := ioutil.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to read file")
+	}
+
+	doc := &Document{CSS: css}
+	if int64(len(buf)) > limit {
+		doc.Truncated = true
+		buf = trimPartialRune(buf[:limit])
+	}
+
+	if !utf8.Valid(buf) {
+		return nil, ErrBinary
+	}
+
+	if isMarkdown(name, contentType) {
+		doc.Markdown = true
+		doc.Language = "Markdown"
+		doc.HTML = template.HTML(policy.SanitizeBytes(blackfriday.Run(buf, blackfriday.WithExtensions(markdownExtensions))))
+		return doc, nil
+	}
+
+	lexer := pickLexer(name, contentType, buf)
+	doc.Language = lexer.Config().Name
+
+	it, err := chroma.Coalesce(lexer).Tokenise(nil, string(buf))
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to tokenise file")
+	}
+
+	var out bytes.Buffer
+	if err = formatter.Format(&out, style, it); err != nil {
+		return nil, errors.Wrap(err, "Failed to highlight file")
+	}
+
+	doc.HTML = template.HTML(out.String())
+	return doc, nil
+}
+
+// Extension first, then the detected type, then a guess from the contents.
+func pickLexer(name, contentType string, buf []byte) chroma.Lexer {
+	lexer := lexers.Match(filepath.Base(name))
+	if lexer == nil {
+		lexer = lexers.MatchMimeType(lib.MediaType(contentType))
+	}
+	if lexer == nil {
+		lexer = lexers.Analyse(string(buf))
+	}
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+
+	return lexer
+}
+
+func isMarkdown(name, contentType string) bool {
+	t := lib.MediaType(contentType)
+	return t == "text/markdown" || t == "text/x-markdown" ||
+		markdownExts[strings.ToLower(filepath.Ext(name))]
+}
+
+// Heading ids are kept so sections can be linked to.
+func markdownPolicy() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.AllowAttrs("id").Matching(bluemonday.Paragraph).OnElements("h1", "h2", "h3", "h4", "h5", "h6")
+	return p
+}
+
+func styleSheet() template.CSS {
+	var b bytes.Buffer
+	formatter.WriteCSS(&b, style)
+	return template.CSS(b.String())
+}
+
+// Cutting at a byte limit can split the last character in half.
+func trimPartialRune(b []byte) []byte {
+	for i := 0; i < utf8.UTFMax && len(b) > 0; i++ {
+		r, size := utf8.DecodeLastRune(b)
+		if r != utf8.RuneError || size != 1 {
+			break
+		}
+		b = b[:len(b)-1]
+	}
+
+	return b
+}
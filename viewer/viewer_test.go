@@ -0,0 +1,76 @@
+package viewer_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zqzca/back/viewer"
+)
+
+func TestViewable(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	a.True(viewer.Viewable("build.log", "text/plain; charset=utf-8"))
+	a.True(viewer.Viewable("data.json", "application/json"))
+	a.True(viewer.Viewable("main.go", "application/octet-stream"))
+	a.False(viewer.Viewable("cat.jpg", "image/jpeg"))
+	a.False(viewer.Viewable("blob.bin", "application/octet-stream"))
+}
+
+func TestRenderCode(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	doc, err := viewer.Render(strings.NewReader("package main\n\nfunc main() {}\n"), "main.go", "text/plain", 0)
+	a.Nil(err)
+	a.Equal("Go", doc.Language)
+	a.False(doc.Markdown)
+	a.False(doc.Truncated)
+	a.Contains(string(doc.HTML), `id="L3"`)
+	a.Contains(string(doc.HTML), "func")
+	a.NotEmpty(doc.CSS)
+}
+
+func TestRenderEscapesCode(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	doc, err := viewer.Render(strings.NewReader("<script>alert(1)</script>"), "x.txt", "text/plain", 0)
+	a.Nil(err)
+	a.NotContains(string(doc.HTML), "<script>")
+}
+
+func TestRenderMarkdown(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	md := "# Hello World\n\n<script>alert(1)</script>\n\n[x](javascript:alert(1))\n"
+	doc, err := viewer.Render(strings.NewReader(md), "README.md", "text/plain", 0)
+	a.Nil(err)
+	a.True(doc.Markdown)
+	a.Contains(string(doc.HTML), `<h1 id="hello-world">Hello World</h1>`)
+	a.NotContains(string(doc.HTML), "<script>")
+	a.NotContains(string(doc.HTML), "javascript:")
+}
+
+func TestRenderTruncates(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	// The limit lands in the middle of the two byte é.
+	doc, err := viewer.Render(strings.NewReader("aaaé and more"), "a.txt", "text/plain", 4)
+	a.Nil(err)
+	a.True(doc.Truncated)
+	a.Contains(string(doc.HTML), "aaa")
+	a.NotContains(string(doc.HTML), "more")
+}
+
+func TestRenderBinary(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	_, err := viewer.Render(strings.NewReader("\xff\xfe\x00\x01"), "a.txt", "text/plain", 0)
+	a.Equal(viewer.ErrBinary, err)
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/davecgh/go-spew/spew"
 	uuid "github.com/satori/go.uuid"
+	"github.com/zqzca/back/lib"
 
 	"golang.org/x/net/websocket"
 )
@@ -16,6 +17,7 @@ const channelBufSize = 100
 // Chat client.
 type Client struct {
 	id     string
+	ip     string
 	ws     *websocket.Conn
 	server *Server
 	ch     chan *Event
@@ -33,10 +35,11 @@ func NewClient(ws *websocket.Conn, server *Server) *Client {
 	}
 
 	id := uuid.NewV4().String()
+	ip, _ := lib.ClientIP(ws.Request())
 	ch := make(chan *Event, channelBufSize)
 	doneCh := make(chan bool)
 
-	return &Client{id, ws, server, ch, doneCh}
+	return &Client{id, ip, ws, server, ch, doneCh}
 }
 
 func (c *Client) Conn() *websocket.Conn {
@@ -112,7 +112,7 @@ func (s *Server) handleChannels() {
 		case c := <-s.register:
 			s.clients[c.id] = c
 			s.send(c, RegisterEvent(c.id))
-			s.Info("WS Added Client", "client", c.id, "total", len(s.clients))
+			s.Info("WS Added Client", "client", c.id, "ip", c.ip, "total", len(s.clients))
 
 		// del a client
 		case c := <-s.unregister: